@@ -13,11 +13,11 @@ import (
 	"strconv"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 
 	"github.com/wdvxdr1123/ZeroBot/message"
 	"github.com/wdvxdr1123/ZeroBot/utils/helper"
+	"github.com/wdvxdr1123/ZeroBot/utils/metrics"
 )
 
 var base64Reg = regexp.MustCompile(`"type":"image","data":\{"file":"base64://[\w/\+=]+`)
@@ -53,22 +53,34 @@ func formatMessage(msg any) string {
 
 // CallAction 调用 cqhttp API
 func (ctx *Ctx) CallAction(action string, params Params) APIResponse {
-	c, cancel := context.WithTimeout(context.Background(), time.Minute)
+	c, cancel := context.WithTimeout(ctx.Context(), time.Minute)
 	defer cancel()
 	return ctx.CallActionWithContext(c, action, params)
 }
 
 // CallActionWithContext 使用 context 调用 cqhttp API
 func (ctx *Ctx) CallActionWithContext(c context.Context, action string, params Params) APIResponse {
+	start := time.Now()
+	c, span := GetTracer().Start(c, "api "+action, Attr{"zerobot.api.action", action})
+	defer span.End()
 	rsp, err := ctx.caller.CallAPI(c, APIRequest{
 		Action: action,
 		Params: params,
 	})
+	if metrics.Enabled() {
+		metricAPIDuration.With(action).ObserveSince(start)
+	}
+	span.SetAttributes(Attr{"zerobot.api.retcode", rsp.RetCode})
 	if err != nil {
-		log.Errorln("[api] 调用", action, "时出现错误: ", err)
+		span.SetError(err)
+		metricAPIErrors.With(action, "error").Inc()
+		Log("api").Error("调用 API 时出现错误", F(FieldAction, action), F(FieldError, err))
 	}
 	if err == nil && rsp.RetCode != 0 {
-		log.Errorln("[api] 调用", action, "时出现错误, 返回值:", rsp.RetCode, ", 信息:", rsp.Message, "解释:", rsp.Wording)
+		span.SetError(errors.New(rsp.Message))
+		metricAPIErrors.With(action, "retcode").Inc()
+		Log("api").Error("调用 API 时出现错误", F(FieldAction, action), F("retcode", rsp.RetCode),
+			F("msg", rsp.Message), F("wording", rsp.Wording))
 	}
 	return rsp
 }
@@ -81,7 +93,7 @@ func (ctx *Ctx) SendGroupMessage(groupID int64, message any) int64 {
 		"message":  message,
 	}).Data.Get("message_id")
 	if rsp.Exists() {
-		log.Infof("[api] 发送群消息(%v): %v (id=%v)", groupID, formatMessage(message), rsp.Int())
+		Log("api").Info("发送群消息", F(FieldGroupID, groupID), F("message", formatMessage(message)), F("message_id", rsp.Int()))
 		return rsp.Int()
 	}
 	return 0 // 无法获取返回值
@@ -95,7 +107,7 @@ func (ctx *Ctx) SendPrivateMessage(userID int64, message any) int64 {
 		"message": message,
 	}).Data.Get("message_id")
 	if rsp.Exists() {
-		log.Infof("[api] 发送私聊消息(%v): %v (id=%v)", userID, formatMessage(message), rsp.Int())
+		Log("api").Info("发送私聊消息", F(FieldUserID, userID), F("message", formatMessage(message)), F("message_id", rsp.Int()))
 		return rsp.Int()
 	}
 	return 0 // 无法获取返回值
@@ -629,7 +641,8 @@ func (ctx *Ctx) SendGuildChannelMessage(guildID, channelID string, message any)
 		"message":    message,
 	}).Data.Get("message_id")
 	if rsp.Exists() {
-		log.Infof("[api] 发送频道消息(%v-%v): %v (id=%v)", guildID, channelID, formatMessage(message), rsp.Int())
+		Log("api").Info("发送频道消息", F("guild_id", guildID), F("channel_id", channelID),
+			F("message", formatMessage(message)), F("message_id", rsp.Int()))
 		return rsp.String()
 	}
 	return "0" // 无法获取返回值
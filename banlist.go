@@ -0,0 +1,384 @@
+package zero
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BanKind 黑白名单种类
+type BanKind string
+
+// 黑白名单种类
+const (
+	BlockUser  BanKind = "block_user"
+	BlockGroup BanKind = "block_group"
+	AllowUser  BanKind = "allow_user"
+	AllowGroup BanKind = "allow_group"
+)
+
+var banKinds = []BanKind{BlockUser, BlockGroup, AllowUser, AllowGroup}
+
+// BanEntry 黑白名单中的一项
+type BanEntry struct {
+	ID     int64  `json:"id"`
+	Reason string `json:"reason,omitempty"`
+	Expire int64  `json:"expire,omitempty"` // unix 秒, 0 为永久
+}
+
+// Expired 是否已过期
+func (e BanEntry) Expired(now time.Time) bool {
+	return e.Expire != 0 && e.Expire <= now.Unix()
+}
+
+// BanStore 黑白名单的持久化存储
+type BanStore interface {
+	Load() (map[BanKind][]BanEntry, error)
+	Save(map[BanKind][]BanEntry) error
+}
+
+type fileBanStore string
+
+// NewFileBanStore 以 JSON 文件保存黑白名单
+func NewFileBanStore(path string) BanStore {
+	return fileBanStore(path)
+}
+
+func (p fileBanStore) Load() (map[BanKind][]BanEntry, error) {
+	data, err := os.ReadFile(string(p))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := map[BanKind][]BanEntry{}
+	return m, json.Unmarshal(data, &m)
+}
+
+func (p fileBanStore) Save(m map[BanKind][]BanEntry) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(string(p)), 0o755); err != nil {
+		return err
+	}
+	tmp := string(p) + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, string(p))
+}
+
+// BanList 在匹配前过滤事件的黑白名单
+//
+// 被拉黑的用户或群的事件被忽略; 白名单非空时, 只处理白名单中的用户或群的事件.
+// 超级用户不受限制, 没有用户与群号的事件 (如心跳) 不受影响
+type BanList struct {
+	mu      sync.RWMutex
+	store   BanStore
+	entries map[BanKind]map[int64]BanEntry
+}
+
+// NewBanList 新建黑白名单, store 为 nil 时仅保存在内存中
+func NewBanList(store BanStore) (*BanList, error) {
+	b := &BanList{store: store, entries: map[BanKind]map[int64]BanEntry{}}
+	for _, k := range banKinds {
+		b.entries[k] = map[int64]BanEntry{}
+	}
+	if store == nil {
+		return b, nil
+	}
+	m, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for k, list := range m {
+		if b.entries[k] == nil {
+			continue
+		}
+		for _, e := range list {
+			b.entries[k][e.ID] = e
+		}
+	}
+	return b, nil
+}
+
+// DefaultBanListPath 全局黑白名单的保存路径, 需在首次调用 DefaultBanList 前修改
+var DefaultBanListPath = "banlist.json"
+
+var (
+	defaultBanList    *BanList
+	defaultBanListSet bool
+	banListMu         sync.RWMutex
+)
+
+// DefaultBanList 返回全局黑白名单, 未设置时在首次调用时从 DefaultBanListPath 加载
+func DefaultBanList() *BanList {
+	banListMu.RLock()
+	b, ok := defaultBanList, defaultBanListSet
+	banListMu.RUnlock()
+	if ok {
+		return b
+	}
+	banListMu.Lock()
+	defer banListMu.Unlock()
+	if !defaultBanListSet {
+		b, err := NewBanList(NewFileBanStore(DefaultBanListPath))
+		if err != nil {
+			// 不使用文件存储, 以免覆盖无法解析的文件
+			Log("bot").Error("加载黑白名单失败, 本次运行的修改不会保存", F(FieldError, err))
+			b, _ = NewBanList(nil)
+		}
+		defaultBanList, defaultBanListSet = b, true
+	}
+	return defaultBanList
+}
+
+// SetBanList 设置全局黑白名单, 为 nil 时不过滤
+func SetBanList(b *BanList) {
+	banListMu.Lock()
+	defer banListMu.Unlock()
+	defaultBanList, defaultBanListSet = b, true
+}
+
+// Add 添加一项, ttl 为 0 时永久有效
+func (b *BanList) Add(kind BanKind, id int64, ttl time.Duration, reason string) error {
+	e := BanEntry{ID: id, Reason: reason}
+	if ttl > 0 {
+		e.Expire = time.Now().Add(ttl).Unix()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.entries[kind]
+	if !ok {
+		return errors.New("zero: unknown ban kind " + string(kind))
+	}
+	m[id] = e
+	return b.save()
+}
+
+// Remove 移除一项
+func (b *BanList) Remove(kind BanKind, id int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.entries[kind]
+	if !ok {
+		return errors.New("zero: unknown ban kind " + string(kind))
+	}
+	if _, ok = m[id]; !ok {
+		return nil
+	}
+	delete(m, id)
+	return b.save()
+}
+
+// List 返回按 ID 排序的未过期项
+func (b *BanList) List(kind BanKind) []BanEntry {
+	now := time.Now()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	list := make([]BanEntry, 0, len(b.entries[kind]))
+	for _, e := range b.entries[kind] {
+		if !e.Expired(now) {
+			list = append(list, e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// save 清理过期项并持久化, 需持有写锁
+func (b *BanList) save() error {
+	now := time.Now()
+	m := make(map[BanKind][]BanEntry, len(b.entries))
+	for k, entries := range b.entries {
+		list := make([]BanEntry, 0, len(entries))
+		for id, e := range entries {
+			if e.Expired(now) {
+				delete(entries, id)
+				continue
+			}
+			list = append(list, e)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		m[k] = list
+	}
+	if b.store == nil {
+		return nil
+	}
+	return b.store.Save(m)
+}
+
+// contains 需持有读锁
+func (b *BanList) contains(kind BanKind, id int64, now time.Time) bool {
+	e, ok := b.entries[kind][id]
+	return ok && !e.Expired(now)
+}
+
+// hasAny 是否有未过期项, 需持有读锁
+func (b *BanList) hasAny(kind BanKind, now time.Time) bool {
+	for _, e := range b.entries[kind] {
+		if !e.Expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed 判断 user 在 group (私聊为 0) 中的事件是否应被处理
+func (b *BanList) Allowed(user, group int64) bool {
+	if user != 0 && issu(user) {
+		return true
+	}
+	now := time.Now()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if user != 0 {
+		if b.contains(BlockUser, user, now) {
+			return false
+		}
+		if b.hasAny(AllowUser, now) && !b.contains(AllowUser, user, now) {
+			return false
+		}
+	}
+	if group != 0 {
+		if b.contains(BlockGroup, group, now) {
+			return false
+		}
+		if b.hasAny(AllowGroup, now) && !b.contains(AllowGroup, group, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rule 返回检查黑白名单的 Rule
+func (b *BanList) Rule() Rule {
+	return func(ctx *Ctx) bool {
+		return b.Allowed(ctx.Event.UserID, ctx.Event.GroupID)
+	}
+}
+
+// SetBanList 为该 Engine 单独设置黑白名单, 覆盖全局设置, 为 nil 时不过滤
+func (e *Engine) SetBanList(b *BanList) *Engine {
+	e.banList = b
+	e.banListSet = true
+	return e
+}
+
+// banned 依 Engine 或全局设置判断事件是否被过滤
+func banned(e *Engine, ctx *Ctx) bool {
+	b := DefaultBanList()
+	if e != nil && e.banListSet {
+		b = e.banList
+	}
+	return b != nil && !b.Allowed(ctx.Event.UserID, ctx.Event.GroupID)
+}
+
+// parseBanDuration 解析 30m, 2h, 7d 形式的时长
+func parseBanDuration(s string) (time.Duration, bool) {
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		d, err := strconv.ParseUint(n, 10, 32)
+		return time.Duration(d) * 24 * time.Hour, err == nil
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d > 0
+}
+
+// RegisterCommands 在 e 上注册仅超级用户可用的黑白名单管理命令
+//
+//	ban/unban <qq> [时长] [理由]
+//	bangroup/unbangroup <群号> [时长] [理由]
+//	allow/disallow <qq>
+//	allowgroup/disallowgroup <群号>
+//	banlist
+func (b *BanList) RegisterCommands(e *Engine) {
+	cmds := map[string]struct {
+		kind BanKind
+		add  bool
+	}{
+		"ban": {BlockUser, true}, "unban": {BlockUser, false},
+		"bangroup": {BlockGroup, true}, "unbangroup": {BlockGroup, false},
+		"allow": {AllowUser, true}, "disallow": {AllowUser, false},
+		"allowgroup": {AllowGroup, true}, "disallowgroup": {AllowGroup, false},
+	}
+	names := []string{"banlist"}
+	for name := range cmds {
+		names = append(names, name)
+	}
+	// 长命令优先匹配, 避免 ban 截断 bangroup
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	e.OnCommandGroup(names, SuperUserPermission).SetBlock(true).Handle(func(ctx *Ctx) {
+		if ctx.State["command"] == "banlist" {
+			ctx.Send(b.String())
+			return
+		}
+		cmd := cmds[ctx.State["command"].(string)]
+		args := strings.Fields(ctx.State["args"].(string))
+		id := int64(0)
+		for _, seg := range ctx.Event.Message {
+			if seg.Type == "at" {
+				id, _ = strconv.ParseInt(seg.Data["qq"], 10, 64)
+				break
+			}
+		}
+		if id == 0 && len(args) > 0 {
+			id, _ = strconv.ParseInt(args[0], 10, 64)
+			args = args[1:]
+		}
+		if id == 0 {
+			ctx.Send("请指定 qq 或群号")
+			return
+		}
+		var err error
+		if cmd.add {
+			var ttl time.Duration
+			if len(args) > 0 {
+				if d, ok := parseBanDuration(args[0]); ok {
+					ttl, args = d, args[1:]
+				}
+			}
+			err = b.Add(cmd.kind, id, ttl, strings.Join(args, " "))
+		} else {
+			err = b.Remove(cmd.kind, id)
+		}
+		if err != nil {
+			Log("bot").Error("保存黑白名单失败", F(FieldError, err))
+			ctx.Send("操作失败: " + err.Error())
+			return
+		}
+		ctx.Send("已" + ctx.State["command"].(string) + " " + strconv.FormatInt(id, 10))
+	})
+}
+
+// String 列出所有未过期项
+func (b *BanList) String() string {
+	sb := strings.Builder{}
+	for i, k := range banKinds {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(string(k))
+		sb.WriteByte(':')
+		for _, en := range b.List(k) {
+			sb.WriteString("\n  ")
+			sb.WriteString(strconv.FormatInt(en.ID, 10))
+			if en.Expire != 0 {
+				sb.WriteString(" 至 ")
+				sb.WriteString(time.Unix(en.Expire, 0).Format("2006-01-02 15:04"))
+			}
+			if en.Reason != "" {
+				sb.WriteString(" (" + en.Reason + ")")
+			}
+		}
+	}
+	return sb.String()
+}
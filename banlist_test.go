@@ -0,0 +1,83 @@
+package zero
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBanList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ban.json")
+	b, err := NewBanList(NewFileBanStore(path))
+	assert.NoError(t, err)
+
+	assert.True(t, b.Allowed(1, 10))
+	assert.NoError(t, b.Add(BlockUser, 1, 0, "spam"))
+	assert.NoError(t, b.Add(BlockGroup, 20, time.Hour, ""))
+	b.entries[BlockUser][2] = BanEntry{ID: 2, Expire: time.Now().Unix() - 1} // 已过期
+	assert.False(t, b.Allowed(1, 0))
+	assert.False(t, b.Allowed(3, 20))
+	assert.True(t, b.Allowed(2, 10))
+	assert.True(t, b.Allowed(0, 0))
+
+	assert.NoError(t, b.Add(AllowGroup, 10, 0, ""))
+	assert.True(t, b.Allowed(3, 10))
+	assert.False(t, b.Allowed(3, 30))
+	assert.True(t, b.Allowed(3, 0))
+
+	setConfig(t, func(c *Config) { c.SuperUsers = []int64{1} })
+	assert.True(t, b.Allowed(1, 20))
+
+	// 重新加载
+	b2, err := NewBanList(NewFileBanStore(path))
+	assert.NoError(t, err)
+	assert.Equal(t, []BanEntry{{ID: 1, Reason: "spam"}}, b2.List(BlockUser))
+	assert.False(t, b2.Allowed(3, 20))
+	assert.NoError(t, b2.Remove(BlockUser, 1))
+	assert.True(t, b2.Allowed(1, 10))
+}
+
+func TestDefaultBanList(t *testing.T) {
+	path := DefaultBanListPath
+	DefaultBanListPath = filepath.Join(t.TempDir(), "ban.json")
+	defer func() {
+		DefaultBanListPath = path
+		SetBanList(nil)
+	}()
+	banListMu.Lock()
+	defaultBanList, defaultBanListSet = nil, false
+	banListMu.Unlock()
+
+	assert.NoError(t, DefaultBanList().Add(BlockUser, 1, 0, ""))
+	b, err := NewBanList(NewFileBanStore(DefaultBanListPath))
+	assert.NoError(t, err)
+	assert.False(t, b.Allowed(1, 0))
+}
+
+func TestEngineBanList(t *testing.T) {
+	global, _ := NewBanList(nil)
+	_ = global.Add(BlockUser, 1, 0, "")
+	SetBanList(global)
+	defer SetBanList(nil)
+
+	ctx := &Ctx{Event: &Event{UserID: 1}}
+	e := New()
+	assert.True(t, banned(e, ctx))
+	assert.True(t, banned(nil, ctx))
+	e.SetBanList(nil)
+	assert.False(t, banned(e, ctx))
+	own, _ := NewBanList(nil)
+	_ = own.Add(BlockUser, 2, 0, "")
+	e.SetBanList(own)
+	assert.False(t, banned(e, ctx))
+	ctx.Event.UserID = 2
+	assert.True(t, banned(e, ctx))
+
+	d, ok := parseBanDuration("7d")
+	assert.True(t, ok)
+	assert.Equal(t, 7*24*time.Hour, d)
+	_, ok = parseBanDuration("spam")
+	assert.False(t, ok)
+}
@@ -12,11 +12,11 @@ import (
 	"time"
 
 	"github.com/FloatTech/ttl"
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 
 	"github.com/wdvxdr1123/ZeroBot/message"
 	"github.com/wdvxdr1123/ZeroBot/utils/helper"
+	"github.com/wdvxdr1123/ZeroBot/utils/metrics"
 )
 
 const (
@@ -27,19 +27,26 @@ const (
 
 // Config is config of zero bot
 type Config struct {
-	NickName        []string      `json:"nickname"`           // 机器人名称
-	CommandPrefix   string        `json:"command_prefix"`     // 触发命令
-	SuperUsers      []int64       `json:"super_users"`        // 超级用户
-	RingLen         uint          `json:"ring_len"`           // 事件环长度 (默认关闭)
-	Latency         time.Duration `json:"latency"`            // 事件处理延迟 (延迟 latency 再处理事件，在 ring 模式下不可低于 1ms)
-	MaxProcessTime  time.Duration `json:"max_process_time"`   // 事件最大处理时间 (默认4min)
-	MarkMessage     bool          `json:"mark_message"`       // 自动标记消息为已读
-	KeepAtMeMessage bool          `json:"keep_at_me_message"` // 是否保留at me的原始消息
-	AddSpaceAfterAt bool          `json:"at_space"`           // 是否在At消息后没有空格时自动添加空格
-	Driver          []Driver      `json:"-"`                  // 通信驱动
+	NickName        []string        `json:"nickname"`           // 机器人名称
+	CommandPrefix   string          `json:"command_prefix"`     // 触发命令
+	SuperUsers      []int64         `json:"super_users"`        // 超级用户
+	RingLen         uint            `json:"ring_len"`           // 事件环长度 (默认关闭)
+	Latency         time.Duration   `json:"latency"`            // 事件处理延迟 (延迟 latency 再处理事件，在 ring 模式下不可低于 1ms)
+	MaxProcessTime  time.Duration   `json:"max_process_time"`   // 事件最大处理时间 (默认4min)
+	MarkMessage     bool            `json:"mark_message"`       // 自动标记消息为已读
+	KeepAtMeMessage bool            `json:"keep_at_me_message"` // 是否保留at me的原始消息
+	AddSpaceAfterAt bool            `json:"at_space"`           // 是否在At消息后没有空格时自动添加空格
+	APIBalance      BalanceStrategy `json:"api_balance"`        // 同一账号有多个连接时的 API 调用策略, 默认 least_pending
+	Driver          []Driver        `json:"-"`                  // 通信驱动
+
+	Logger    Logger           `json:"-"`          // 日志输出, 为 nil 时使用 logrus
+	LogLevels map[string]Level `json:"log_levels"` // 各子系统的最低日志级别, 如 {"*": "info", "ws": "warn"}
+	Redact    Redactor         `json:"-"`          // 日志字段脱敏, 为 nil 时使用 DefaultRedactor
 }
 
 // APICallers 所有的APICaller列表， 通过self-ID映射
+//
+// 驱动通过 AddCaller 与 RemoveCaller 登记连接, 值为同一账号所有连接的 *CallerGroup
 var APICallers callerMap
 
 // APICaller is the interface of CallAPI
@@ -47,6 +54,12 @@ type APICaller interface {
 	CallAPI(c context.Context, request APIRequest) (APIResponse, error)
 }
 
+// EventFinisher 可由 APICaller 实现, 事件的匹配与处理完成后调用 EventFinished,
+// 如 HTTP 驱动据此结束快速操作的等待
+type EventFinisher interface {
+	EventFinished()
+}
+
 // Driver 与OneBot通信的驱动，使用driver.DefaultWebSocketDriver
 type Driver interface {
 	Connect()
@@ -54,8 +67,22 @@ type Driver interface {
 }
 
 // BotConfig 运行中bot的配置，是Run函数的参数的拷贝
+//
+// Deprecated: 不随 ReloadConfig 更新, 且直接读写会与事件处理竞争, 请使用 GetConfig
 var BotConfig Config
 
+// botConfig 当前生效的配置, Run 与 ReloadConfig 整体替换, 不修改已发布的 *Config
+var botConfig atomic.Pointer[Config]
+
+func init() {
+	botConfig.Store(&Config{})
+}
+
+// GetConfig 返回运行中 bot 的配置, 可被并发读取, 调用者不应修改返回值
+func GetConfig() *Config {
+	return botConfig.Load()
+}
+
 var (
 	evring    eventRing // evring 事件环
 	isrunning uintptr
@@ -66,12 +93,16 @@ func runinit(op *Config) {
 	if op.MaxProcessTime == 0 {
 		op.MaxProcessTime = time.Minute * 4
 	}
-	BotConfig = *op
+	c := *op
+	botConfig.Store(&c)
+	BotConfig = c
 	if op.RingLen == 0 {
 		return
 	}
 	evring = newring(op.RingLen)
-	evring.loop(op.Latency, op.MaxProcessTime, processEventAsync)
+	evring.loop(op.Latency, op.MaxProcessTime, func(b []byte, c APICaller, _ time.Duration) {
+		processEventAsync(b, c, GetConfig().MaxProcessTime)
+	})
 }
 
 func (op *Config) directlink(b []byte, c APICaller) {
@@ -79,14 +110,14 @@ func (op *Config) directlink(b []byte, c APICaller) {
 		if op.Latency != 0 {
 			time.Sleep(op.Latency)
 		}
-		processEventAsync(b, c, op.MaxProcessTime)
+		processEventAsync(b, c, GetConfig().MaxProcessTime) // 每次读取以支持 ReloadConfig
 	}()
 }
 
 // Run 主函数初始化
 func Run(op *Config) {
 	if !atomic.CompareAndSwapUintptr(&isrunning, 0, 1) {
-		log.Warnln("[bot] 已忽略重复调用的 Run")
+		Log("bot").Warn("已忽略重复调用的 Run")
 	}
 	runinit(op)
 	linkf := op.directlink
@@ -104,7 +135,7 @@ func Run(op *Config) {
 //	preblock 在所有 Driver 连接后，调用最后一个 Driver 的 Listen 阻塞前执行本函数
 func RunAndBlock(op *Config, preblock func()) {
 	if !atomic.CompareAndSwapUintptr(&isrunning, 0, 1) {
-		log.Warnln("[bot] 已忽略重复调用的 RunAndBlock")
+		Log("bot").Warn("已忽略重复调用的 RunAndBlock")
 	}
 	runinit(op)
 	linkf := op.directlink
@@ -229,10 +260,14 @@ func processEventAsync(response []byte, caller APICaller, maxwait time.Duration)
 	if event.PostType == "message" {
 		preprocessMessageEvent(&event, idx)
 	}
+	recordEvent(&event, idx)
+	if metrics.Enabled() {
+		metricEvents.With(event.PostType, event.DetailType, strconv.FormatInt(event.SelfID, 10)).Inc()
+	}
 	ctx := &Ctx{
 		Event:  &event,
 		State:  State{StateKeyEventIndex: idx},
-		caller: &messageLogger{msgid: msgid, caller: caller},
+		caller: &messageLogger{msgid: msgid, caller: groupOf(event.SelfID, caller)},
 	}
 	matcherLock.Lock()
 	if hasMatcherListChanged {
@@ -240,13 +275,78 @@ func processEventAsync(response []byte, caller APICaller, maxwait time.Duration)
 		copy(matcherListForRanging, matcherList)
 		hasMatcherListChanged = false
 	}
+	matchers := matcherListForRanging
 	matcherLock.Unlock()
-	go match(ctx, idx, matcherListForRanging, maxwait)
+	finisher, _ := caller.(EventFinisher)
+	if !tracing() {
+		go func(matchers []*Matcher) {
+			if finisher != nil {
+				defer finisher.EventFinished()
+			}
+			match(ctx, idx, matchers, maxwait)
+		}(matchers)
+		return
+	}
+	c, span := GetTracer().Start(context.Background(), "event "+event.PostType+"/"+event.DetailType,
+		Attr{"zerobot.event.index", int64(idx)},
+		Attr{"zerobot.event.post_type", event.PostType},
+		Attr{"zerobot.event.detail_type", event.DetailType},
+		Attr{"zerobot.event.sub_type", event.SubType},
+		Attr{"zerobot.self_id", event.SelfID},
+		Attr{"zerobot.user_id", event.UserID},
+		Attr{"zerobot.group_id", event.GroupID},
+	)
+	ctx.WithContext(c)
+	go func(matchers []*Matcher) {
+		defer span.End()
+		if finisher != nil {
+			defer finisher.EventFinished()
+		}
+		match(ctx, idx, matchers, maxwait)
+	}(matchers)
+}
+
+// matcherSpans match 中当前 Matcher 的追踪
+type matcherSpans struct {
+	event   context.Context
+	matcher Span
+	handler Span
+}
+
+func (s *matcherSpans) start(ctx *Ctx, m *Matcher) {
+	s.end(ctx)
+	engine, label := matcherLabel(m)
+	var c context.Context
+	c, s.matcher = GetTracer().Start(s.event, "matcher "+label,
+		Attr{"zerobot.engine", engine}, Attr{"zerobot.matcher", label})
+	ctx.WithContext(c)
+}
+
+func (s *matcherSpans) startHandler(ctx *Ctx) {
+	var c context.Context
+	c, s.handler = GetTracer().Start(ctx.Context(), "handler")
+	ctx.WithContext(c)
+}
+
+func (s *matcherSpans) end(ctx *Ctx) {
+	if s.handler != nil {
+		s.handler.End()
+		s.handler = nil
+	}
+	if s.matcher != nil {
+		s.matcher.End()
+		s.matcher = nil
+	}
+	ctx.WithContext(s.event)
 }
 
 // match 匹配规则，处理事件
 func match(ctx *Ctx, idx uintptr, matchers []*Matcher, maxwait time.Duration) {
-	if BotConfig.MarkMessage && ctx.Event.MessageID != nil {
+	blog := Log("bot").With(F(FieldEvent, idx))
+	inflight := metricEventsInFlight.With()
+	inflight.Inc()
+	defer inflight.Dec()
+	if GetConfig().MarkMessage && ctx.Event.MessageID != nil {
 		go ctx.MarkThisMessageAsRead()
 	}
 	gorule := func(rule Rule) <-chan bool {
@@ -255,7 +355,7 @@ func match(ctx *Ctx, idx uintptr, matchers []*Matcher, maxwait time.Duration) {
 			defer func() {
 				close(ch)
 				if pa := recover(); pa != nil {
-					log.Errorf("[bot] [%d] execute rule err: %v\n%v", idx, pa, helper.BytesToString(debug.Stack()))
+					blog.Error("execute rule err", F(FieldError, pa), F("stack", helper.BytesToString(debug.Stack())))
 				}
 			}()
 			ch <- rule(ctx)
@@ -268,7 +368,7 @@ func match(ctx *Ctx, idx uintptr, matchers []*Matcher, maxwait time.Duration) {
 			defer func() {
 				close(ch)
 				if pa := recover(); pa != nil {
-					log.Errorf("[bot] [%d] execute handler err: %v\n%v", idx, pa, helper.BytesToString(debug.Stack()))
+					blog.Error("execute handler err", F(FieldError, pa), F("stack", helper.BytesToString(debug.Stack())))
 				}
 			}()
 			h(ctx)
@@ -278,11 +378,17 @@ func match(ctx *Ctx, idx uintptr, matchers []*Matcher, maxwait time.Duration) {
 	}
 	t := time.NewTimer(maxwait)
 	defer t.Stop()
+	trace := tracing()
+	spans := matcherSpans{event: ctx.Context()}
+	defer spans.end(ctx)
 loop:
 	for _, matcher := range matchers {
 		if !matcher.Type(ctx) {
 			continue
 		}
+		if trace {
+			spans.start(ctx, matcher)
+		}
 		for k := range ctx.State { // Clear State
 			if !strings.HasPrefix(k, StateKeyPrefixKeep) {
 				delete(ctx.State, k)
@@ -291,6 +397,14 @@ loop:
 		m := matcher.copy()
 		ctx.ma = m
 
+		// ban list
+		if banned(m.Engine, ctx) {
+			continue
+		}
+		if engineDisabled(m.Engine, ctx) {
+			continue
+		}
+
 		// pre handler
 		if m.Engine != nil {
 			for _, handler := range m.Engine.preHandler {
@@ -307,10 +421,10 @@ loop:
 					case <-t.C:
 						if m.NoTimeout { // 不设超时限制
 							t.Reset(maxwait)
-							log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "preHandler 处理达到最大时延, 但用户禁止退出")
+							blog.Warn("preHandler 处理达到最大时延, 但用户禁止退出", F(FieldMatcher, matcherName(matcher)))
 							continue
 						}
-						log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "preHandler 处理达到最大时延, 退出")
+						blog.Warn("preHandler 处理达到最大时延, 退出", F(FieldMatcher, matcherName(matcher)))
 						break loop
 					}
 					break
@@ -332,10 +446,10 @@ loop:
 				case <-t.C:
 					if m.NoTimeout { // 不设超时限制
 						t.Reset(maxwait)
-						log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "rule 处理达到最大时延, 但用户禁止退出")
+						blog.Warn("rule 处理达到最大时延, 但用户禁止退出", F(FieldMatcher, matcherName(matcher)))
 						continue
 					}
-					log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "rule 处理达到最大时延, 退出")
+					blog.Warn("rule 处理达到最大时延, 退出", F(FieldMatcher, matcherName(matcher)))
 					break loop
 				}
 				break
@@ -358,10 +472,10 @@ loop:
 					case <-t.C:
 						if m.NoTimeout { // 不设超时限制
 							t.Reset(maxwait)
-							log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "midHandler 处理达到最大时延, 但用户禁止退出")
+							blog.Warn("midHandler 处理达到最大时延, 但用户禁止退出", F(FieldMatcher, matcherName(matcher)))
 							continue
 						}
-						log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "midHandler 处理达到最大时延, 退出")
+						blog.Warn("midHandler 处理达到最大时延, 退出", F(FieldMatcher, matcherName(matcher)))
 						break loop
 					}
 					break
@@ -373,6 +487,14 @@ loop:
 			matcher.Delete()
 		}
 
+		var start time.Time
+		if metrics.Enabled() {
+			start = time.Now()
+		}
+		if trace {
+			spans.matcher.SetAttributes(Attr{"zerobot.matcher.hit", true})
+			spans.startHandler(ctx)
+		}
 		if m.Handler != nil {
 			for _, handler := range m.Handler {
 				c := gohandler(handler)
@@ -382,10 +504,10 @@ loop:
 					case <-t.C:
 						if m.NoTimeout { // 不设超时限制
 							t.Reset(maxwait)
-							log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "Handler 处理达到最大时延, 但用户禁止退出")
+							blog.Warn("Handler 处理达到最大时延, 但用户禁止退出", F(FieldMatcher, matcherName(matcher)))
 							continue
 						}
-						log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "Handler 处理达到最大时延, 退出")
+						blog.Warn("Handler 处理达到最大时延, 退出", F(FieldMatcher, matcherName(matcher)))
 						break loop
 					}
 					break
@@ -393,6 +515,12 @@ loop:
 			}
 		}
 
+		if metrics.Enabled() {
+			engine, label := matcherLabel(matcher)
+			metricMatcherHits.With(engine, label).Inc()
+			metricHandlerDuration.With(engine, label).ObserveSince(start)
+		}
+
 		if m.Engine != nil {
 			// post handler
 			for _, handler := range m.Engine.postHandler {
@@ -403,10 +531,10 @@ loop:
 					case <-t.C:
 						if m.NoTimeout { // 不设超时限制
 							t.Reset(maxwait)
-							log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "postHandler 处理达到最大时延, 但用户禁止退出")
+							blog.Warn("postHandler 处理达到最大时延, 但用户禁止退出", F(FieldMatcher, matcherName(matcher)))
 							continue
 						}
-						log.Warnln("[bot]", "["+strconv.FormatUint(uint64(idx), 10)+"]", "postHandler 处理达到最大时延, 退出")
+						blog.Warn("postHandler 处理达到最大时延, 退出", F(FieldMatcher, matcherName(matcher)))
 						break loop
 					}
 					break
@@ -448,7 +576,7 @@ func preprocessMessageEvent(e *Event, idx uintptr) {
 				qq, _ := strconv.ParseInt(m.Data["qq"], 10, 64)
 				if qq == e.SelfID {
 					e.IsToMe = true
-					if !BotConfig.KeepAtMeMessage {
+					if !GetConfig().KeepAtMeMessage {
 						e.Message = append(e.Message[:i], e.Message[i+1:]...)
 					}
 					return
@@ -461,7 +589,7 @@ func preprocessMessageEvent(e *Event, idx uintptr) {
 		first := e.Message[0]
 		first.Data["text"] = strings.TrimLeft(first.Data["text"], " ") // Trim!
 		text := first.Data["text"]
-		for _, nickname := range BotConfig.NickName {
+		for _, nickname := range GetConfig().NickName {
 			if strings.HasPrefix(text, nickname) {
 				e.IsToMe = true
 				first.Data["text"] = text[len(nickname):]
@@ -472,14 +600,18 @@ func preprocessMessageEvent(e *Event, idx uintptr) {
 
 	switch {
 	case e.DetailType == "group":
-		log.Infof("[bot] [%d] 收到群(%v)消息 %v : %v", idx, e.GroupID, e.Sender.String(), e.RawMessage)
+		Log("bot").Info("收到群消息", F(FieldEvent, idx), F(FieldSelfID, e.SelfID), F(FieldGroupID, e.GroupID),
+			F(FieldUserID, e.UserID), F("sender", e.Sender.String()), F("message", e.RawMessage))
 		processAt()
 	case e.DetailType == "guild" && e.SubType == "channel":
-		log.Infof("[bot] [%d] 收到频道(%v)(%v-%v)消息 %v : %v", idx, e.GroupID, e.GuildID, e.ChannelID, e.Sender.String(), e.Message)
+		Log("bot").Info("收到频道消息", F(FieldEvent, idx), F(FieldSelfID, e.SelfID), F(FieldGroupID, e.GroupID),
+			F("guild_id", e.GuildID), F("channel_id", e.ChannelID), F(FieldUserID, e.UserID),
+			F("sender", e.Sender.String()), F("message", e.Message.String()))
 		processAt()
 	default:
 		e.IsToMe = true // 私聊也判断为at
-		log.Infof("[bot] [%d] 收到私聊消息 %v : %v", idx, e.Sender.String(), e.RawMessage)
+		Log("bot").Info("收到私聊消息", F(FieldEvent, idx), F(FieldSelfID, e.SelfID), F(FieldUserID, e.UserID),
+			F("sender", e.Sender.String()), F("message", e.RawMessage))
 	}
 	if len(e.Message) > 0 && e.Message[0].Type == "text" { // Trim Again!
 		e.Message[0].Data["text"] = strings.TrimLeft(e.Message[0].Data["text"], " ")
@@ -495,6 +627,31 @@ func preprocessNoticeEvent(e *Event) {
 	}
 }
 
+var (
+	shutdownHooks   []func()
+	shutdownHooksMu sync.Mutex
+)
+
+// OnShutdown 注册在 Shutdown 时执行的清理函数, 如关闭数据库
+func OnShutdown(f func()) {
+	shutdownHooksMu.Lock()
+	shutdownHooks = append(shutdownHooks, f)
+	shutdownHooksMu.Unlock()
+}
+
+// Shutdown 按注册的逆序执行所有清理函数, 应在进程退出前调用
+//
+// 每个清理函数只会执行一次
+func Shutdown() {
+	shutdownHooksMu.Lock()
+	hooks := shutdownHooks
+	shutdownHooks = nil
+	shutdownHooksMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
 // GetBot 获取指定的bot (Ctx)实例
 func GetBot(id int64) *Ctx {
 	caller, ok := APICallers.Load(id)
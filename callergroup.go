@@ -0,0 +1,265 @@
+package zero
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalanceStrategy 同一账号有多个连接时选择 APICaller 的策略
+type BalanceStrategy string
+
+const (
+	// BalanceLeastPending 优先选择进行中调用最少的连接, 为默认策略
+	BalanceLeastPending BalanceStrategy = "least_pending"
+	// BalanceRoundRobin 依次轮流使用各连接
+	BalanceRoundRobin BalanceStrategy = "round_robin"
+)
+
+const (
+	// CallerMaxFailures 连接连续失败达到该次数后暂时视为不可用
+	CallerMaxFailures = 3
+	// CallerRetryInterval 不可用的连接每多失败一次增加的等待时长, 最长为 CallerMaxRetryInterval
+	CallerRetryInterval = 5 * time.Second
+	// CallerMaxRetryInterval 不可用的连接再次被优先选择前的最长等待时长
+	CallerMaxRetryInterval = time.Minute
+)
+
+// ErrNoCaller 账号的连接组中没有可用的连接
+var ErrNoCaller = errors.New("zero: no api caller")
+
+// ErrNotSent 表示请求未能发出, APICaller 可包装后返回, CallerGroup 会在其它连接上重试任何 API
+var ErrNotSent = errors.New("zero: api request not sent")
+
+// CallerGroup 同一账号的一组连接, 本身是 APICaller
+//
+// 调用时按 Config.APIBalance 选择可用的连接. 返回 ErrNotSent 时在其余连接上重试;
+// 其它错误 (如超时) 只重试 get_ 等只读的 API, 以免 send_msg 等操作被执行两次
+type CallerGroup struct {
+	mu      sync.Mutex
+	members []*groupMember
+	next    uint32
+}
+
+type groupMember struct {
+	caller    APICaller
+	pending   int64 // atomic
+	fails     int
+	downUntil time.Time
+}
+
+// Callers 返回组中的连接
+func (g *CallerGroup) Callers() []APICaller {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	cs := make([]APICaller, len(g.members))
+	for i, m := range g.members {
+		cs[i] = m.caller
+	}
+	return cs
+}
+
+// Contains 判断 c 是否在组中
+func (g *CallerGroup) Contains(c APICaller) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.indexLocked(c) >= 0
+}
+
+func (g *CallerGroup) indexLocked(c APICaller) int {
+	for i, m := range g.members {
+		for mc := m.caller; mc != nil; {
+			if mc == c {
+				return i
+			}
+			w, ok := mc.(CallerWrapper)
+			if !ok {
+				break
+			}
+			mc = w.Unwrap()
+		}
+	}
+	return -1
+}
+
+// candidates 返回按策略排序的连接, 不可用的连接排在最后
+func (g *CallerGroup) candidates() []*groupMember {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := len(g.members)
+	if n == 0 {
+		return nil
+	}
+	start := int(g.next % uint32(n))
+	g.next++
+	now := time.Now()
+	healthy := make([]*groupMember, 0, n)
+	var down []*groupMember
+	for i := 0; i < n; i++ {
+		m := g.members[(start+i)%n]
+		if now.Before(m.downUntil) {
+			down = append(down, m)
+		} else {
+			healthy = append(healthy, m)
+		}
+	}
+	if GetConfig().APIBalance != BalanceRoundRobin {
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return atomic.LoadInt64(&healthy[i].pending) < atomic.LoadInt64(&healthy[j].pending)
+		})
+	}
+	return append(healthy, down...)
+}
+
+// report 记录一次调用的结果
+func (g *CallerGroup) report(m *groupMember, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err == nil {
+		m.fails = 0
+		m.downUntil = time.Time{}
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	m.fails++
+	if m.fails >= CallerMaxFailures {
+		wait := time.Duration(m.fails-CallerMaxFailures+1) * CallerRetryInterval
+		if wait > CallerMaxRetryInterval {
+			wait = CallerMaxRetryInterval
+		}
+		m.downUntil = time.Now().Add(wait)
+	}
+}
+
+// CallAPI 在选中的连接上调用 API, 失败时尝试其余连接
+func (g *CallerGroup) CallAPI(c context.Context, request APIRequest) (rsp APIResponse, err error) {
+	members := g.candidates()
+	if len(members) == 0 {
+		return rsp, ErrNoCaller
+	}
+	for i, m := range members {
+		if i > 0 {
+			if c.Err() != nil || !retryable(request.Action, err) {
+				return
+			}
+			Log("api").Warn("连接调用 API 失败, 尝试其他连接", F(FieldAction, request.Action), F(FieldError, err))
+		}
+		atomic.AddInt64(&m.pending, 1)
+		rsp, err = m.caller.CallAPI(c, request)
+		atomic.AddInt64(&m.pending, -1)
+		g.report(m, err)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// retryable 判断失败的调用能否在其它连接上重试
+func retryable(action string, err error) bool {
+	return errors.Is(err, ErrNotSent) || strings.HasPrefix(action, "get_") || strings.HasPrefix(action, "can_")
+}
+
+// groupOf 返回 c 所在的账号 id 的连接组, 使事件处理中的调用同样负载均衡与故障转移,
+// c 不在组中时 (如驱动为单个事件包装的 APICaller) 返回 c
+func groupOf(id int64, c APICaller) APICaller {
+	if v, ok := APICallers.Load(id); ok {
+		if g, ok := v.(*CallerGroup); ok && g.Contains(c) {
+			return g
+		}
+	}
+	return c
+}
+
+// CallerWrapper 包装其它连接的 APICaller, 如录制 API 调用
+//
+// 组中的成员以包装后的 APICaller 调用, 但 Contains, AddCaller 与 RemoveCaller
+// 传入被包装的 APICaller 时同样视为该成员
+type CallerWrapper interface {
+	APICaller
+	Unwrap() APICaller
+}
+
+var callerGroupsMu sync.Mutex
+
+// WrapCaller 将账号 id 的连接组中的 c 替换为包装 c 的 w, 使 GetBot 等取得的调用同样经过 w
+//
+// c 不在组中时返回 false
+func WrapCaller(id int64, c APICaller, w CallerWrapper) bool {
+	callerGroupsMu.Lock()
+	defer callerGroupsMu.Unlock()
+	v, ok := APICallers.Load(id)
+	if !ok {
+		return false
+	}
+	g, ok := v.(*CallerGroup)
+	if !ok {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, m := range g.members {
+		if m.caller == c {
+			m.caller = w
+			return true
+		}
+	}
+	return false
+}
+
+// AddCaller 将 c 加入账号 id 的连接组, 驱动在连接成功后调用
+//
+// APICallers 中已有的非 CallerGroup 的 APICaller 会被并入组中
+func AddCaller(id int64, c APICaller) {
+	callerGroupsMu.Lock()
+	defer callerGroupsMu.Unlock()
+	old, ok := APICallers.Load(id)
+	g, isGroup := old.(*CallerGroup)
+	if !isGroup {
+		g = &CallerGroup{}
+		if ok && old != c {
+			g.members = append(g.members, &groupMember{caller: old})
+		}
+	}
+	g.mu.Lock()
+	if g.indexLocked(c) < 0 {
+		g.members = append(g.members, &groupMember{caller: c})
+	}
+	g.mu.Unlock()
+	if !isGroup {
+		APICallers.Store(id, g)
+	}
+}
+
+// RemoveCaller 从账号 id 的连接组中移除 c, 组为空时从 APICallers 删除账号
+//
+// 返回 c 是否曾在组中
+func RemoveCaller(id int64, c APICaller) bool {
+	callerGroupsMu.Lock()
+	defer callerGroupsMu.Unlock()
+	old, ok := APICallers.Load(id)
+	if !ok {
+		return false
+	}
+	g, isGroup := old.(*CallerGroup)
+	if !isGroup {
+		return APICallers.CompareAndDelete(id, c)
+	}
+	g.mu.Lock()
+	i := g.indexLocked(c)
+	if i >= 0 {
+		g.members = append(g.members[:i], g.members[i+1:]...)
+	}
+	empty := len(g.members) == 0
+	g.mu.Unlock()
+	if empty {
+		APICallers.CompareAndDelete(id, g)
+	}
+	return i >= 0
+}
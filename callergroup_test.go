@@ -0,0 +1,145 @@
+package zero
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countCaller struct {
+	n   int
+	err error
+}
+
+func (c *countCaller) CallAPI(context.Context, APIRequest) (APIResponse, error) {
+	c.n++
+	return APIResponse{Status: "ok"}, c.err
+}
+
+func TestCallerGroupAddRemove(t *testing.T) {
+	const id = 10001
+	a, b := &countCaller{}, &countCaller{}
+	AddCaller(id, a)
+	AddCaller(id, b)
+	AddCaller(id, b)
+	ctx := GetBot(id)
+	assert.NotNil(t, ctx)
+	g, ok := ctx.caller.(*CallerGroup)
+	assert.True(t, ok)
+	assert.Equal(t, []APICaller{a, b}, g.Callers())
+
+	assert.True(t, RemoveCaller(id, a))
+	assert.False(t, RemoveCaller(id, a))
+	assert.NotNil(t, GetBot(id))
+	assert.True(t, RemoveCaller(id, b))
+	assert.Nil(t, GetBot(id))
+
+	// 直接 Store 的 APICaller 并入组中
+	APICallers.Store(id, a)
+	AddCaller(id, b)
+	g, _ = GetBot(id).caller.(*CallerGroup)
+	assert.Equal(t, []APICaller{a, b}, g.Callers())
+	RemoveCaller(id, a)
+	RemoveCaller(id, b)
+	assert.Nil(t, GetBot(id))
+}
+
+type wrapCaller struct {
+	APICaller
+}
+
+func (w wrapCaller) Unwrap() APICaller { return w.APICaller }
+
+func TestWrapCaller(t *testing.T) {
+	const id = 10002
+	a, b := &countCaller{}, &countCaller{}
+	w := wrapCaller{a}
+	assert.False(t, WrapCaller(id, a, w))
+	AddCaller(id, a)
+	AddCaller(id, b)
+	assert.True(t, WrapCaller(id, a, w))
+	assert.False(t, WrapCaller(id, a, w))
+	g, _ := GetBot(id).caller.(*CallerGroup)
+	assert.Equal(t, []APICaller{w, b}, g.Callers())
+	assert.True(t, g.Contains(a))
+	assert.True(t, g.Contains(w))
+
+	// 以被包装的 APICaller 添加或移除
+	AddCaller(id, a)
+	assert.Len(t, g.Callers(), 2)
+	assert.True(t, RemoveCaller(id, a))
+	assert.Equal(t, []APICaller{b}, g.Callers())
+	RemoveCaller(id, b)
+	assert.Nil(t, GetBot(id))
+}
+
+func TestCallerGroupBalance(t *testing.T) {
+	setConfig(t, func(c *Config) { c.APIBalance = BalanceRoundRobin })
+	a, b := &countCaller{}, &countCaller{}
+	g := &CallerGroup{members: []*groupMember{{caller: a}, {caller: b}}}
+	for i := 0; i < 10; i++ {
+		_, err := g.CallAPI(context.Background(), APIRequest{Action: "get_status"})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 5, a.n)
+	assert.Equal(t, 5, b.n)
+
+	// least_pending 优先选择进行中调用少的连接
+	setConfig(t, func(c *Config) { c.APIBalance = BalanceLeastPending })
+	g.members[0].pending = 1
+	a.n, b.n = 0, 0
+	for i := 0; i < 4; i++ {
+		_, _ = g.CallAPI(context.Background(), APIRequest{Action: "get_status"})
+	}
+	assert.Equal(t, 0, a.n)
+	assert.Equal(t, 4, b.n)
+}
+
+func TestCallerGroupFailover(t *testing.T) {
+	setConfig(t, func(c *Config) { c.APIBalance = BalanceRoundRobin })
+	a, b := &countCaller{err: errors.New("closed")}, &countCaller{}
+	g := &CallerGroup{members: []*groupMember{{caller: a}, {caller: b}}}
+	for i := 0; i < 6; i++ {
+		_, err := g.CallAPI(context.Background(), APIRequest{Action: "get_status"})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 6, b.n)
+	// 连续失败 CallerMaxFailures 次后不再优先选择 a
+	assert.Equal(t, CallerMaxFailures, a.n)
+	assert.True(t, g.members[0].downUntil.After(g.members[1].downUntil))
+
+	b.err = errors.New("closed")
+	_, err := g.CallAPI(context.Background(), APIRequest{Action: "get_status"})
+	assert.Error(t, err)
+
+	_, err = (&CallerGroup{}).CallAPI(context.Background(), APIRequest{})
+	assert.ErrorIs(t, err, ErrNoCaller)
+
+	// 发送消息只在请求未发出时重试
+	a, b = &countCaller{err: context.DeadlineExceeded}, &countCaller{}
+	g = &CallerGroup{members: []*groupMember{{caller: a}, {caller: b}}}
+	_, err = g.CallAPI(context.Background(), APIRequest{Action: "send_msg"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, b.n)
+	a.err = fmt.Errorf("%w: broken pipe", ErrNotSent)
+	g.next = 0
+	_, err = g.CallAPI(context.Background(), APIRequest{Action: "send_msg"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, b.n)
+}
+
+func TestEventCallerGroup(t *testing.T) {
+	const id = 10002
+	a, b, other := &countCaller{}, &countCaller{}, &countCaller{}
+	AddCaller(id, a)
+	AddCaller(id, b)
+	defer RemoveCaller(id, a)
+	defer RemoveCaller(id, b)
+	g, _ := APICallers.Load(id)
+	assert.Equal(t, g, groupOf(id, a))
+	assert.Equal(t, other, groupOf(id, other))
+	assert.Equal(t, a, groupOf(id+1, a))
+}
@@ -0,0 +1,31 @@
+package zero
+
+import "sync/atomic"
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+//
+// 用于驱动在连接断开时只移除自己, 而不误删已被新连接替换的 APICaller
+func (m *callerMap) CompareAndDelete(key int64, old APICaller) (deleted bool) {
+	read, _ := m.read.Load().(readOnlyCallerMap)
+	e, ok := read.m[key]
+	if !ok && read.amended {
+		m.mu.Lock()
+		read, _ = m.read.Load().(readOnlyCallerMap)
+		e, ok = read.m[key]
+		if !ok && read.amended {
+			e, ok = m.dirty[key]
+			m.missLocked()
+		}
+		m.mu.Unlock()
+	}
+	for ok {
+		p := atomic.LoadPointer(&e.p)
+		if p == nil || p == expungedCallerMap || *(*APICaller)(p) != old {
+			return false
+		}
+		if atomic.CompareAndSwapPointer(&e.p, p, nil) {
+			return true
+		}
+	}
+	return false
+}
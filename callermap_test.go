@@ -0,0 +1,22 @@
+package zero
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallerMapCompareAndDelete(t *testing.T) {
+	var m callerMap
+	a, b := &messageLogger{}, &messageLogger{}
+	assert.False(t, m.CompareAndDelete(1, a))
+	m.Store(1, a)
+	m.Store(1, b)
+	assert.False(t, m.CompareAndDelete(1, a))
+	c, ok := m.Load(1)
+	assert.True(t, ok)
+	assert.Same(t, b, c)
+	assert.True(t, m.CompareAndDelete(1, b))
+	_, ok = m.Load(1)
+	assert.False(t, ok)
+}
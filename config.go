@@ -0,0 +1,326 @@
+package zero
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// DriverFactory 由配置文件中的驱动选项创建 Driver
+type DriverFactory func(options json.RawMessage) (Driver, error)
+
+var (
+	driverFactories   = map[string]DriverFactory{}
+	driverFactoriesMu sync.RWMutex
+)
+
+// RegisterDriver 注册配置文件中 drivers[].type 为 typ 的驱动,
+// 默认驱动在导入 driver 包时注册
+func RegisterDriver(typ string, f DriverFactory) {
+	driverFactoriesMu.Lock()
+	defer driverFactoriesMu.Unlock()
+	driverFactories[typ] = f
+}
+
+// ConfigEnvPrefix 覆盖配置文件的环境变量前缀
+//
+// 如 ZEROBOT_SUPER_USERS=1,2 ZEROBOT_MAX_PROCESS_TIME=1m ZEROBOT_LOG_LEVELS=*=info,ws=warn
+// 与 ZEROBOT_DRIVERS='[{"type":"ws","url":"ws://127.0.0.1:6700"}]'
+const ConfigEnvPrefix = "ZEROBOT_"
+
+// LoadConfig 按扩展名从 JSON, YAML 或 TOML 文件读取配置, 并应用环境变量覆盖
+//
+//	nickname: [椛椛]
+//	command_prefix: /
+//	super_users: [123456]
+//	max_process_time: 4m
+//	log_levels: {"*": info}
+//	drivers:
+//	  - type: ws
+//	    url: ws://127.0.0.1:6700
+//	    access_token: ""
+func LoadConfig(path string) (*Config, error) {
+	raw, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(raw, true)
+}
+
+// readConfig 读取配置文件并应用环境变量覆盖
+func readConfig(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, errors.New("zero: unknown config format " + path)
+	}
+	if err == nil { // 统一为 JSON 的类型, 如 TOML 的 []map[string]any
+		var b []byte
+		b, err = json.Marshal(raw)
+		if err == nil {
+			err = json.Unmarshal(b, &raw)
+		}
+	}
+	if err != nil {
+		return nil, errors.New("zero: parse " + path + ": " + err.Error())
+	}
+	if err = applyConfigEnv(raw, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// configField 配置文件中的键对应的 Config 字段
+func configField(key string) (reflect.StructField, bool) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == key {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// applyConfigEnv 以 ConfigEnvPrefix 开头的环境变量覆盖 raw 中的值
+func applyConfigEnv(raw map[string]any, lookup func(string) (string, bool)) error {
+	if v, ok := lookup(ConfigEnvPrefix + "DRIVERS"); ok {
+		var drivers []any
+		if err := json.Unmarshal([]byte(v), &drivers); err != nil {
+			return errors.New("zero: invalid " + ConfigEnvPrefix + "DRIVERS: " + err.Error())
+		}
+		raw["drivers"] = drivers
+	}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		env := ConfigEnvPrefix + strings.ToUpper(key)
+		v, ok := lookup(env)
+		if !ok {
+			continue
+		}
+		val, err := parseEnvValue(t.Field(i).Type, v)
+		if err != nil {
+			return errors.New("zero: invalid " + env + ": " + err.Error())
+		}
+		raw[key] = val
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parseEnvValue 按字段类型解析环境变量, 切片以 , 分隔, map 为 k=v,k=v
+func parseEnvValue(t reflect.Type, v string) (any, error) {
+	if t == durationType {
+		return v, nil
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		items := []any{}
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			item, err := parseEnvValue(t.Elem(), s)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case reflect.Map:
+		m := map[string]any{}
+		for _, s := range strings.Split(v, ",") {
+			k, val, ok := strings.Cut(strings.TrimSpace(s), "=")
+			if !ok {
+				return nil, errors.New("expect key=value, got " + strconv.Quote(s))
+			}
+			m[k] = val
+		}
+		return m, nil
+	case reflect.Bool:
+		return strconv.ParseBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(v, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(v, 10, 64)
+	}
+	return v, nil
+}
+
+// parseConfig 将解析后的配置转为 Config, 时长可为 "4m" 或纳秒数
+//
+// buildDrivers 为 false 时不创建驱动, 用于热更新 (驱动可能打开文件或注册 OnShutdown)
+func parseConfig(raw map[string]any, buildDrivers bool) (*Config, error) {
+	var drivers []any
+	if d, ok := raw["drivers"]; ok {
+		drivers, ok = d.([]any)
+		if !ok {
+			return nil, errors.New("zero: drivers must be a list")
+		}
+		delete(raw, "drivers")
+	}
+	for key, v := range raw {
+		f, ok := configField(key)
+		if !ok {
+			return nil, errors.New("zero: unknown config key " + strconv.Quote(key))
+		}
+		if s, ok := v.(string); ok && f.Type == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, errors.New("zero: invalid " + key + ": " + err.Error())
+			}
+			raw[key] = int64(d)
+		}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, errors.New("zero: parse config: " + err.Error())
+	}
+	if !buildDrivers {
+		return c, nil
+	}
+	for i, d := range drivers {
+		drv, err := newDriver(d)
+		if err != nil {
+			return nil, errors.New("zero: drivers[" + strconv.Itoa(i) + "]: " + err.Error())
+		}
+		c.Driver = append(c.Driver, drv)
+	}
+	return c, nil
+}
+
+func newDriver(d any) (Driver, error) {
+	m, ok := d.(map[string]any)
+	if !ok {
+		return nil, errors.New("driver must be a map")
+	}
+	typ, _ := m["type"].(string)
+	driverFactoriesMu.RLock()
+	f, ok := driverFactories[typ]
+	driverFactoriesMu.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown driver type " + strconv.Quote(typ) + ", forgot to import driver?")
+	}
+	options, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return f(options)
+}
+
+var (
+	configHooks   []func(old, new Config)
+	configHooksMu sync.Mutex
+)
+
+// OnConfigChange 注册 ReloadConfig 后的回调, old 与 new 为重新加载前后的配置
+func OnConfigChange(f func(old, new Config)) {
+	configHooksMu.Lock()
+	defer configHooksMu.Unlock()
+	configHooks = append(configHooks, f)
+}
+
+// ReloadConfig 在运行时应用 c 中可热更新的配置并调用 OnConfigChange 的回调
+//
+// 可热更新: NickName, CommandPrefix, SuperUsers, MaxProcessTime, MarkMessage,
+// KeepAtMeMessage, AddSpaceAfterAt, APIBalance, LogLevels; 其余字段 (如 Driver, RingLen, Latency) 需重启生效.
+// 回调在释放锁后调用, 其中可以再次调用 OnConfigChange 或 ReloadConfig
+func ReloadConfig(c *Config) {
+	configHooksMu.Lock()
+	old := *GetConfig()
+	next := old
+	next.NickName = c.NickName
+	next.CommandPrefix = c.CommandPrefix
+	next.SuperUsers = c.SuperUsers
+	next.MaxProcessTime = c.MaxProcessTime
+	if next.MaxProcessTime == 0 {
+		next.MaxProcessTime = time.Minute * 4
+	}
+	next.MarkMessage = c.MarkMessage
+	next.KeepAtMeMessage = c.KeepAtMeMessage
+	next.AddSpaceAfterAt = c.AddSpaceAfterAt
+	next.APIBalance = c.APIBalance
+	next.LogLevels = c.LogLevels
+	botConfig.Store(&next)
+	hooks := configHooks[:len(configHooks):len(configHooks)]
+	configHooksMu.Unlock()
+	Log("bot").Info("已重新加载配置")
+	for _, f := range hooks {
+		f(old, next)
+	}
+}
+
+// ReloadConfigFile 重新读取 path 并调用 ReloadConfig, 可用于 admin.Server.Reload
+//
+// 驱动需重启生效, 因此不会创建文件中的驱动
+func ReloadConfigFile(path string) error {
+	raw, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	c, err := parseConfig(raw, false)
+	if err != nil {
+		return err
+	}
+	ReloadConfig(c)
+	return nil
+}
+
+// WatchConfig 每隔 interval 检查 path 的修改时间, 变化时重新加载配置, 调用 stop 停止
+func WatchConfig(path string, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var last time.Time
+	if st, err := os.Stat(path); err == nil {
+		last = st.ModTime()
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+			st, err := os.Stat(path)
+			if err != nil || st.ModTime().Equal(last) {
+				continue
+			}
+			last = st.ModTime()
+			if err = ReloadConfigFile(path); err != nil {
+				Log("bot").Error("重新加载配置失败", F("path", path), F(FieldError, err))
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
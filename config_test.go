@@ -0,0 +1,172 @@
+package zero
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDriver struct {
+	URL string `json:"url"`
+}
+
+func (fakeDriver) Connect()                       {}
+func (fakeDriver) Listen(func([]byte, APICaller)) {}
+
+func init() {
+	RegisterDriver("fake", func(options json.RawMessage) (Driver, error) {
+		var d fakeDriver
+		err := json.Unmarshal(options, &d)
+		return d, err
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml": "nickname: [椛椛]\nsuper_users: [1, 2]\nmax_process_time: 1m\nlog_levels: {\"*\": warn}\ndrivers:\n  - type: fake\n    url: ws://a\n",
+		"a.toml": "nickname = [\"椛椛\"]\nsuper_users = [1, 2]\nmax_process_time = \"1m\"\n[log_levels]\n\"*\" = \"warn\"\n[[drivers]]\ntype = \"fake\"\nurl = \"ws://a\"\n",
+		"a.json": `{"nickname":["椛椛"],"super_users":[1,2],"max_process_time":60000000000,"log_levels":{"*":"warn"},"drivers":[{"type":"fake","url":"ws://a"}]}`,
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+		c, err := LoadConfig(p)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.Equal(t, []string{"椛椛"}, c.NickName, name)
+		assert.Equal(t, []int64{1, 2}, c.SuperUsers, name)
+		assert.Equal(t, time.Minute, c.MaxProcessTime, name)
+		assert.Equal(t, map[string]Level{"*": LevelWarn}, c.LogLevels, name)
+		assert.Equal(t, []Driver{fakeDriver{URL: "ws://a"}}, c.Driver, name)
+	}
+
+	p := filepath.Join(dir, "bad.yaml")
+	assert.NoError(t, os.WriteFile(p, []byte("drivers: [{type: none}]"), 0o644))
+	_, err := LoadConfig(p)
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(p, []byte("no_such_key: 1"), 0o644))
+	_, err = LoadConfig(p)
+	assert.Error(t, err)
+}
+
+func TestConfigEnv(t *testing.T) {
+	env := map[string]string{
+		"ZEROBOT_SUPER_USERS":      "3, 4",
+		"ZEROBOT_COMMAND_PREFIX":   "#",
+		"ZEROBOT_MAX_PROCESS_TIME": "30s",
+		"ZEROBOT_MARK_MESSAGE":     "true",
+		"ZEROBOT_LOG_LEVELS":       "*=info,ws=error",
+		"ZEROBOT_DRIVERS":          `[{"type":"fake","url":"ws://b"}]`,
+	}
+	raw := map[string]any{"super_users": []any{1}}
+	assert.NoError(t, applyConfigEnv(raw, func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}))
+	c, err := parseConfig(raw, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 4}, c.SuperUsers)
+	assert.Equal(t, "#", c.CommandPrefix)
+	assert.Equal(t, 30*time.Second, c.MaxProcessTime)
+	assert.True(t, c.MarkMessage)
+	assert.Equal(t, map[string]Level{"*": LevelInfo, "ws": LevelError}, c.LogLevels)
+	assert.Equal(t, []Driver{fakeDriver{URL: "ws://b"}}, c.Driver)
+
+	assert.Error(t, applyConfigEnv(map[string]any{}, func(k string) (string, bool) {
+		return "x", k == "ZEROBOT_MARK_MESSAGE"
+	}))
+}
+
+func TestReloadConfigFileDrivers(t *testing.T) {
+	built := 0
+	RegisterDriver("fake_counted", func(json.RawMessage) (Driver, error) {
+		built++
+		return fakeDriver{}, nil
+	})
+	setConfig(t, func(*Config) {})
+	p := filepath.Join(t.TempDir(), "c.json")
+	assert.NoError(t, os.WriteFile(p, []byte(`{"command_prefix":"!","drivers":[{"type":"fake_counted"}]}`), 0o644))
+	// 热更新不创建驱动
+	assert.NoError(t, ReloadConfigFile(p))
+	assert.Equal(t, 0, built)
+	assert.Equal(t, "!", GetConfig().CommandPrefix)
+	_, err := LoadConfig(p)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, built)
+}
+
+func TestReloadConfigReentrant(t *testing.T) {
+	setConfig(t, func(*Config) {})
+	// 回调中注册回调与重新加载配置不会死锁
+	nested := 0
+	once := false
+	OnConfigChange(func(_, n Config) {
+		if n.CommandPrefix != "reentrant" || once {
+			return
+		}
+		once = true
+		OnConfigChange(func(Config, Config) { nested++ })
+		ReloadConfig(&Config{CommandPrefix: "reentrant2"})
+	})
+	done := make(chan struct{})
+	go func() {
+		ReloadConfig(&Config{CommandPrefix: "reentrant"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ReloadConfig deadlocked")
+	}
+	assert.Equal(t, 1, nested)
+	assert.Equal(t, "reentrant2", GetConfig().CommandPrefix)
+}
+
+// setConfig 在测试期间以 f 修改配置, 结束时恢复
+func setConfig(t *testing.T, f func(c *Config)) {
+	old := GetConfig()
+	c := *old
+	f(&c)
+	botConfig.Store(&c)
+	t.Cleanup(func() { botConfig.Store(old) })
+}
+
+func TestReloadConfig(t *testing.T) {
+	setConfig(t, func(c *Config) { c.RingLen = 16 })
+	old := *GetConfig()
+	var mu sync.Mutex
+	var got [2]Config
+	OnConfigChange(func(o, n Config) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = [2]Config{o, n}
+	})
+	ReloadConfig(&Config{SuperUsers: []int64{9}, CommandPrefix: "/", RingLen: 1})
+	c := GetConfig()
+	assert.Equal(t, []int64{9}, c.SuperUsers)
+	assert.Equal(t, "/", c.CommandPrefix)
+	assert.Equal(t, uint(16), c.RingLen)
+	assert.Equal(t, 4*time.Minute, c.MaxProcessTime)
+	assert.Equal(t, []int64{9}, got[1].SuperUsers)
+	assert.Equal(t, old.SuperUsers, got[0].SuperUsers)
+
+	p := filepath.Join(t.TempDir(), "c.json")
+	assert.NoError(t, os.WriteFile(p, []byte(`{"super_users":[7]}`), 0o644))
+	stop := WatchConfig(p, 10*time.Millisecond)
+	defer stop()
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, os.WriteFile(p, []byte(`{"super_users":[8]}`), 0o644))
+	assert.NoError(t, os.Chtimes(p, time.Now().Add(time.Second), time.Now().Add(time.Second)))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got[1].SuperUsers) == 1 && got[1].SuperUsers[0] == 8
+	}, time.Second, 10*time.Millisecond)
+}
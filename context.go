@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/wdvxdr1123/ZeroBot/message"
@@ -16,6 +17,7 @@ type Ctx struct {
 	Event  *Event
 	State  State
 	caller APICaller
+	c      atomic.Value // ctxHolder, 追踪等请求范围的信息, match 切换 Matcher 时与 Handler 并发读写
 
 	// lazy message
 	once    sync.Once
@@ -150,7 +152,7 @@ func (ctx *Ctx) Send(msg any) message.ID {
 
 // SendChain 快捷发送消息/合并转发-消息链
 func (ctx *Ctx) SendChain(msg ...message.Segment) message.ID {
-	if BotConfig.AddSpaceAfterAt && len(msg) > 0 {
+	if GetConfig().AddSpaceAfterAt && len(msg) > 0 {
 		newMsg := make(message.Message, 0, len(msg)*2)
 		for i := 0; i < len(msg)-1; i++ {
 			newMsg = append(newMsg, msg[i])
@@ -170,10 +172,10 @@ func (ctx *Ctx) SendChain(msg ...message.Segment) message.ID {
 
 // Echo 向自身分发虚拟事件
 func (ctx *Ctx) Echo(response []byte) {
-	if BotConfig.RingLen != 0 {
+	if c := GetConfig(); c.RingLen != 0 {
 		evring.processEvent(response, ctx.caller)
 	} else {
-		processEventAsync(response, ctx.caller, BotConfig.MaxProcessTime)
+		processEventAsync(response, ctx.caller, c.MaxProcessTime)
 	}
 }
 
@@ -0,0 +1,223 @@
+package driver
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxAuthFailures 同一 IP 连续鉴权失败的默认上限
+	DefaultMaxAuthFailures = 5
+	// DefaultAuthBanTime 超过失败上限后拒绝该 IP 的默认时长
+	DefaultAuthBanTime = 10 * time.Minute
+	// DefaultMaxMessageSize 单条 WebSocket 消息或 HTTP 请求体的默认最大字节数
+	DefaultMaxMessageSize = 32 << 20
+)
+
+// maxTrackedIPs 记录鉴权失败的 IP 数超过时清理过期记录
+const maxTrackedIPs = 1024
+
+// ServerAuth 服务端驱动 (WSServer, HTTP) 的鉴权与限制, 零值使用默认限制
+//
+// 驱动的 AccessToken 等价于 Tokens 中允许任意账号的一项; 未设置任何 token 时不鉴权
+type ServerAuth struct {
+	// Tokens token 到允许连接的账号, 列表为空时允许任意账号; HTTP 上报时 token 为签名密钥
+	Tokens          map[string][]int64 `json:"tokens"`
+	AllowIPs        []string           `json:"allow_ips"`         // IP 或 CIDR 白名单, 为空时不限制
+	AllowQueryToken bool               `json:"allow_query_token"` // 接受 ?access_token=, 默认只接受 Authorization 头
+	MaxFailures     int                `json:"max_failures"`      // 同一 IP 连续鉴权失败的上限, 0 为 DefaultMaxAuthFailures, 负数不限制
+	BanSeconds      int                `json:"ban_seconds"`       // 超过上限后拒绝该 IP 的秒数, 0 为 DefaultAuthBanTime
+	MaxMessageSize  int64              `json:"max_message_size"`  // 0 为 DefaultMaxMessageSize, 负数不限制
+
+	once sync.Once
+	nets []*net.IPNet
+	err  error
+
+	mu       sync.Mutex
+	failures map[string]*authFailure
+}
+
+type authFailure struct {
+	n     int
+	last  time.Time
+	until time.Time
+}
+
+// Init 解析 AllowIPs, 出错时拒绝所有请求
+func (a *ServerAuth) Init() error {
+	a.once.Do(func() {
+		for _, s := range a.AllowIPs {
+			s = strings.TrimSpace(s)
+			if !strings.Contains(s, "/") {
+				ip := net.ParseIP(s)
+				if ip == nil {
+					a.err = errors.New("driver: invalid allow_ips entry " + s)
+					return
+				}
+				bits := 8 * net.IPv4len
+				if ip.To4() == nil {
+					bits = 8 * net.IPv6len
+				}
+				a.nets = append(a.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				a.err = errors.New("driver: invalid allow_ips entry " + s)
+				return
+			}
+			a.nets = append(a.nets, n)
+		}
+	})
+	return a.err
+}
+
+func (a *ServerAuth) banTime() time.Duration {
+	if a.BanSeconds > 0 {
+		return time.Duration(a.BanSeconds) * time.Second
+	}
+	return DefaultAuthBanTime
+}
+
+func (a *ServerAuth) messageSize() int64 {
+	if a.MaxMessageSize == 0 {
+		return DefaultMaxMessageSize
+	}
+	return a.MaxMessageSize
+}
+
+// remoteIP 返回请求的来源 IP, unix socket 等无 IP 时为空
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// admit 检查 IP 白名单与失败次数, 返回 http.StatusOK 表示放行
+func (a *ServerAuth) admit(ip string) int {
+	if a.Init() != nil {
+		return http.StatusForbidden
+	}
+	if len(a.nets) > 0 {
+		addr := net.ParseIP(ip)
+		allowed := false
+		for _, n := range a.nets {
+			if addr != nil && n.Contains(addr) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return http.StatusForbidden
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if f, ok := a.failures[ip]; ok && time.Now().Before(f.until) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusOK
+}
+
+// fail 记录 ip 的一次鉴权失败
+func (a *ServerAuth) fail(ip string) {
+	max := a.MaxFailures
+	if max == 0 {
+		max = DefaultMaxAuthFailures
+	}
+	if max < 0 {
+		return
+	}
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.failures == nil {
+		a.failures = map[string]*authFailure{}
+	}
+	if len(a.failures) >= maxTrackedIPs {
+		for k, f := range a.failures {
+			if now.After(f.until) && now.Sub(f.last) > a.banTime() {
+				delete(a.failures, k)
+			}
+		}
+	}
+	f, ok := a.failures[ip]
+	if !ok {
+		f = &authFailure{}
+		a.failures[ip] = f
+	}
+	f.n++
+	f.last = now
+	if f.n >= max {
+		f.n = 0
+		f.until = now.Add(a.banTime())
+	}
+}
+
+// succeed 鉴权成功后清除 ip 的失败记录
+func (a *ServerAuth) succeed(ip string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.failures, ip)
+}
+
+// match 返回使 equal 成立的 token 允许的账号, accessToken 允许任意账号;
+// 未设置任何 token 时总是成功
+func (a *ServerAuth) match(accessToken string, equal func(token string) bool) (ids []int64, ok bool) {
+	if accessToken == "" && len(a.Tokens) == 0 {
+		return nil, true
+	}
+	// 比较所有 token, 耗时与匹配位置无关
+	if accessToken != "" && equal(accessToken) {
+		ok = true
+	}
+	for token, allowed := range a.Tokens {
+		if equal(token) && !ok {
+			ids, ok = allowed, true
+		}
+	}
+	return
+}
+
+// checkToken 校验 Authorization 头 (或开启 AllowQueryToken 时的 access_token 参数)
+func (a *ServerAuth) checkToken(r *http.Request, accessToken string) ([]int64, int) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		if a.AllowQueryToken {
+			auth = r.URL.Query().Get("access_token")
+		}
+	} else if _, after, ok := strings.Cut(auth, " "); ok {
+		auth = after
+	}
+	ids, ok := a.match(accessToken, func(token string) bool {
+		return subtle.ConstantTimeCompare([]byte(auth), []byte(token)) == 1
+	})
+	switch {
+	case ok:
+		return ids, http.StatusOK
+	case auth == "":
+		return nil, http.StatusUnauthorized
+	default:
+		return nil, http.StatusForbidden
+	}
+}
+
+// permitted 判断 ids 是否允许账号 selfID, ids 为空时允许任意账号
+func permitted(ids []int64, selfID int64) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, id := range ids {
+		if id == selfID {
+			return true
+		}
+	}
+	return false
+}
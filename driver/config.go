@@ -0,0 +1,198 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/extension/filter"
+)
+
+// options 配置文件中的驱动选项
+type options struct {
+	URL         string      `json:"url"`
+	AccessToken string      `json:"access_token"`
+	WaitN       int         `json:"wait_n"`       // 反向 WS 等待连接队列长度, 默认 16
+	CallerURL   string      `json:"caller_url"`   // HTTP 调用 API 的地址
+	CallerToken string      `json:"caller_token"` // HTTP 调用 API 的 access token
+	TLS         *TLSConfig  `json:"tls"`          // 监听或连接使用的 TLS 配置
+	CallerTLS   *TLSConfig  `json:"caller_tls"`   // HTTP 调用 API 使用的 TLS 配置
+	Auth        *ServerAuth `json:"auth"`         // 反向 WS 与 HTTP 上报的鉴权与限制
+}
+
+// wrapOptions 所有驱动共用的录制与转发选项
+type wrapOptions struct {
+	Record string        `json:"record"` // 不为空时将事件与 API 调用录制到该文件
+	Relay  *relayOptions `json:"relay"`  // 不为 nil 时为下游 bot 转发事件与 API 调用
+}
+
+// relayOptions 转发选项
+type relayOptions struct {
+	URL         string              `json:"url"`
+	AccessToken string              `json:"access_token"`
+	TLS         *TLSConfig          `json:"tls"`
+	Auth        *ServerAuth         `json:"auth"`
+	Post        []string            `json:"post"`
+	Filter      map[string][]string `json:"filter"`    // 字段到允许的值, 所有字段均满足时才转发
+	Timeout     float64             `json:"timeout"`   // 转发 API 调用的超时秒数
+	Heartbeat   float64             `json:"heartbeat"` // 心跳事件的间隔秒数, 负数不发送
+}
+
+// relayFilter 由 filter 选项构造过滤器
+func relayFilter(fields map[string][]string) filter.Func {
+	if len(fields) == 0 {
+		return nil
+	}
+	filters := make([]filter.Func, 0, len(fields))
+	for k, values := range fields {
+		anyOf := make([]filter.Func, 0, len(values))
+		for _, v := range values {
+			anyOf = append(anyOf, filter.Equal(v))
+		}
+		filters = append(filters, filter.NewField(k).Any(anyOf...))
+	}
+	return filter.And(filters...)
+}
+
+// wrap 按 record 与 relay 选项包装 f 创建的驱动
+func wrap(f zero.DriverFactory) zero.DriverFactory {
+	return func(data json.RawMessage) (zero.Driver, error) {
+		var o wrapOptions
+		if err := json.Unmarshal(data, &o); err != nil {
+			return nil, err
+		}
+		d, err := f(data)
+		if err != nil {
+			return nil, err
+		}
+		if o.Record != "" {
+			file, err := os.OpenFile(o.Record, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				return nil, err
+			}
+			r := NewRecorder(d, file)
+			zero.OnShutdown(func() { _ = r.Close() })
+			d = r
+		}
+		if ro := o.Relay; ro != nil {
+			if ro.Auth != nil {
+				if err = ro.Auth.Init(); err != nil {
+					return nil, err
+				}
+			}
+			r := NewRelay(d, ro.URL, ro.AccessToken)
+			r.TLS = ro.TLS
+			r.Auth = ro.Auth
+			r.Post = ro.Post
+			r.Filter = relayFilter(ro.Filter)
+			r.Timeout = time.Duration(ro.Timeout * float64(time.Second))
+			r.Heartbeat = time.Duration(ro.Heartbeat * float64(time.Second))
+			d = r
+		}
+		return d, nil
+	}
+}
+
+// register 注册驱动, 支持 record 与 relay 选项
+func register(typ string, f zero.DriverFactory) {
+	zero.RegisterDriver(typ, wrap(f))
+}
+
+func parseOptions(data json.RawMessage) (o options, err error) {
+	err = json.Unmarshal(data, &o)
+	if err == nil && o.Auth != nil {
+		err = o.Auth.Init()
+	}
+	return
+}
+
+func init() {
+	ws := func(data json.RawMessage) (zero.Driver, error) {
+		o, err := parseOptions(data)
+		if err != nil {
+			return nil, err
+		}
+		c := NewWebSocketClient(o.URL, o.AccessToken)
+		c.TLS = o.TLS
+		return c, nil
+	}
+	wss := func(data json.RawMessage) (zero.Driver, error) {
+		o, err := parseOptions(data)
+		if err != nil {
+			return nil, err
+		}
+		if o.WaitN <= 0 {
+			o.WaitN = 16
+		}
+		s := NewWebSocketServer(o.WaitN, o.URL, o.AccessToken)
+		s.TLS = o.TLS
+		s.Auth = o.Auth
+		return s, nil
+	}
+	register("ws", ws)
+	register("wsclient", ws)
+	register("wss", wss)
+	register("wsserver", wss)
+	register("console", func(data json.RawMessage) (zero.Driver, error) {
+		c := &Console{}
+		return c, json.Unmarshal(data, c)
+	})
+	zero.RegisterDriver("replay", func(data json.RawMessage) (zero.Driver, error) {
+		var o struct {
+			Path  string  `json:"path"`
+			Speed float64 `json:"speed"`
+		}
+		if err := json.Unmarshal(data, &o); err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(o.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewReplay(bytes.NewReader(b), o.Speed), nil
+	})
+	register("poll", func(data json.RawMessage) (zero.Driver, error) {
+		o, err := parseOptions(data)
+		if err != nil {
+			return nil, err
+		}
+		var po struct {
+			Action   string  `json:"action"`
+			Limit    int     `json:"limit"`
+			Timeout  float64 `json:"timeout"`  // 长轮询超时秒数, 负数不使用长轮询
+			Interval float64 `json:"interval"` // 没有新事件时的最短轮询间隔秒数
+		}
+		if err = json.Unmarshal(data, &po); err != nil {
+			return nil, err
+		}
+		p := NewPoll(o.URL, o.AccessToken)
+		p.TLS = o.TLS
+		p.Action = po.Action
+		p.Limit = po.Limit
+		p.Timeout = time.Duration(po.Timeout * float64(time.Second))
+		p.Interval = time.Duration(po.Interval * float64(time.Second))
+		return p, nil
+	})
+	register("satori", func(data json.RawMessage) (zero.Driver, error) {
+		o, err := parseOptions(data)
+		if err != nil {
+			return nil, err
+		}
+		s := NewSatori(o.URL, o.AccessToken)
+		s.TLS = o.TLS
+		return s, nil
+	})
+	register("http", func(data json.RawMessage) (zero.Driver, error) {
+		o, err := parseOptions(data)
+		if err != nil {
+			return nil, err
+		}
+		h := NewHTTPClient(o.URL, o.AccessToken, o.CallerURL, o.CallerToken)
+		h.TLS = o.TLS
+		h.Auth = o.Auth
+		h.caller.TLS = o.CallerTLS
+		return h, nil
+	})
+}
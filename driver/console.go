@@ -0,0 +1,322 @@
+package driver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// consoleHelp 控制台驱动的指令说明
+const consoleHelp = `:user <id>      设置发送者 QQ
+:group <id>     设置群号, 0 为私聊
+:role <role>    设置群角色: member, admin, owner
+:name <name>    设置发送者昵称
+:at             切换是否在消息前 @bot
+:help           显示本帮助
+其余输入作为消息发送, 支持 CQ 码; 以 :: 开头的输入发送为以 : 开头的消息`
+
+// Console 本地调试驱动, 从 In 逐行读取消息事件, 并将 bot 的 API 调用打印到 Out
+//
+// 常见的信息类 API 返回由当前设置生成的假数据, Data 中的同名 action 优先
+type Console struct {
+	SelfID   int64                      `json:"self_id"`
+	UserID   int64                      `json:"user_id"`
+	GroupID  int64                      `json:"group_id"` // 0 为私聊
+	Role     string                     `json:"role"`     // member, admin 或 owner
+	Nickname string                     `json:"nickname"` // 发送者昵称
+	AtBot    bool                       `json:"at_bot"`   // 在消息前添加 @bot
+	Data     map[string]json.RawMessage `json:"data"`     // action 到返回的 data
+	In       io.Reader                  `json:"-"`        // 为 nil 时使用 os.Stdin
+	Out      io.Writer                  `json:"-"`        // 为 nil 时使用 os.Stdout
+
+	mu    sync.Mutex
+	msgID int64
+	msgs  map[int64]gjson.Result // 最近的消息, 供 get_msg 使用
+}
+
+// consoleMaxMessages get_msg 可查询的最近消息数
+const consoleMaxMessages = 256
+
+// NewConsole 使用 selfID 作为 bot, userID 作为发送者的控制台驱动
+func NewConsole(selfID, userID int64) *Console {
+	return &Console{SelfID: selfID, UserID: userID}
+}
+
+func (c *Console) out() io.Writer {
+	if c.Out == nil {
+		return os.Stdout
+	}
+	return c.Out
+}
+
+func (c *Console) printf(format string, a ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = fmt.Fprintf(c.out(), format, a...)
+}
+
+// Connect 登记控制台账号
+func (c *Console) Connect() {
+	if c.SelfID == 0 {
+		c.SelfID = 10000
+	}
+	if c.UserID == 0 {
+		c.UserID = 10001
+	}
+	if c.Role == "" {
+		c.Role = "member"
+	}
+	if c.Nickname == "" {
+		c.Nickname = "user"
+	}
+	zero.AddCaller(c.SelfID, c)
+	zero.Log("console").Info("控制台驱动已就绪, 输入 :help 查看指令", zero.F(zero.FieldSelfID, c.SelfID))
+}
+
+// Listen 逐行读取输入直到 EOF
+func (c *Console) Listen(handler func([]byte, zero.APICaller)) {
+	in := c.In
+	if in == nil {
+		in = os.Stdin
+	}
+	defer zero.RemoveCaller(c.SelfID, c)
+	s := bufio.NewScanner(in)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "::"):
+			line = line[1:]
+		case strings.HasPrefix(line, ":"):
+			c.command(line[1:])
+			continue
+		}
+		payload := c.event(line)
+		zero.Log("console").Debug("接收到事件", zero.F("payload", string(payload)))
+		handler(payload, c)
+	}
+	if err := s.Err(); err != nil {
+		zero.Log("console").Warn("读取输入失败", zero.F(zero.FieldError, err))
+	}
+}
+
+// command 处理 :cmd 形式的设置指令
+func (c *Console) command(line string) {
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := c.out()
+	parseID := func(dst *int64) {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			_, _ = fmt.Fprintln(out, "无效的 ID:", arg)
+			return
+		}
+		*dst = id
+	}
+	switch cmd {
+	case "user":
+		parseID(&c.UserID)
+	case "group":
+		parseID(&c.GroupID)
+	case "role":
+		switch arg {
+		case "member", "admin", "owner":
+			c.Role = arg
+		default:
+			_, _ = fmt.Fprintln(out, "无效的角色:", arg)
+			return
+		}
+	case "name":
+		c.Nickname = arg
+	case "at":
+		c.AtBot = !c.AtBot
+	case "help":
+		_, _ = fmt.Fprintln(out, consoleHelp)
+		return
+	default:
+		_, _ = fmt.Fprintln(out, "未知指令, 输入 :help 查看帮助")
+		return
+	}
+	target := "私聊"
+	if c.GroupID != 0 {
+		target = "群 " + strconv.FormatInt(c.GroupID, 10) + " (" + c.Role + ")"
+	}
+	_, _ = fmt.Fprintf(out, "当前: 用户 %d (%s) %s, @bot: %v\n", c.UserID, c.Nickname, target, c.AtBot)
+}
+
+// remember 记录消息以供 get_msg 查询, 返回消息 ID
+func (c *Console) remember(msg map[string]any) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgID++
+	id := c.msgID
+	msg["message_id"] = id
+	b, _ := json.Marshal(msg)
+	if c.msgs == nil {
+		c.msgs = map[int64]gjson.Result{}
+	}
+	c.msgs[id] = gjson.ParseBytes(b)
+	delete(c.msgs, id-consoleMaxMessages)
+	return id
+}
+
+// settings 当前的发送者设置, command 在 Listen 中修改, 需在锁内读取
+type consoleSettings struct {
+	userID, groupID int64
+	role, name      string
+	at              bool
+}
+
+func (c *Console) settings() consoleSettings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return consoleSettings{userID: c.UserID, groupID: c.GroupID, role: c.Role, name: c.Nickname, at: c.AtBot}
+}
+
+// event 由一行输入生成 OneBot 11 消息事件
+func (c *Console) event(line string) []byte {
+	st := c.settings()
+	userID, groupID, role, name, at := st.userID, st.groupID, st.role, st.name, st.at
+	if at {
+		line = message.Message{message.At(c.SelfID)}.CQString() + " " + line
+	}
+	sender := map[string]any{"user_id": userID, "nickname": name}
+	ev := map[string]any{
+		"time":        time.Now().Unix(),
+		"self_id":     c.SelfID,
+		"post_type":   "message",
+		"user_id":     userID,
+		"message":     line,
+		"raw_message": line,
+		"font":        0,
+		"sender":      sender,
+	}
+	if groupID != 0 {
+		sender["role"] = role
+		sender["card"] = ""
+		ev["message_type"] = "group"
+		ev["sub_type"] = "normal"
+		ev["group_id"] = groupID
+	} else {
+		ev["message_type"] = "private"
+		ev["sub_type"] = "friend"
+	}
+	c.remember(ev)
+	b, _ := json.Marshal(ev)
+	return b
+}
+
+// name 将 bot 与发送者的 QQ 渲染为昵称
+func (c *Console) name(id int64) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch id {
+	case c.SelfID:
+		return "bot"
+	case c.UserID:
+		return c.Nickname
+	}
+	return ""
+}
+
+// CallAPI 打印发出的消息与操作, 信息类 API 返回假数据
+func (c *Console) CallAPI(_ context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	params, _ := json.Marshal(req.Params)
+	p := gjson.ParseBytes(params)
+	var data any = map[string]any{}
+	switch req.Action {
+	case "send_msg", "send_group_msg", "send_private_msg":
+		msg := paramMessage(req.Params["message"])
+		groupID := p.Get("group_id").Int()
+		target := "私聊 " + p.Get("user_id").String()
+		if groupID != 0 {
+			target = "群 " + strconv.FormatInt(groupID, 10)
+		}
+		c.printf("[bot -> %s] %s\n", target, msg.Console(c.name))
+		ev := map[string]any{
+			"time": time.Now().Unix(), "message_type": "private", "user_id": c.SelfID,
+			"message": msg.CQString(), "raw_message": msg.CQString(),
+			"sender": map[string]any{"user_id": c.SelfID, "nickname": "bot"},
+		}
+		if groupID != 0 {
+			ev["message_type"] = "group"
+			ev["group_id"] = groupID
+		}
+		data = map[string]any{"message_id": c.remember(ev)}
+	case "get_msg":
+		c.mu.Lock()
+		msg, ok := c.msgs[p.Get("message_id").Int()]
+		c.mu.Unlock()
+		if !ok {
+			return zero.APIResponse{Status: "failed", RetCode: 100, Message: "message not found"}, nil
+		}
+		data = json.RawMessage(msg.Raw)
+	case "get_login_info":
+		data = map[string]any{"user_id": c.SelfID, "nickname": "bot"}
+	case "get_stranger_info", "get_friend_list":
+		st := c.settings()
+		user := map[string]any{"user_id": st.userID, "nickname": st.name, "sex": "unknown", "age": 0}
+		if req.Action == "get_friend_list" {
+			data = []any{user}
+		} else {
+			user["user_id"] = p.Get("user_id").Int()
+			data = user
+		}
+	case "get_group_info":
+		data = map[string]any{"group_id": p.Get("group_id").Int(), "group_name": "console", "member_count": 2, "max_member_count": 200}
+	case "get_group_list":
+		data = []any{map[string]any{"group_id": c.settings().groupID, "group_name": "console", "member_count": 2, "max_member_count": 200}}
+	case "get_group_member_info":
+		data = c.member(p.Get("group_id").Int(), p.Get("user_id").Int())
+	case "get_group_member_list":
+		groupID := p.Get("group_id").Int()
+		data = []any{c.member(groupID, c.SelfID), c.member(groupID, c.settings().userID)}
+	case "get_status":
+		data = map[string]any{"online": true, "good": true}
+	case "get_version_info":
+		data = map[string]any{"app_name": "zerobot-console", "app_version": "1.0.0", "protocol_version": "v11"}
+	case "can_send_image", "can_send_record":
+		data = map[string]any{"yes": true}
+	default:
+		c.printf("[bot %s] %s\n", req.Action, params)
+	}
+	b, _ := json.Marshal(data)
+	if d, ok := c.Data[req.Action]; ok {
+		b = d
+	}
+	return zero.APIResponse{Status: "ok", Data: gjson.ParseBytes(b)}, nil
+}
+
+// member 返回群成员信息, 发送者使用当前角色, 其余为 member
+func (c *Console) member(groupID, userID int64) map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	role, name := "member", "member"
+	switch userID {
+	case c.UserID:
+		role, name = c.Role, c.Nickname
+	case c.SelfID:
+		name = "bot"
+	}
+	return map[string]any{"group_id": groupID, "user_id": userID, "nickname": name, "card": "", "role": role}
+}
+
+// Status implements zero.DriverStatus
+func (c *Console) Status() zero.DriverState {
+	return zero.DriverState{Type: "console", Connected: true, SelfIDs: []int64{c.SelfID}}
+}
@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// syncBuffer 可并发读写的 bytes.Buffer
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestConsole(t *testing.T) {
+	e := zero.New()
+	defer e.Delete()
+	e.OnCommand("console_ping").Handle(func(ctx *zero.Ctx) {
+		ctx.Send("pong " + ctx.GetStrangerInfo(ctx.Event.UserID, false).Get("nickname").String())
+	})
+	e.OnCommand("console_admin", zero.AdminPermission).Handle(func(ctx *zero.Ctx) {
+		ctx.Send("admin")
+	})
+
+	in, w := io.Pipe()
+	out := &syncBuffer{}
+	c := &Console{SelfID: 20000, UserID: 20001, In: in, Out: out}
+	done := make(chan struct{})
+	go func() {
+		zero.RunAndBlock(&zero.Config{CommandPrefix: "/", Driver: []zero.Driver{c}}, nil)
+		close(done)
+	}()
+
+	send := func(lines ...string) {
+		_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+		assert.NoError(t, err)
+	}
+	wait := func(s string) {
+		assert.Eventually(t, func() bool { return strings.Contains(out.String(), s) }, time.Second, 10*time.Millisecond, out.String())
+	}
+
+	send(":name alice", "/console_ping")
+	wait("[bot -> 私聊 20001] pong alice")
+
+	// 普通成员不满足 AdminPermission, 修改角色后触发
+	send(":group 10", "/console_admin", ":role admin", "/console_admin")
+	wait("[bot -> 群 10] admin")
+	assert.Equal(t, 1, strings.Count(out.String(), "[bot -> 群 10] admin"))
+
+	_ = w.Close()
+	<-done
+	_, ok := zero.APICallers.Load(int64(20000))
+	assert.False(t, ok)
+}
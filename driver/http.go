@@ -13,9 +13,10 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 
 	zero "github.com/wdvxdr1123/ZeroBot"
@@ -23,35 +24,43 @@ import (
 )
 
 type HTTP struct {
-	URL         string
-	AccessToken string
-	lst         net.Listener
-	caller      *HTTPCaller
+	URL          string
+	AccessToken  string
+	QuickTimeout time.Duration // 大于 0 时启用快速操作, 为等待事件处理的最长时间, 可用 DefaultQuickTimeout
+	TLS          *TLSConfig    // 不为 nil 时以 TLS 监听, 可选校验客户端证书
+	Auth         *ServerAuth   // 鉴权与限制, Tokens 为签名密钥, 为 nil 时使用默认限制
+	lst          net.Listener
+	caller       *HTTPCaller
+	authOnce     sync.Once
 }
 
 func (h *HTTP) Connect() {
-	log.Infof("[httpcaller] 正在尝试与服务器握手: %s", h.caller.URL)
+	zero.Log("httpcaller").Info("正在尝试与服务器握手", zero.F("url", h.caller.URL))
 	c, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 	rsp, err := h.caller.CallAPI(c, zero.APIRequest{Action: "get_login_info", Params: nil})
 	if err != nil {
-		log.Warningf("[httpcaller] 与服务器握手失败: %s\n%v", h.caller.URL, err)
+		metricConnectErrors.With("http", h.caller.URL).Inc()
+		zero.Log("httpcaller").Warn("与服务器握手失败", zero.F("url", h.caller.URL), zero.F(zero.FieldError, err))
 		return
 	}
 	if rsp.RetCode == 0 {
 		h.caller.selfID = rsp.Data.Get("user_id").Int()
-		zero.APICallers.Store(h.caller.selfID, h.caller) // 添加Caller到 APICaller list...
-		log.Infof("[httpcaller] 与服务器 %s 握手成功, 账号: %d", h.caller.URL, h.caller.selfID)
+		zero.AddCaller(h.caller.selfID, h.caller) // 添加Caller到 APICaller list...
+		metricConnects.With("http", h.caller.URL).Inc()
+		zero.Log("httpcaller").Info("与服务器握手成功", zero.F("url", h.caller.URL), zero.F(zero.FieldSelfID, h.caller.selfID))
 	} else {
-		log.Warningf("[httpcaller] 与服务器握手失败: %s", h.caller.URL)
-		log.Warningf("[httpcaller] status:%s, retcode:%d, msg:%s, wording:%s", rsp.Status, rsp.RetCode, rsp.Message, rsp.Wording)
+		zero.Log("httpcaller").Warn("与服务器握手失败", zero.F("url", h.caller.URL), zero.F("status", rsp.Status),
+			zero.F("retcode", rsp.RetCode), zero.F("msg", rsp.Message), zero.F("wording", rsp.Wording))
 	}
 }
 
 type HTTPCaller struct {
 	URL         string
 	AccessToken string
+	TLS         *TLSConfig // https:// 请求的客户端配置, 为 nil 时使用 http.DefaultClient
 	selfID      int64
+	client      httpClient
 }
 
 func NewHTTPClient(url, accessToken, callerURL, callerToken string) *HTTP {
@@ -71,53 +80,110 @@ func (h *HTTP) listen() {
 	}
 
 	listener, err := net.Listen(network, address)
+	if err == nil {
+		listener, err = listenTLS(listener, h.TLS)
+	}
 	if err != nil {
-		log.Warningf("[httpserver] 服务器监听失败: %v", err)
+		zero.Log("httpserver").Warn("服务器监听失败", zero.F(zero.FieldError, err))
 		h.lst = nil
 		return
 	}
 
 	h.lst = listener
-	log.Infof("[httpserver] 服务器开始监听: %v", listener.Addr())
+	zero.Log("httpserver").Info("服务器开始监听", zero.F("addr", listener.Addr().String()))
 }
 
 // any 处理所有 API 请求
 func (h *HTTP) any(w http.ResponseWriter, r *http.Request, apiHandler func([]byte, zero.APICaller)) {
 	if r.Method != http.MethodPost {
-		log.Warningf("[httpserver] 已拒绝 %s 请求: 不支持的请求方法 %s", r.RemoteAddr, r.Method)
+		zero.Log("httpserver").Warn("已拒绝请求: 不支持的请求方法", zero.F("remote", r.RemoteAddr), zero.F("method", r.Method))
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 	if r.Header.Get("Content-Type") != "application/json" {
-		log.Warningf("[httpserver] 已拒绝 %s 请求: 不支持的 Content-Type %s", r.RemoteAddr, r.Header.Get("Content-Type"))
+		zero.Log("httpserver").Warn("已拒绝请求: 不支持的 Content-Type", zero.F("remote", r.RemoteAddr), zero.F("content_type", r.Header.Get("Content-Type")))
 		w.WriteHeader(http.StatusNotAcceptable)
 		return
 	}
-	content, err := io.ReadAll(r.Body)
+	a := h.auth()
+	ip := remoteIP(r)
+	if status := a.admit(ip); status != http.StatusOK {
+		zero.Log("httpserver").Warn("已拒绝请求: 来源受限", zero.F("remote", r.RemoteAddr), zero.F("code", status))
+		w.WriteHeader(status)
+		return
+	}
+	body := r.Body
+	if size := a.messageSize(); size > 0 {
+		body = http.MaxBytesReader(w, body, size)
+	}
+	content, err := io.ReadAll(body)
 	if err != nil {
-		log.Warningf("[httpserver] 已拒绝 %s 请求: 读取请求体失败: %s", r.RemoteAddr, err)
+		zero.Log("httpserver").Warn("已拒绝请求: 读取请求体失败", zero.F("remote", r.RemoteAddr), zero.F(zero.FieldError, err))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	if h.AccessToken != "" {
-		signatureHeader := r.Header.Get("X-Signature")
+	signatureHeader := r.Header.Get("X-Signature")
+	hexSig, _ := strings.CutPrefix(signatureHeader, "sha1=")
+	signature, _ := hex.DecodeString(hexSig)
+	ids, ok := a.match(h.AccessToken, func(secret string) bool {
+		mac := hmac.New(sha1.New, helper.StringToBytes(secret))
+		mac.Write(content)
+		return hmac.Equal(signature, mac.Sum(nil))
+	})
+	if !ok {
+		a.fail(ip)
 		if signatureHeader == "" {
-			log.Warningf("[httpserver] 已拒绝 %s 请求: 缺少签名", r.RemoteAddr)
+			zero.Log("httpserver").Warn("已拒绝请求: 缺少签名", zero.F("remote", r.RemoteAddr))
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
+		zero.Log("httpserver").Warn("已拒绝请求: 签名错误", zero.F("remote", r.RemoteAddr))
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if selfID := gjson.GetBytes(content, "self_id").Int(); !permitted(ids, selfID) {
+		a.fail(ip)
+		zero.Log("httpserver").Warn("已拒绝请求: 密钥不允许该账号", zero.F("remote", r.RemoteAddr), zero.F(zero.FieldSelfID, selfID))
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	a.succeed(ip)
 
-		mac := hmac.New(sha1.New, helper.StringToBytes(h.AccessToken))
-		mac.Write(content)
-		if signatureHeader != "sha1="+hex.EncodeToString(mac.Sum(nil)) {
-			log.Warningf("[httpserver] 已拒绝 %s 请求: 签名错误", r.RemoteAddr)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
+	if h.QuickTimeout <= 0 {
+		apiHandler(content, h.caller)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	q := newQuickCaller(h.caller, content)
+	apiHandler(content, q)
+	t := time.NewTimer(h.QuickTimeout)
+	select {
+	case <-q.done:
+	case <-t.C:
+	}
+	t.Stop()
+	op := q.finish()
+	if len(op) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(op)
+}
 
-	apiHandler(content, h.caller)
+func (h *HTTP) auth() *ServerAuth {
+	h.authOnce.Do(func() {
+		if h.Auth == nil {
+			h.Auth = &ServerAuth{}
+		}
+	})
+	return h.Auth
 }
 
 // Listen 监听 HTTP 请求
@@ -137,13 +203,14 @@ func (h *HTTP) Listen(handler func([]byte, zero.APICaller)) {
 			h.listen()
 			continue
 		}
-		log.Infof("[httpserver] 服务器开始处理: %v", h.lst.Addr())
+		zero.Log("httpserver").Info("服务器开始处理", zero.F("addr", h.lst.Addr().String()))
 		err := server.Serve(h.lst)
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Warningf("[httpserver] 服务器在端点 %s 失败: %s", h.lst.Addr(), err)
+			metricConnectErrors.With("httpserver", h.lst.Addr().String()).Inc()
+			zero.Log("httpserver").Warn("服务器在端点失败", zero.F("addr", h.lst.Addr().String()), zero.F(zero.FieldError, err))
 			h.lst = nil
 		} else if errors.Is(err, http.ErrServerClosed) {
-			log.Info("[httpserver] 服务器已关闭")
+			zero.Log("httpserver").Info("服务器已关闭")
 			return
 		}
 	}
@@ -165,7 +232,15 @@ func (c *HTTPCaller) httpCaller(ctx context.Context, action string, payload []by
 		header.Set("Authorization", "Bearer "+c.AccessToken)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	client, err := c.client.get(c.TLS)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return nil, fmt.Errorf("%w: %w", zero.ErrNotSent, err)
+	}
 	if err != nil {
 		return nil, err
 	}
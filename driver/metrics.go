@@ -0,0 +1,12 @@
+package driver
+
+import "github.com/wdvxdr1123/ZeroBot/utils/metrics"
+
+var (
+	metricConnects = metrics.NewCounterVec("zerobot_driver_connects_total",
+		"驱动成功建立连接的次数", "driver", "url")
+	metricDisconnects = metrics.NewCounterVec("zerobot_driver_disconnects_total",
+		"驱动连接断开的次数, 正向 WS 断开后会重连", "driver", "url")
+	metricConnectErrors = metrics.NewCounterVec("zerobot_driver_connect_errors_total",
+		"驱动连接或监听失败的次数", "driver", "url")
+)
@@ -0,0 +1,274 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// ob12Segments OneBot 12 消息段类型到 OneBot 11 的对应, 以及需改名的数据字段
+var ob12Segments = map[string]struct {
+	typ    string
+	fields map[string]string
+}{
+	"mention":     {"at", map[string]string{"user_id": "qq"}},
+	"mention_all": {"at", nil},
+	"image":       {"image", map[string]string{"file_id": "file"}},
+	"voice":       {"record", map[string]string{"file_id": "file"}},
+	"audio":       {"record", map[string]string{"file_id": "file"}},
+	"video":       {"video", map[string]string{"file_id": "file"}},
+	"file":        {"file", map[string]string{"file_id": "file"}},
+	"reply":       {"reply", map[string]string{"message_id": "id"}},
+}
+
+// ob12Details OneBot 12 通知与请求的 detail_type 到 OneBot 11 的对应, 以及 sub_type 的对应,
+// 未列出的 detail_type 与 sub_type 保持原样
+var ob12Details = map[string]struct {
+	typ      string
+	subTypes map[string]string
+}{
+	"notice.friend_increase":        {"friend_add", nil},
+	"notice.private_message_delete": {"friend_recall", nil},
+	"notice.group_member_increase":  {"group_increase", map[string]string{"join": "approve", "invite": "invite"}},
+	"notice.group_member_decrease":  {"group_decrease", map[string]string{"leave": "leave", "kick": "kick"}},
+	"notice.group_message_delete":   {"group_recall", nil},
+	"notice.group_admin_set":        {"group_admin", map[string]string{"": "set"}},
+	"notice.group_admin_unset":      {"group_admin", map[string]string{"": "unset"}},
+	"notice.group_member_ban":       {"group_ban", map[string]string{"": "ban"}},
+	"notice.group_member_unban":     {"group_ban", map[string]string{"": "lift_ban"}},
+	"request.new_friend":            {"friend", nil},
+	"request.join_group":            {"group", map[string]string{"": "add"}},
+	"request.group_invite":          {"group", map[string]string{"": "invite"}},
+}
+
+// ob12ID 将 OneBot 12 中字符串形式的 ID 转为数字, 非数字时保持原样
+func ob12ID(v gjson.Result) any {
+	if v.Type == gjson.Number {
+		return v.Int()
+	}
+	if id, err := strconv.ParseInt(v.Str, 10, 64); err == nil {
+		return id
+	}
+	return v.Str
+}
+
+// convertOB12Message 将 OneBot 12 消息段转为 OneBot 11 数组格式
+func convertOB12Message(msg gjson.Result) []map[string]any {
+	segs := []map[string]any{}
+	msg.ForEach(func(_, seg gjson.Result) bool {
+		typ := seg.Get("type").Str
+		data := map[string]any{}
+		seg.Get("data").ForEach(func(k, v gjson.Result) bool {
+			data[k.Str] = v.String()
+			return true
+		})
+		if m, ok := ob12Segments[typ]; ok {
+			for from, to := range m.fields {
+				if v, ok := data[from]; ok {
+					delete(data, from)
+					data[to] = v
+				}
+			}
+			if typ == "mention_all" {
+				data["qq"] = "all"
+			}
+			typ = m.typ
+		}
+		segs = append(segs, map[string]any{"type": typ, "data": data})
+		return true
+	})
+	return segs
+}
+
+// ConvertOneBot12 将 OneBot 12 事件转为 zero 处理的 OneBot 11 格式, 其余字段原样保留
+//
+// 已是 OneBot 11 格式 (含 post_type) 的事件不做修改
+func ConvertOneBot12(event []byte) []byte {
+	e := gjson.ParseBytes(event)
+	if e.Get("post_type").Exists() || !e.Get("type").Exists() {
+		return event
+	}
+	out := map[string]any{}
+	if json.Unmarshal(event, &out) != nil {
+		return event
+	}
+	typ, detail := e.Get("type").Str, e.Get("detail_type").Str
+	switch typ {
+	case "message":
+		out["message_type"] = detail
+	case "notice", "request":
+		sub := e.Get("sub_type").Str
+		if m, ok := ob12Details[typ+"."+detail]; ok {
+			detail = m.typ
+			if s, ok := m.subTypes[sub]; ok {
+				sub = s
+			}
+		}
+		if sub != "" || e.Get("sub_type").Exists() {
+			out["sub_type"] = sub
+		}
+		out[typ+"_type"] = detail
+	case "meta":
+		typ = "meta_event"
+		out["meta_event_type"] = detail
+	}
+	out["post_type"] = typ
+	out["time"] = int64(e.Get("time").Float())
+	out["self_id"] = ob12ID(e.Get("self.user_id"))
+	for _, k := range []string{"user_id", "group_id", "operator_id", "message_id"} {
+		if v := e.Get(k); v.Exists() {
+			out[k] = ob12ID(v)
+		}
+	}
+	// 机器人自身被移出群时 OneBot 11 使用 kick_me
+	if out["notice_type"] == "group_decrease" && out["sub_type"] == "kick" && out["user_id"] == out["self_id"] {
+		out["sub_type"] = "kick_me"
+	}
+	if typ == "message" {
+		out["message"] = convertOB12Message(e.Get("message"))
+		out["raw_message"] = e.Get("alt_message").Str
+		if _, ok := out["sender"]; !ok {
+			out["sender"] = map[string]any{"user_id": out["user_id"]}
+		}
+	}
+	for _, k := range []string{"id", "type", "detail_type", "self"} {
+		delete(out, k)
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return event
+	}
+	return b
+}
+
+// ob11Segments OneBot 11 消息段类型到 OneBot 12 的对应, 以及需改名的数据字段
+var ob11Segments = map[string]struct {
+	typ    string
+	fields map[string]string
+}{
+	"at":     {"mention", map[string]string{"qq": "user_id"}},
+	"image":  {"image", map[string]string{"file": "file_id"}},
+	"record": {"voice", map[string]string{"file": "file_id"}},
+	"video":  {"video", map[string]string{"file": "file_id"}},
+	"file":   {"file", map[string]string{"file": "file_id"}},
+	"reply":  {"reply", map[string]string{"id": "message_id"}},
+}
+
+// ob12Actions OneBot 11 action 到 OneBot 12 的对应, 未列出的 action 同名
+var ob12Actions = map[string]string{
+	"send_msg":          "send_message",
+	"send_private_msg":  "send_message",
+	"send_group_msg":    "send_message",
+	"delete_msg":        "delete_message",
+	"get_login_info":    "get_self_info",
+	"get_stranger_info": "get_user_info",
+}
+
+// convertOB11Message 将 OneBot 11 消息转为 OneBot 12 消息段
+//
+// 媒体段的 file 原样作为 file_id, 需实现端能够识别
+func convertOB11Message(msg message.Message) []map[string]any {
+	segs := make([]map[string]any, 0, len(msg))
+	for _, seg := range msg {
+		typ := seg.Type
+		data := make(map[string]any, len(seg.Data))
+		for k, v := range seg.Data {
+			data[k] = v
+		}
+		if m, ok := ob11Segments[typ]; ok {
+			for from, to := range m.fields {
+				if v, ok := data[from]; ok {
+					delete(data, from)
+					data[to] = v
+				}
+			}
+			typ = m.typ
+			if typ == "mention" && data["user_id"] == "all" {
+				typ = "mention_all"
+				delete(data, "user_id")
+			}
+		}
+		segs = append(segs, map[string]any{"type": typ, "data": data})
+	}
+	return segs
+}
+
+// ob12Request 将 OneBot 11 的 API 请求转为 OneBot 12 格式, ID 参数转为字符串
+func ob12Request(req zero.APIRequest) zero.APIRequest {
+	params := make(zero.Params, len(req.Params)+1)
+	for k, v := range req.Params {
+		params[k] = v
+	}
+	for _, k := range []string{"user_id", "group_id", "message_id"} {
+		if v, ok := params[k]; ok {
+			params[k] = fmt.Sprint(v)
+		}
+	}
+	if action, ok := ob12Actions[req.Action]; ok {
+		req.Action = action
+	}
+	if req.Action == "send_message" {
+		detail := "private"
+		typ, _ := params["message_type"].(string)
+		groupID, _ := params["group_id"].(string)
+		if typ == "group" || typ != "private" && groupID != "" && groupID != "0" {
+			detail = "group"
+			delete(params, "user_id")
+		} else {
+			delete(params, "group_id")
+		}
+		params["detail_type"] = detail
+		msg := message.Message{message.Text(params["message"])}
+		if escape, _ := params["auto_escape"].(bool); !escape {
+			msg = paramMessage(params["message"])
+		}
+		params["message"] = convertOB11Message(msg)
+		delete(params, "message_type")
+		delete(params, "auto_escape")
+	}
+	req.Params = params
+	return req
+}
+
+// ob12Data 将 OneBot 12 响应中的 ID 转为数字, user_name 作为 nickname
+func ob12Data(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, k := range []string{"user_id", "group_id", "message_id"} {
+			if s, ok := v[k].(string); ok {
+				if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+					v[k] = id
+				}
+			}
+		}
+		if name, ok := v["user_name"]; ok {
+			if _, ok := v["nickname"]; !ok {
+				v["nickname"] = name
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = ob12Data(v[i])
+		}
+	}
+	return v
+}
+
+// ob12Response 将 OneBot 12 的 API 响应转为 zero 使用的 OneBot 11 格式
+func ob12Response(rsp zero.APIResponse) zero.APIResponse {
+	var data any
+	if json.Unmarshal([]byte(rsp.Data.Raw), &data) != nil {
+		return rsp
+	}
+	b, err := json.Marshal(ob12Data(data))
+	if err != nil {
+		return rsp
+	}
+	rsp.Data = gjson.ParseBytes(b)
+	return rsp
+}
@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+func TestConvertOneBot12(t *testing.T) {
+	ev := ConvertOneBot12([]byte(`{"id":"e1","time":1700000000.5,"type":"message","detail_type":"group","sub_type":"",
+		"message_id":"42","user_id":"10001","group_id":"20001","alt_message":"hi @bot",
+		"message":[{"type":"text","data":{"text":"hi "}},{"type":"mention","data":{"user_id":"10000"}},
+		{"type":"mention_all","data":{}},{"type":"image","data":{"file_id":"abc"}},{"type":"reply","data":{"message_id":"41"}}],
+		"self":{"platform":"qq","user_id":"10000"}}`))
+	e := gjson.ParseBytes(ev)
+	assert.Equal(t, "message", e.Get("post_type").Str)
+	assert.Equal(t, "group", e.Get("message_type").Str)
+	assert.Equal(t, int64(1700000000), e.Get("time").Int())
+	assert.Equal(t, int64(10000), e.Get("self_id").Int())
+	assert.Equal(t, gjson.Number, e.Get("user_id").Type)
+	assert.Equal(t, int64(20001), e.Get("group_id").Int())
+	assert.Equal(t, int64(42), e.Get("message_id").Int())
+	assert.Equal(t, "hi @bot", e.Get("raw_message").Str)
+	assert.Equal(t, int64(10001), e.Get("sender.user_id").Int())
+	assert.Equal(t, "hi [CQ:at,qq=10000][CQ:at,qq=all][CQ:image,file=abc][CQ:reply,id=41]",
+		message.ParseMessageFromArray(e.Get("message")).String())
+	for _, k := range []string{"id", "type", "detail_type", "self"} {
+		assert.False(t, e.Get(k).Exists(), k)
+	}
+
+	ev = ConvertOneBot12([]byte(`{"type":"meta","detail_type":"heartbeat","self":{"user_id":"10000"}}`))
+	assert.Equal(t, "meta_event", gjson.GetBytes(ev, "post_type").Str)
+	assert.Equal(t, "heartbeat", gjson.GetBytes(ev, "meta_event_type").Str)
+
+	// 通知与请求的 detail_type 与 sub_type 按表转换, 未知的保持原样
+	for _, c := range []struct{ in, typ, sub string }{
+		{`"type":"notice","detail_type":"group_member_increase","sub_type":"join"`, "group_increase", "approve"},
+		{`"type":"notice","detail_type":"group_member_increase","sub_type":"invite"`, "group_increase", "invite"},
+		{`"type":"notice","detail_type":"group_member_decrease","sub_type":"leave"`, "group_decrease", "leave"},
+		{`"type":"notice","detail_type":"group_member_decrease","sub_type":"kick","user_id":"10000"`, "group_decrease", "kick_me"},
+		{`"type":"notice","detail_type":"group_member_decrease","sub_type":"kick","user_id":"10001"`, "group_decrease", "kick"},
+		{`"type":"notice","detail_type":"group_message_delete","sub_type":"recall"`, "group_recall", "recall"},
+		{`"type":"notice","detail_type":"private_message_delete","sub_type":""`, "friend_recall", ""},
+		{`"type":"notice","detail_type":"friend_increase","sub_type":""`, "friend_add", ""},
+		{`"type":"notice","detail_type":"group_admin_set","sub_type":""`, "group_admin", "set"},
+		{`"type":"notice","detail_type":"group_member_unban","sub_type":""`, "group_ban", "lift_ban"},
+		{`"type":"notice","detail_type":"qq.poke","sub_type":"x"`, "qq.poke", "x"},
+		{`"type":"request","detail_type":"new_friend","sub_type":""`, "friend", ""},
+		{`"type":"request","detail_type":"join_group","sub_type":""`, "group", "add"},
+	} {
+		ev = ConvertOneBot12([]byte(`{` + c.in + `,"self":{"user_id":"10000"}}`))
+		e = gjson.ParseBytes(ev)
+		typ := gjson.GetBytes([]byte(`{`+c.in+`}`), "type").Str
+		assert.Equal(t, c.typ, e.Get(typ+"_type").Str, c.in)
+		assert.Equal(t, c.sub, e.Get("sub_type").Str, c.in)
+	}
+
+	// OneBot 11 事件与无法解析的数据原样返回
+	ob11 := []byte(`{"post_type":"message","type":"x"}`)
+	assert.Equal(t, ob11, ConvertOneBot12(ob11))
+	assert.Equal(t, []byte(`not json`), ConvertOneBot12([]byte(`not json`)))
+}
+
+func TestOneBot12Request(t *testing.T) {
+	req := ob12Request(zero.APIRequest{Action: "send_msg", Params: zero.Params{
+		"message_type": "group", "group_id": int64(20001), "user_id": int64(10001),
+		"message": message.Message{message.Text("hi"), message.At(10001), message.AtAll(), message.Image("https://a/b.png")},
+	}})
+	assert.Equal(t, "send_message", req.Action)
+	b, _ := json.Marshal(req.Params)
+	assert.JSONEq(t, `{"detail_type":"group","group_id":"20001","message":[
+		{"type":"text","data":{"text":"hi"}},{"type":"mention","data":{"user_id":"10001"}},
+		{"type":"mention_all","data":{}},{"type":"image","data":{"file_id":"https://a/b.png"}}]}`, string(b))
+
+	req = ob12Request(zero.APIRequest{Action: "send_private_msg", Params: zero.Params{"user_id": int64(10001), "message": "[CQ:face,id=1]", "auto_escape": true}})
+	b, _ = json.Marshal(req.Params)
+	assert.JSONEq(t, `{"detail_type":"private","user_id":"10001","message":[{"type":"text","data":{"text":"[CQ:face,id=1]"}}]}`, string(b))
+
+	req = ob12Request(zero.APIRequest{Action: "delete_msg", Params: zero.Params{"message_id": int64(42)}})
+	assert.Equal(t, "delete_message", req.Action)
+	assert.Equal(t, "42", req.Params["message_id"])
+	assert.Equal(t, "get_self_info", ob12Request(zero.APIRequest{Action: "get_login_info"}).Action)
+	assert.Equal(t, "get_group_info", ob12Request(zero.APIRequest{Action: "get_group_info"}).Action)
+
+	rsp := ob12Response(zero.APIResponse{Data: gjson.Parse(`{"user_id":"10000","user_name":"bot","message_id":"x"}`)})
+	assert.Equal(t, int64(10000), rsp.Data.Get("user_id").Int())
+	assert.Equal(t, gjson.Number, rsp.Data.Get("user_id").Type)
+	assert.Equal(t, "bot", rsp.Data.Get("nickname").Str)
+	assert.Equal(t, "x", rsp.Data.Get("message_id").Str)
+	rsp = ob12Response(zero.APIResponse{Data: gjson.Parse(`[{"group_id":"1"},{"group_id":"2"}]`)})
+	assert.Equal(t, []int64{1, 2}, []int64{rsp.Data.Get("0.group_id").Int(), rsp.Data.Get("1.group_id").Int()})
+}
+
+func TestPollOneBot12(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		action := strings.TrimPrefix(r.URL.Path, "/")
+		mu.Lock()
+		calls = append(calls, action+" "+string(b))
+		mu.Unlock()
+		switch action {
+		case "get_login_info":
+			_, _ = w.Write([]byte(`{"status":"failed","retcode":10002}`))
+		case "get_self_info":
+			_, _ = w.Write([]byte(`{"status":"ok","retcode":0,"data":{"user_id":"40000","user_name":"bot"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"ok","retcode":0,"data":{"message_id":"7"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	p := NewPoll(srv.URL, "")
+	p.Connect()
+	defer zero.RemoveCaller(40000, p.callers[40000])
+	assert.True(t, p.ob12.Load())
+	assert.Equal(t, []int64{40000}, p.Status().SelfIDs)
+
+	// 不同账号使用各自的 APICaller 与 self
+	a := p.register(40000, json.RawMessage(`{"platform":"qq","user_id":"40000"}`))
+	b := p.register(40001, json.RawMessage(`{"platform":"qq","user_id":"40001"}`))
+	defer zero.RemoveCaller(40001, b)
+	assert.NotSame(t, a, b)
+	assert.Equal(t, []int64{40000, 40001}, p.Status().SelfIDs)
+
+	mu.Lock()
+	calls = nil
+	mu.Unlock()
+	rsp, err := b.CallAPI(context.Background(), zero.APIRequest{Action: "delete_msg", Params: zero.Params{"message_id": int64(7)}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), rsp.Data.Get("message_id").Int())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "delete_message", strings.Fields(calls[0])[0])
+	assert.JSONEq(t, `{"message_id":"7","self":{"platform":"qq","user_id":"40001"}}`, strings.Fields(calls[0])[1])
+}
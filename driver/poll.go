@@ -0,0 +1,226 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+const (
+	// DefaultPollAction 拉取事件的默认 action
+	DefaultPollAction = "get_latest_events"
+	// DefaultPollTimeout 长轮询的默认超时
+	DefaultPollTimeout = 30 * time.Second
+	// DefaultPollInterval 没有新事件时两次拉取的最短间隔
+	DefaultPollInterval = time.Second
+	// DefaultPollMaxBackoff 拉取失败后重试的最长等待
+	DefaultPollMaxBackoff = time.Minute
+)
+
+// Poll 主动轮询拉取事件的驱动, 用于只能向外发起 HTTP 请求的环境 (如 NAT 之后)
+//
+// 默认调用 OneBot 12 的 get_latest_events, 参数为 limit 与 timeout (秒);
+// 返回的 data 可为事件数组, 或 {"events": [...], "cursor": ...},
+// 后者的 cursor 会作为下次拉取的 cursor 参数.
+// 事件经 Convert 转换后交给 zero 处理. 实现端为 OneBot 12 时, send_msg, delete_msg,
+// get_login_info 等 API 调用转为对应的 OneBot 12 action, 其余 action 同名发送, ID 参数转为字符串
+type Poll struct {
+	URL         string
+	AccessToken string
+	TLS         *TLSConfig          // https:// 请求的客户端配置
+	Action      string              // 拉取事件的 action, 为空时使用 DefaultPollAction
+	Limit       int                 // 每次最多拉取的事件数, 0 为 100
+	Timeout     time.Duration       // 长轮询超时, 0 为 DefaultPollTimeout, 负数不使用长轮询
+	Interval    time.Duration       // 没有新事件时两次拉取的最短间隔, 0 为 DefaultPollInterval
+	MaxBackoff  time.Duration       // 拉取失败后重试的最长等待, 0 为 DefaultPollMaxBackoff
+	Convert     func([]byte) []byte // 事件格式转换, 为 nil 时使用 ConvertOneBot12
+
+	caller  *HTTPCaller
+	ob12    atomic.Bool // 实现端为 OneBot 12
+	cursor  json.RawMessage
+	callers map[int64]*pollCaller // 已登记到 APICallers 的账号
+}
+
+// pollCaller 账号 selfID 的 APICaller, 共用 Poll 的 HTTPCaller
+type pollCaller struct {
+	p      *Poll
+	selfID int64
+	self   atomic.Pointer[json.RawMessage] // OneBot 12 事件中的 self, 调用时作为参数
+}
+
+// CallAPI 实现端为 OneBot 12 时转换请求与响应
+func (c *pollCaller) CallAPI(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if !c.p.ob12.Load() {
+		return c.p.caller.CallAPI(ctx, req)
+	}
+	req = ob12Request(req)
+	if self := c.self.Load(); self != nil {
+		req.Params["self"] = *self
+	}
+	rsp, err := c.p.caller.CallAPI(ctx, req)
+	return ob12Response(rsp), err
+}
+
+// NewPoll 轮询 url 的 get_latest_events
+func NewPoll(url, accessToken string) *Poll {
+	return &Poll{URL: url, AccessToken: accessToken}
+}
+
+// Connect 获取账号信息并登记 APICaller
+func (p *Poll) Connect() {
+	p.caller = &HTTPCaller{URL: p.URL, AccessToken: p.AccessToken, TLS: p.TLS}
+	p.callers = map[int64]*pollCaller{}
+	zero.Log("poll").Info("正在尝试与服务器握手", zero.F("url", p.URL))
+	c, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	rsp, err := p.caller.CallAPI(c, zero.APIRequest{Action: "get_login_info"})
+	if err == nil && rsp.RetCode != 0 { // OneBot 12
+		rsp, err = p.caller.CallAPI(c, zero.APIRequest{Action: "get_self_info"})
+		if err == nil && rsp.RetCode == 0 {
+			p.ob12.Store(true)
+		}
+	}
+	if err != nil || rsp.RetCode != 0 {
+		metricConnectErrors.With("poll", p.URL).Inc()
+		zero.Log("poll").Warn("与服务器握手失败, 将从事件中获取账号", zero.F("url", p.URL), zero.F(zero.FieldError, err),
+			zero.F("retcode", rsp.RetCode))
+		return
+	}
+	id, _ := ob12ID(rsp.Data.Get("user_id")).(int64)
+	p.register(id, nil)
+	metricConnects.With("poll", p.URL).Inc()
+	zero.Log("poll").Info("与服务器握手成功", zero.F("url", p.URL), zero.F(zero.FieldSelfID, id))
+}
+
+// register 返回账号 id 的 APICaller, 首次出现时登记到 APICallers
+//
+// self 为 OneBot 12 事件中的 self 字段, 为 nil 时保持原值
+func (p *Poll) register(id int64, self json.RawMessage) zero.APICaller {
+	if id == 0 {
+		return p.caller
+	}
+	c, ok := p.callers[id]
+	if !ok {
+		c = &pollCaller{p: p, selfID: id}
+		p.callers[id] = c
+		zero.AddCaller(id, c)
+	}
+	if self != nil {
+		c.self.Store(&self)
+	}
+	return c
+}
+
+// pull 拉取一次事件
+func (p *Poll) pull() ([]gjson.Result, error) {
+	limit := p.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultPollTimeout
+	}
+	params := zero.Params{"limit": limit}
+	wait := 30 * time.Second
+	if timeout > 0 {
+		params["timeout"] = int64(timeout / time.Second)
+		wait += timeout
+	}
+	if p.cursor != nil {
+		params["cursor"] = p.cursor
+	}
+	action := p.Action
+	if action == "" {
+		action = DefaultPollAction
+	}
+	c, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	rsp, err := p.caller.CallAPI(c, zero.APIRequest{Action: action, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.RetCode != 0 {
+		return nil, errors.New("poll: " + action + " returned retcode " + strconv.FormatInt(rsp.RetCode, 10) + ": " + rsp.Message)
+	}
+	if rsp.Data.IsArray() {
+		return rsp.Data.Array(), nil
+	}
+	if cursor := rsp.Data.Get("cursor"); cursor.Exists() {
+		p.cursor = json.RawMessage(cursor.Raw)
+	}
+	return rsp.Data.Get("events").Array(), nil
+}
+
+// Listen 循环拉取事件, 失败时以指数退避重试
+func (p *Poll) Listen(handler func([]byte, zero.APICaller)) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultPollMaxBackoff
+	}
+	convert := p.Convert
+	if convert == nil {
+		convert = ConvertOneBot12
+	}
+	var backoff time.Duration
+	for {
+		start := time.Now()
+		events, err := p.pull()
+		if err != nil {
+			backoff *= 2
+			if backoff == 0 {
+				backoff = time.Second
+			}
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			metricConnectErrors.With("poll", p.URL).Inc()
+			zero.Log("poll").Warn("拉取事件失败", zero.F("url", p.URL), zero.F("retry_in", backoff.String()), zero.F(zero.FieldError, err))
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+		for _, ev := range events {
+			var self json.RawMessage
+			if ev.Get("type").Exists() && !ev.Get("post_type").Exists() { // OneBot 12
+				p.ob12.Store(true)
+				if s := ev.Get("self"); s.IsObject() {
+					self = json.RawMessage(s.Raw)
+				}
+			}
+			payload := convert([]byte(ev.Raw))
+			caller := p.register(gjson.GetBytes(payload, "self_id").Int(), self)
+			if gjson.GetBytes(payload, "meta_event_type").Str == "heartbeat" { // 忽略心跳事件
+				continue
+			}
+			zero.Log("poll").Debug("接收到事件", zero.F("payload", string(payload)))
+			handler(payload, caller)
+		}
+		if elapsed := time.Since(start); len(events) == 0 && elapsed < interval {
+			time.Sleep(interval - elapsed)
+		}
+	}
+}
+
+// Status implements zero.DriverStatus
+func (p *Poll) Status() zero.DriverState {
+	ids := []int64{}
+	if p.caller != nil {
+		ids = connectedIDs(func(c zero.APICaller) bool {
+			pc, ok := c.(*pollCaller)
+			return ok && pc.p == p
+		})
+	}
+	return zero.DriverState{Type: "poll", URL: p.URL, Connected: len(ids) > 0, SelfIDs: ids}
+}
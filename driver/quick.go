@@ -0,0 +1,210 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// DefaultQuickTimeout HTTP 驱动等待事件处理以返回快速操作的建议时长
+const DefaultQuickTimeout = 3 * time.Second
+
+type quickReplyKey struct{}
+
+// QuickReply 返回允许以快速操作回复的 context
+//
+// 启用了快速操作的 HTTP 驱动会将以此 context 发送的 send_msg 等回复写入上报的响应,
+// 此时没有 message_id (Send 返回 0), 如
+//
+//	ctx.CallActionWithContext(driver.QuickReply(ctx.Context()), "send_msg", params)
+func QuickReply(c context.Context) context.Context {
+	return context.WithValue(c, quickReplyKey{}, true)
+}
+
+func isQuickReply(c context.Context) bool {
+	ok, _ := c.Value(quickReplyKey{}).(bool)
+	return ok
+}
+
+// quickCaller 在 HTTP 上报的响应窗口内, 将针对当前事件的 API 调用转为快速操作
+//
+// 快速操作在事件处理结束后才随响应发出, 因此之后的调用会先将已记下的快速操作按原请求发出, 以保持顺序
+//
+// https://github.com/botuniverse/onebot-11/blob/master/communication/http-post.md#快速操作
+type quickCaller struct {
+	*HTTPCaller
+	event gjson.Result
+
+	mu      sync.Mutex
+	op      map[string]any
+	pending []zero.APIRequest // 已转为 op 的请求
+	closed  bool
+	once    sync.Once
+	done    chan struct{}
+}
+
+func newQuickCaller(c *HTTPCaller, event []byte) *quickCaller {
+	return &quickCaller{
+		HTTPCaller: c,
+		event:      gjson.ParseBytes(event),
+		op:         map[string]any{},
+		done:       make(chan struct{}),
+	}
+}
+
+// quickResponse 以快速操作完成的调用的返回值, 不含 message_id
+var quickResponse = zero.APIResponse{Status: "ok", Data: gjson.Parse("{}")}
+
+// CallAPI 能以快速操作完成时不再发起请求, 否则先发出已记下的快速操作, 再调用 HTTPCaller
+func (q *quickCaller) CallAPI(c context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if q.quick(c, req) {
+		zero.Log("httpserver").Debug("以快速操作响应事件", zero.F(zero.FieldAction, req.Action))
+		return quickResponse, nil
+	}
+	q.flush(c)
+	return q.HTTPCaller.CallAPI(c, req)
+}
+
+// flush 将已记下的快速操作按原请求发出
+func (q *quickCaller) flush(c context.Context) {
+	q.mu.Lock()
+	pending := q.pending
+	if len(pending) > 0 {
+		q.op, q.pending = map[string]any{}, nil
+	}
+	q.mu.Unlock()
+	for _, req := range pending {
+		if _, err := q.HTTPCaller.CallAPI(c, req); err != nil {
+			zero.Log("httpserver").Warn("发出快速操作失败", zero.F(zero.FieldAction, req.Action), zero.F(zero.FieldError, err))
+		}
+	}
+}
+
+// EventFinished implements zero.EventFinisher.
+func (q *quickCaller) EventFinished() {
+	q.once.Do(func() { close(q.done) })
+}
+
+// finish 关闭窗口, 返回需写入响应的快速操作
+//
+// 返回的快速操作已交给响应, 之后的调用不会再按原请求发出
+func (q *quickCaller) finish() map[string]any {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	op := q.op
+	q.op, q.pending = map[string]any{}, nil
+	return op
+}
+
+// set 在窗口内写入 kv, 已存在的键不覆盖
+func (q *quickCaller) set(req zero.APIRequest, kv map[string]any) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	for k := range kv {
+		if _, ok := q.op[k]; ok {
+			return false
+		}
+	}
+	for k, v := range kv {
+		q.op[k] = v
+	}
+	q.pending = append(q.pending, req)
+	return true
+}
+
+// quick 尝试将 req 转为对当前事件的快速操作
+//
+// 发送消息需 c 由 QuickReply 标记, 其余操作的返回值本就不含数据
+func (q *quickCaller) quick(c context.Context, req zero.APIRequest) bool {
+	ev := q.event
+	p := req.Params
+	groupID := ev.Get("group_id").Int()
+	userID := ev.Get("user_id").Int()
+	switch ev.Get("post_type").Str {
+	case "message":
+		isGroup := ev.Get("message_type").Str == "group"
+		switch req.Action {
+		case "send_msg", "send_group_msg", "send_private_msg":
+			if !isQuickReply(c) {
+				return false
+			}
+			if req.Action != "send_private_msg" && isGroup && paramInt(p["group_id"]) == groupID ||
+				req.Action != "send_group_msg" && !isGroup && paramInt(p["group_id"]) == 0 && paramInt(p["user_id"]) == userID {
+				escape, _ := p["auto_escape"].(bool)
+				return q.set(req, map[string]any{"reply": p["message"], "auto_escape": escape, "at_sender": false})
+			}
+		case "delete_msg":
+			if isGroup && paramInt(p["message_id"]) == ev.Get("message_id").Int() {
+				return q.set(req, map[string]any{"delete": true})
+			}
+		case "set_group_kick":
+			if isGroup && paramInt(p["group_id"]) == groupID && paramInt(p["user_id"]) == userID {
+				if reject, _ := p["reject_add_request"].(bool); !reject {
+					return q.set(req, map[string]any{"kick": true})
+				}
+			}
+		case "set_group_ban":
+			if isGroup && paramInt(p["group_id"]) == groupID && paramInt(p["user_id"]) == userID {
+				// 快速操作的禁言时长以分钟为单位
+				if d := paramInt(p["duration"]); d > 0 && d%60 == 0 {
+					return q.set(req, map[string]any{"ban": true, "ban_duration": d / 60})
+				}
+			}
+		}
+	case "request":
+		if p["flag"] != ev.Get("flag").Str {
+			return false
+		}
+		approve, _ := p["approve"].(bool)
+		switch {
+		case req.Action == "set_friend_add_request" && ev.Get("request_type").Str == "friend":
+			return q.set(req, map[string]any{"approve": approve, "remark": p["remark"]})
+		case req.Action == "set_group_add_request" && ev.Get("request_type").Str == "group":
+			return q.set(req, map[string]any{"approve": approve, "reason": p["reason"]})
+		}
+	}
+	return false
+}
+
+// paramInt 将 Params 中的数字转为 int64
+func paramInt(v any) int64 {
+	switch x := v.(type) {
+	case int:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case int64:
+		return x
+	case uint64:
+		return int64(x)
+	case float64:
+		return int64(x)
+	case json.Number:
+		i, _ := x.Int64()
+		return i
+	case string:
+		i, _ := strconv.ParseInt(x, 10, 64)
+		return i
+	}
+	return 0
+}
+
+// paramMessage 将 Params 中的 message 转为 message.Message, 支持 CQ 码, 单个 Segment 与数组
+func paramMessage(v any) message.Message {
+	b, _ := json.Marshal(v)
+	if gjson.ParseBytes(b).IsObject() { // 单个 Segment
+		b = append(append([]byte{'['}, b...), ']')
+	}
+	return message.ParseMessage(b)
+}
@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func TestQuickCaller(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, strings.TrimPrefix(r.URL.Path, "/"))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"status":"ok","retcode":0,"data":{"message_id":7}}`))
+	}))
+	defer srv.Close()
+
+	event := []byte(`{"post_type":"message","message_type":"group","group_id":10,"user_id":2,"message_id":5}`)
+	send := func(text string) zero.APIRequest {
+		return zero.APIRequest{Action: "send_msg", Params: zero.Params{"group_id": int64(10), "message": text}}
+	}
+	bg := context.Background()
+
+	// 未标记 QuickReply 的发送需要 message_id, 不转为快速操作
+	q := newQuickCaller(&HTTPCaller{URL: srv.URL}, event)
+	rsp, err := q.CallAPI(bg, send("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), rsp.Data.Get("message_id").Int())
+	assert.Empty(t, q.finish())
+
+	// 快速回复之后的调用先发出快速回复, 保持顺序
+	calls = nil
+	q = newQuickCaller(&HTTPCaller{URL: srv.URL}, event)
+	rsp, err = q.CallAPI(QuickReply(bg), send("a"))
+	assert.NoError(t, err)
+	assert.False(t, rsp.Data.Get("message_id").Exists())
+	_, err = q.CallAPI(QuickReply(bg), send("b"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"send_msg", "send_msg"}, calls)
+	assert.Empty(t, q.finish())
+
+	// 唯一的快速操作随响应返回
+	calls = nil
+	q = newQuickCaller(&HTTPCaller{URL: srv.URL}, event)
+	_, _ = q.CallAPI(QuickReply(bg), send("a"))
+	_, _ = q.CallAPI(bg, zero.APIRequest{Action: "delete_msg", Params: zero.Params{"message_id": int64(6)}})
+	assert.Equal(t, []string{"send_msg", "delete_msg"}, calls)
+	calls = nil
+	q = newQuickCaller(&HTTPCaller{URL: srv.URL}, event)
+	_, _ = q.CallAPI(bg, zero.APIRequest{Action: "delete_msg", Params: zero.Params{"message_id": int64(5)}})
+	_, _ = q.CallAPI(QuickReply(bg), send("a"))
+	assert.Empty(t, calls)
+	assert.Equal(t, map[string]any{"delete": true, "reply": "a", "auto_escape": false, "at_sender": false}, q.finish())
+
+	// 响应返回后的调用不再重复发出已随响应返回的快速操作
+	calls = nil
+	q = newQuickCaller(&HTTPCaller{URL: srv.URL}, event)
+	_, _ = q.CallAPI(QuickReply(bg), send("a"))
+	assert.Equal(t, map[string]any{"reply": "a", "auto_escape": false, "at_sender": false}, q.finish())
+	_, err = q.CallAPI(QuickReply(bg), send("b"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"send_msg"}, calls)
+	assert.Empty(t, q.finish())
+}
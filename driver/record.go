@@ -0,0 +1,367 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// 录制文件中记录的类型
+const (
+	RecordEvent = "event"
+	RecordAPI   = "api"
+)
+
+// Record 录制文件 (JSONL) 中的一行
+type Record struct {
+	Time     time.Time        `json:"time"`
+	Type     string           `json:"type"` // event 或 api
+	SelfID   int64            `json:"self_id"`
+	Event    json.RawMessage  `json:"event,omitempty"`    // 原始事件
+	Request  *zero.APIRequest `json:"request,omitempty"`  // API 请求, 不含 echo
+	Response *RecordResponse  `json:"response,omitempty"` // API 响应
+	Error    string           `json:"error,omitempty"`    // API 调用返回的错误
+}
+
+// RecordResponse 可序列化的 zero.APIResponse
+type RecordResponse struct {
+	Status  string          `json:"status"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"msg,omitempty"`
+	Wording string          `json:"wording,omitempty"`
+	RetCode int64           `json:"retcode"`
+}
+
+func newRecordResponse(rsp zero.APIResponse) *RecordResponse {
+	r := &RecordResponse{Status: rsp.Status, Message: rsp.Message, Wording: rsp.Wording, RetCode: rsp.RetCode}
+	if rsp.Data.Raw != "" {
+		r.Data = json.RawMessage(rsp.Data.Raw)
+	}
+	return r
+}
+
+// APIResponse 转回 zero.APIResponse
+func (r *RecordResponse) APIResponse() zero.APIResponse {
+	return zero.APIResponse{
+		Status:  r.Status,
+		Data:    gjson.ParseBytes(r.Data),
+		Message: r.Message,
+		Wording: r.Wording,
+		RetCode: r.RetCode,
+	}
+}
+
+// Recorder 包装任意 Driver, 将收到的事件与 API 调用逐行追加到 W
+//
+// 连接收到第一个事件 (通常为 lifecycle 元事件) 后, 其在 zero.APICallers 中的 APICaller
+// 被替换为录制的包装, 此后经由 zero.GetBot 等发起的调用同样被录制
+type Recorder struct {
+	zero.Driver
+	W io.Writer
+
+	mu      sync.Mutex
+	callers map[recordKey]*recordCaller // 已登记到 APICallers 的包装
+}
+
+type recordKey struct {
+	caller zero.APICaller
+	selfID int64
+}
+
+// NewRecorder 录制 d 的事件与 API 调用到 w
+func NewRecorder(d zero.Driver, w io.Writer) *Recorder {
+	return &Recorder{Driver: d, W: w}
+}
+
+func (r *Recorder) write(rec *Record) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		zero.Log("record").Warn("序列化录制记录失败", zero.F(zero.FieldError, err))
+		return
+	}
+	b = append(b, '\n')
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err = r.W.Write(b); err != nil {
+		zero.Log("record").Warn("写入录制记录失败", zero.F(zero.FieldError, err))
+	}
+}
+
+// Listen 录制事件后交给 handler
+func (r *Recorder) Listen(handler func([]byte, zero.APICaller)) {
+	r.Driver.Listen(func(payload []byte, caller zero.APICaller) {
+		selfID := gjson.GetBytes(payload, "self_id").Int()
+		r.write(&Record{Time: time.Now(), Type: RecordEvent, SelfID: selfID, Event: append(json.RawMessage(nil), payload...)})
+		handler(payload, r.wrap(selfID, caller))
+	})
+}
+
+// wrap 返回录制 caller 的包装, caller 为账号的连接时将包装登记到 APICallers
+func (r *Recorder) wrap(selfID int64, caller zero.APICaller) zero.APICaller {
+	key := recordKey{caller: caller, selfID: selfID}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rc, ok := r.callers[key]; ok {
+		return rc
+	}
+	rc := &recordCaller{r: r, caller: caller, selfID: selfID}
+	if !zero.WrapCaller(selfID, caller, rc) {
+		return rc // 如为单个事件包装的 APICaller
+	}
+	if r.callers == nil {
+		r.callers = map[recordKey]*recordCaller{}
+	}
+	// 清理已断开的连接
+	for k, c := range r.callers {
+		if g, ok := zero.APICallers.Load(k.selfID); !ok || !contains(g, c) {
+			delete(r.callers, k)
+		}
+	}
+	r.callers[key] = rc
+	return rc
+}
+
+func contains(g zero.APICaller, c zero.APICaller) bool {
+	cg, ok := g.(*zero.CallerGroup)
+	return ok && cg.Contains(c)
+}
+
+// Close 关闭 W, 如果 W 实现了 io.Closer
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.W.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Status implements zero.DriverStatus
+func (r *Recorder) Status() zero.DriverState {
+	if s, ok := r.Driver.(zero.DriverStatus); ok {
+		return s.Status()
+	}
+	return zero.DriverState{Type: "record"}
+}
+
+// recordCaller 录制经由其发起的 API 调用
+type recordCaller struct {
+	r      *Recorder
+	caller zero.APICaller
+	selfID int64
+}
+
+func (c *recordCaller) CallAPI(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	rsp, err := c.caller.CallAPI(ctx, req)
+	req.Echo = 0
+	rec := &Record{Time: time.Now(), Type: RecordAPI, SelfID: c.selfID, Request: &req, Response: newRecordResponse(rsp)}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	c.r.write(rec)
+	return rsp, err
+}
+
+// Unwrap implements zero.CallerWrapper
+func (c *recordCaller) Unwrap() zero.APICaller {
+	return c.caller
+}
+
+// EventFinished 转发给被包装的 APICaller, 如 HTTP 驱动的快速操作
+func (c *recordCaller) EventFinished() {
+	if f, ok := c.caller.(zero.EventFinisher); ok {
+		f.EventFinished()
+	}
+}
+
+// ErrNoRecord 回放时找不到与 API 请求对应的录制响应
+var ErrNoRecord = errors.New("replay: no recorded response")
+
+// Replay 回放 Recorder 录制的文件, 按录制的间隔重新发送事件, 并以录制的响应回答 API 调用
+//
+// API 请求优先匹配 action 与参数相同的录制, 其次匹配 action 相同的录制, 每条录制只使用一次
+type Replay struct {
+	R     io.Reader
+	Speed float64 // 回放速度, 1 为原速, 2 为两倍速, 0 为不等待
+
+	mu      sync.Mutex
+	records []Record
+	exact   map[string][]*Record // action 与参数到 API 调用的录制
+	byName  map[string][]*Record // action 到 API 调用的录制
+	used    map[*Record]bool
+	err     error
+	wg      sync.WaitGroup
+	loaded  bool
+	selfIDs []int64
+}
+
+// NewReplay 以 speed 倍速回放 r 中的录制
+func NewReplay(r io.Reader, speed float64) *Replay {
+	return &Replay{R: r, Speed: speed}
+}
+
+// canonical 将 JSON 规范化以便比较参数
+func canonical(b []byte) string {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if d.Decode(&v) != nil {
+		return string(b)
+	}
+	b, _ = json.Marshal(v)
+	return string(b)
+}
+
+func requestKey(action string, params []byte) string {
+	return action + " " + canonical(params)
+}
+
+// load 读取录制文件
+func (rp *Replay) load() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.loaded {
+		return rp.err
+	}
+	rp.loaded = true
+	rp.exact = map[string][]*Record{}
+	rp.byName = map[string][]*Record{}
+	rp.used = map[*Record]bool{}
+	seen := map[int64]bool{}
+	s := bufio.NewScanner(rp.R)
+	s.Buffer(nil, DefaultMaxMessageSize)
+	for s.Scan() {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		rec := &Record{}
+		if rp.err = json.Unmarshal(s.Bytes(), rec); rp.err != nil {
+			return rp.err
+		}
+		if rec.SelfID != 0 && !seen[rec.SelfID] {
+			seen[rec.SelfID] = true
+			rp.selfIDs = append(rp.selfIDs, rec.SelfID)
+		}
+		switch rec.Type {
+		case RecordEvent:
+			rp.records = append(rp.records, *rec)
+		case RecordAPI:
+			if rec.Request == nil || rec.Response == nil {
+				continue
+			}
+			// 使用原始参数, 避免大整数经 float64 失真
+			key := requestKey(rec.Request.Action, []byte(gjson.GetBytes(s.Bytes(), "request.params").Raw))
+			rp.exact[key] = append(rp.exact[key], rec)
+			rp.byName[rec.Request.Action] = append(rp.byName[rec.Request.Action], rec)
+		}
+	}
+	rp.err = s.Err()
+	return rp.err
+}
+
+// Connect 读取录制文件并登记其中的账号
+func (rp *Replay) Connect() {
+	if err := rp.load(); err != nil {
+		zero.Log("replay").Error("读取录制文件失败", zero.F(zero.FieldError, err))
+		return
+	}
+	for _, id := range rp.selfIDs {
+		zero.AddCaller(id, rp)
+	}
+	zero.Log("replay").Info("已读取录制文件", zero.F("events", len(rp.records)))
+}
+
+// Listen 按录制的时间间隔发送事件, 所有事件处理完成后返回
+func (rp *Replay) Listen(handler func([]byte, zero.APICaller)) {
+	if err := rp.load(); err != nil {
+		return
+	}
+	defer func() {
+		for _, id := range rp.selfIDs {
+			zero.RemoveCaller(id, rp)
+		}
+	}()
+	var last time.Time
+	for i := range rp.records {
+		rec := &rp.records[i]
+		if rp.Speed > 0 && !last.IsZero() {
+			time.Sleep(time.Duration(float64(rec.Time.Sub(last)) / rp.Speed))
+		}
+		last = rec.Time
+		rp.wg.Add(1)
+		handler(rec.Event, &replayCaller{rp: rp})
+	}
+	done := make(chan struct{})
+	go func() {
+		rp.wg.Wait()
+		close(done)
+	}()
+	wait := zero.GetConfig().MaxProcessTime
+	if wait <= 0 {
+		wait = 4 * time.Minute
+	}
+	select {
+	case <-done:
+	case <-time.After(wait):
+		zero.Log("replay").Warn("等待事件处理超时")
+	}
+	zero.Log("replay").Info("回放结束", zero.F("events", len(rp.records)))
+}
+
+// CallAPI 返回录制的响应
+func (rp *Replay) CallAPI(_ context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	take := func(recs []*Record) *Record {
+		for _, r := range recs {
+			if !rp.used[r] {
+				rp.used[r] = true
+				return r
+			}
+		}
+		return nil
+	}
+	params, _ := json.Marshal(req.Params)
+	rec := take(rp.exact[requestKey(req.Action, params)])
+	if rec == nil {
+		rec = take(rp.byName[req.Action])
+	}
+	if rec == nil {
+		zero.Log("replay").Warn("没有对应的录制响应", zero.F(zero.FieldAction, req.Action))
+		return nullResponse, ErrNoRecord
+	}
+	if rec.Error != "" {
+		return rec.Response.APIResponse(), errors.New(rec.Error)
+	}
+	return rec.Response.APIResponse(), nil
+}
+
+// Status implements zero.DriverStatus
+func (rp *Replay) Status() zero.DriverState {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return zero.DriverState{Type: "replay", Connected: rp.loaded && rp.err == nil, SelfIDs: rp.selfIDs}
+}
+
+// replayCaller 回放事件的 APICaller, 事件处理完成时通知 Replay
+type replayCaller struct {
+	rp   *Replay
+	once sync.Once
+}
+
+func (c *replayCaller) CallAPI(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	return c.rp.CallAPI(ctx, req)
+}
+
+func (c *replayCaller) EventFinished() {
+	c.once.Do(c.rp.wg.Done)
+}
@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func TestRecordReplay(t *testing.T) {
+	const selfID = 30000
+	bg := context.Background()
+	send := zero.APIRequest{Action: "send_msg", Params: zero.Params{"user_id": int64(30001), "message": "pong"}}
+
+	// 录制控制台的两条消息, 分别经由事件的 APICaller 与 GetBot 调用 API
+	var buf bytes.Buffer
+	out := &syncBuffer{}
+	c := &Console{SelfID: selfID, UserID: 30001, In: strings.NewReader("ping\nping\n"), Out: out}
+	r := NewRecorder(c, &buf)
+	r.Connect()
+	var sent []int64
+	r.Listen(func(_ []byte, caller zero.APICaller) {
+		rsp, err := caller.CallAPI(bg, send)
+		assert.NoError(t, err)
+		sent = append(sent, rsp.Data.Get("message_id").Int())
+		// 驱动的 Status 仍能找到被包装的连接
+		assert.Equal(t, []int64{selfID}, connectedIDs(func(x zero.APICaller) bool { return x == c }))
+		bot := zero.GetBot(selfID)
+		assert.NotNil(t, bot)
+		assert.Equal(t, "bot", bot.CallAction("get_login_info", nil).Data.Get("nickname").String())
+	})
+	assert.Equal(t, 2, strings.Count(out.String(), "[bot -> 私聊 30001] pong"))
+	assert.Equal(t, []int64{2, 4}, sent)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// 2 个事件, 每个事件的 send_msg 与经由 GetBot 的 get_login_info
+	assert.Len(t, lines, 6)
+
+	// 回放时以录制的响应回答相同的调用
+	rp := NewReplay(&buf, 0)
+	rp.Connect()
+	var replayed []int64
+	rp.Listen(func(_ []byte, caller zero.APICaller) {
+		defer caller.(zero.EventFinisher).EventFinished()
+		rsp, err := caller.CallAPI(bg, send)
+		assert.NoError(t, err)
+		replayed = append(replayed, rsp.Data.Get("message_id").Int())
+		rsp, err = caller.CallAPI(bg, zero.APIRequest{Action: "get_login_info"})
+		assert.NoError(t, err)
+		assert.Equal(t, "bot", rsp.Data.Get("nickname").String())
+		_, err = caller.CallAPI(bg, zero.APIRequest{Action: "get_status"})
+		assert.ErrorIs(t, err, ErrNoRecord)
+	})
+	assert.Equal(t, sent, replayed)
+	assert.Nil(t, zero.GetBot(selfID))
+}
@@ -0,0 +1,439 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RomiChan/websocket"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/extension/filter"
+)
+
+const (
+	// DefaultRelayTimeout 转发下游 API 调用的默认超时
+	DefaultRelayTimeout = time.Minute
+	// DefaultRelayHeartbeat 向下游发送心跳事件的默认间隔
+	DefaultRelayHeartbeat = 5 * time.Second
+	// relayQueueSize 每个下游连接与上报地址待发送事件的队列长度, 队列满时丢弃新事件
+	relayQueueSize = 256
+)
+
+// errRelayNoCaller 下游调用的账号没有可用的 APICaller
+var errRelayNoCaller = errors.New("relay: no api caller for self_id")
+
+// Relay 包装任意 Driver, 作为 OneBot 11 实现端为下游 bot 提供正向 WebSocket 与 HTTP API,
+// 使一个上游连接同时服务多个客户端
+//
+// 收到的事件交给 zero 的同时转发给所有下游 WebSocket 连接与 Post 中的地址, 可由 Filter 过滤;
+// 上游驱动不传递心跳事件, 因此由 Relay 为每个已连接的账号定时生成.
+// 下游的 API 调用经 zero.APICallers 中对应账号的 APICaller 发出, 上游重新分配 echo,
+// 响应时换回下游的 echo. 下游以 X-Self-ID 头或 self_id 参数选择账号, 未指定时使用 ID 最小的账号
+type Relay struct {
+	zero.Driver
+	URL         string        // 监听地址, 如 ws://127.0.0.1:6701
+	AccessToken string        // 下游使用的 access token, 同时是 Post 的签名密钥
+	TLS         *TLSConfig    // 不为 nil 时以 TLS 监听, 不用于 Post
+	Auth        *ServerAuth   // 鉴权与限制, 为 nil 时使用默认限制
+	Post        []string      // 以 HTTP POST 上报事件的下游地址
+	Filter      filter.Func   // 不为 nil 时只转发满足条件的事件
+	Timeout     time.Duration // 转发 API 调用的超时, 0 为 DefaultRelayTimeout
+	Heartbeat   time.Duration // 心跳事件的间隔, 0 为 DefaultRelayHeartbeat, 负数不发送
+
+	authOnce sync.Once
+	mu       sync.Mutex
+	clients  map[*relayClient]struct{}
+	posts    []chan relayEvent // 与 Post 一一对应的上报队列
+}
+
+// NewRelay 在 url 上为下游转发 d 的事件与 API 调用
+func NewRelay(d zero.Driver, url, accessToken string) *Relay {
+	return &Relay{Driver: d, URL: url, AccessToken: accessToken}
+}
+
+// relayClient 下游的一个 WebSocket 连接
+type relayClient struct {
+	mu     sync.Mutex // 写锁
+	conn   *websocket.Conn
+	selfID int64
+	role   string
+	events chan []byte
+}
+
+// relayEvent 待上报的事件
+type relayEvent struct {
+	selfID  int64
+	payload []byte
+}
+
+// relayResponse 返回给下游的 API 响应
+type relayResponse struct {
+	*RecordResponse
+	Echo json.RawMessage `json:"echo,omitempty"`
+}
+
+func (r *Relay) auth() *ServerAuth {
+	r.authOnce.Do(func() {
+		if r.Auth == nil {
+			r.Auth = &ServerAuth{}
+		}
+	})
+	return r.Auth
+}
+
+func (r *Relay) timeout() time.Duration {
+	if r.Timeout > 0 {
+		return r.Timeout
+	}
+	return DefaultRelayTimeout
+}
+
+// Listen 开始为下游提供服务, 并在事件交给 handler 前转发给下游
+func (r *Relay) Listen(handler func([]byte, zero.APICaller)) {
+	r.posts = make([]chan relayEvent, len(r.Post))
+	for i, u := range r.Post {
+		r.posts[i] = make(chan relayEvent, relayQueueSize)
+		go r.postLoop(u, r.posts[i])
+	}
+	go r.serve()
+	go r.heartbeat()
+	r.Driver.Listen(func(payload []byte, caller zero.APICaller) {
+		r.broadcast(payload)
+		handler(payload, caller)
+	})
+}
+
+// serve 监听 URL, 失败后重试
+func (r *Relay) serve() {
+	network, address := resolveURI(r.URL)
+	if uri, err := url.Parse(address); err == nil && uri.Scheme != "" {
+		address = uri.Host
+	}
+	for {
+		lstn, err := net.Listen(network, address)
+		if err == nil {
+			lstn, err = listenTLS(lstn, r.TLS)
+		}
+		if err == nil {
+			zero.Log("relay").Info("转发服务开始监听", zero.F("addr", lstn.Addr().String()))
+			err = http.Serve(lstn, http.HandlerFunc(r.any))
+		}
+		metricConnectErrors.With("relay", r.URL).Inc()
+		zero.Log("relay").Warn("转发服务监听失败", zero.F("url", r.URL), zero.F(zero.FieldError, err))
+		time.Sleep(2 * time.Second) // 等待两秒后重试
+	}
+}
+
+// account 返回请求的账号, 未指定时使用 token 允许的 ID 最小的已连接账号
+func (r *Relay) account(req *http.Request, ids []int64) int64 {
+	s := req.Header.Get("X-Self-ID")
+	if s == "" {
+		s = req.URL.Query().Get("self_id")
+	}
+	if s != "" {
+		id, _ := strconv.ParseInt(s, 10, 64)
+		return id
+	}
+	var selfID int64
+	zero.APICallers.Range(func(id int64, _ zero.APICaller) bool {
+		if permitted(ids, id) && (selfID == 0 || id < selfID) {
+			selfID = id
+		}
+		return true
+	})
+	return selfID
+}
+
+func (r *Relay) any(w http.ResponseWriter, req *http.Request) {
+	a := r.auth()
+	ip := remoteIP(req)
+	if status := a.admit(ip); status != http.StatusOK {
+		zero.Log("relay").Warn("已拒绝下游请求: 来源受限", zero.F("remote", req.RemoteAddr), zero.F("code", status))
+		w.WriteHeader(status)
+		return
+	}
+	ids, status := a.checkToken(req, r.AccessToken)
+	if status != http.StatusOK {
+		a.fail(ip)
+		zero.Log("relay").Warn("已拒绝下游请求: Token鉴权失败", zero.F("remote", req.RemoteAddr), zero.F("code", status))
+		w.WriteHeader(status)
+		return
+	}
+	selfID := r.account(req, ids)
+	if !permitted(ids, selfID) {
+		a.fail(ip)
+		zero.Log("relay").Warn("已拒绝下游请求: Token 不允许该账号", zero.F("remote", req.RemoteAddr), zero.F(zero.FieldSelfID, selfID))
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	a.succeed(ip)
+	if _, ok := zero.APICallers.Load(selfID); !ok {
+		w.WriteHeader(http.StatusServiceUnavailable) // 上游尚未连接
+		return
+	}
+	if size := a.messageSize(); size > 0 {
+		req.Body = http.MaxBytesReader(w, req.Body, size)
+	}
+	if websocket.IsWebSocketUpgrade(req) {
+		r.accept(w, req, selfID)
+		return
+	}
+	r.httpAPI(w, req, selfID)
+}
+
+// httpAPI 处理 HTTP API 调用, action 为路径, 参数为 JSON 请求体或查询与表单参数
+func (r *Relay) httpAPI(w http.ResponseWriter, req *http.Request, selfID int64) {
+	action := strings.Trim(req.URL.Path, "/")
+	if action == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	params := zero.Params{}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		body, err := io.ReadAll(req.Body)
+		if err == nil && len(bytes.TrimSpace(body)) > 0 {
+			err = json.Unmarshal(body, &params)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else {
+		if err := req.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for k := range req.Form {
+			params[k] = formParam(k, req.Form.Get(k))
+		}
+	}
+	delete(params, "self_id")
+	rsp := r.call(req.Context(), selfID, zero.APIRequest{Action: action, Params: params}, nil)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rsp)
+}
+
+// formParam 将表单参数转为 JSON 请求中的类型, 以 _id 结尾的参数与 duration 为数字, true 与 false 为布尔值
+func formParam(k, v string) any {
+	switch {
+	case v == "true":
+		return true
+	case v == "false":
+		return false
+	case strings.HasSuffix(k, "_id") || k == "duration":
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+	}
+	return v
+}
+
+// call 经上游转发 API 调用
+func (r *Relay) call(ctx context.Context, selfID int64, req zero.APIRequest, echo json.RawMessage) relayResponse {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout())
+	defer cancel()
+	rsp := nullResponse
+	caller, ok := zero.APICallers.Load(selfID)
+	err := errRelayNoCaller
+	if ok {
+		rsp, err = caller.CallAPI(ctx, req)
+	}
+	if err != nil {
+		zero.Log("relay").Warn("转发下游 API 调用失败", zero.F(zero.FieldSelfID, selfID), zero.F(zero.FieldAction, req.Action), zero.F(zero.FieldError, err))
+		rsp = zero.APIResponse{Status: "failed", RetCode: 100, Message: err.Error()}
+	}
+	return relayResponse{RecordResponse: newRecordResponse(rsp), Echo: echo}
+}
+
+// accept 升级下游的 WebSocket 连接, 先发送 lifecycle 事件告知账号
+func (r *Relay) accept(w http.ResponseWriter, req *http.Request, selfID int64) {
+	conn, err := upgrader.Upgrade(w, req, http.Header{"X-Self-ID": []string{strconv.FormatInt(selfID, 10)}})
+	if err != nil {
+		zero.Log("relay").Warn("处理下游 WebSocket 请求时出现错误", zero.F("remote", req.RemoteAddr), zero.F(zero.FieldError, err))
+		return
+	}
+	if size := r.auth().messageSize(); size > 0 {
+		conn.SetReadLimit(size)
+	}
+	c := &relayClient{conn: conn, selfID: selfID, role: clientRole(req), events: make(chan []byte, relayQueueSize)}
+	err = conn.WriteJSON(map[string]any{
+		"time": time.Now().Unix(), "self_id": selfID,
+		"post_type": "meta_event", "meta_event_type": "lifecycle", "sub_type": "connect",
+	})
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	r.mu.Lock()
+	if r.clients == nil {
+		r.clients = map[*relayClient]struct{}{}
+	}
+	r.clients[c] = struct{}{}
+	r.mu.Unlock()
+	metricConnects.With("relay", r.URL).Inc()
+	zero.Log("relay").Info("下游已连接", zero.F("remote", req.RemoteAddr), zero.F(zero.FieldSelfID, selfID), zero.F("role", c.role))
+	go c.write()
+	r.read(c)
+	r.mu.Lock()
+	delete(r.clients, c)
+	r.mu.Unlock()
+	close(c.events)
+	_ = conn.Close()
+	metricDisconnects.With("relay", r.URL).Inc()
+	zero.Log("relay").Info("下游连接断开", zero.F("remote", req.RemoteAddr), zero.F(zero.FieldSelfID, selfID))
+}
+
+// read 读取下游的 API 调用并转发, 直到连接断开
+func (r *Relay) read(c *relayClient) {
+	for {
+		t, payload, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if t != websocket.TextMessage || c.role == RoleEvent {
+			continue
+		}
+		var req struct {
+			Action string          `json:"action"`
+			Params zero.Params     `json:"params"`
+			Echo   json.RawMessage `json:"echo"`
+		}
+		if json.Unmarshal(payload, &req) != nil || req.Action == "" {
+			continue
+		}
+		go func() {
+			rsp := r.call(context.Background(), c.selfID, zero.APIRequest{Action: req.Action, Params: req.Params}, req.Echo)
+			c.mu.Lock()
+			err := c.conn.WriteJSON(&rsp)
+			c.mu.Unlock()
+			if err != nil {
+				zero.Log("relay").Warn("向下游返回 API 响应失败", zero.F(zero.FieldSelfID, c.selfID), zero.F(zero.FieldError, err))
+			}
+		}()
+	}
+}
+
+// write 发送队列中的事件
+func (c *relayClient) write() {
+	for payload := range c.events {
+		c.mu.Lock()
+		err := c.conn.WriteMessage(websocket.TextMessage, payload)
+		c.mu.Unlock()
+		if err != nil {
+			_ = c.conn.Close()
+			for range c.events { // 等待 accept 关闭队列
+			}
+			return
+		}
+	}
+}
+
+// heartbeat 定时为每个已连接的账号向下游发送心跳事件
+func (r *Relay) heartbeat() {
+	interval := r.Heartbeat
+	if interval < 0 {
+		return
+	}
+	if interval == 0 {
+		interval = DefaultRelayHeartbeat
+	}
+	for range time.Tick(interval) {
+		zero.APICallers.Range(func(id int64, _ zero.APICaller) bool {
+			b, _ := json.Marshal(map[string]any{
+				"time": time.Now().Unix(), "self_id": id,
+				"post_type": "meta_event", "meta_event_type": "heartbeat",
+				"status":   map[string]any{"online": true, "good": true},
+				"interval": interval.Milliseconds(),
+			})
+			r.send(id, b)
+			return true
+		})
+	}
+}
+
+// broadcast 将满足 Filter 的事件转发给所有账号相同的下游
+func (r *Relay) broadcast(payload []byte) {
+	e := gjson.ParseBytes(payload)
+	if r.Filter != nil && !r.Filter(e) {
+		return
+	}
+	r.send(e.Get("self_id").Int(), append([]byte(nil), payload...)) // 驱动可能复用缓冲区
+}
+
+// send 将事件放入账号为 selfID 的下游连接与所有上报地址的队列, 队列满时丢弃
+func (r *Relay) send(selfID int64, payload []byte) {
+	r.mu.Lock()
+	for c := range r.clients {
+		if c.selfID != selfID || c.role == RoleAPI {
+			continue
+		}
+		select {
+		case c.events <- payload:
+		default:
+			zero.Log("relay").Warn("下游接收过慢, 已丢弃事件", zero.F(zero.FieldSelfID, selfID))
+		}
+	}
+	r.mu.Unlock()
+	for i, q := range r.posts {
+		select {
+		case q <- relayEvent{selfID: selfID, payload: payload}:
+		default:
+			zero.Log("relay").Warn("下游上报过慢, 已丢弃事件", zero.F("url", r.Post[i]), zero.F(zero.FieldSelfID, selfID))
+		}
+	}
+}
+
+// postLoop 依次上报队列中的事件
+func (r *Relay) postLoop(u string, q <-chan relayEvent) {
+	for e := range q {
+		r.post(u, e.selfID, e.payload)
+	}
+}
+
+// post 以 HTTP POST 上报事件, 设置 AccessToken 时附带 X-Signature
+func (r *Relay) post(u string, selfID int64, payload []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		zero.Log("relay").Warn("向下游上报事件失败", zero.F("url", u), zero.F(zero.FieldError, err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ZeroBot/1.6.3")
+	req.Header.Set("X-Self-ID", strconv.FormatInt(selfID, 10))
+	if r.AccessToken != "" {
+		mac := hmac.New(sha1.New, []byte(r.AccessToken))
+		_, _ = mac.Write(payload)
+		req.Header.Set("X-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		zero.Log("relay").Warn("向下游上报事件失败", zero.F("url", u), zero.F(zero.FieldError, err))
+		return
+	}
+	_, _ = io.Copy(io.Discard, rsp.Body) // 不处理快速操作
+	_ = rsp.Body.Close()
+}
+
+// Status implements zero.DriverStatus
+func (r *Relay) Status() zero.DriverState {
+	if s, ok := r.Driver.(zero.DriverStatus); ok {
+		return s.Status()
+	}
+	return zero.DriverState{Type: "relay", URL: r.URL}
+}
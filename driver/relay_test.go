@@ -0,0 +1,188 @@
+package driver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RomiChan/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/extension/filter"
+)
+
+// chanDriver 依次交出 events 中事件的 Driver
+type chanDriver struct {
+	events chan []byte
+}
+
+func (d *chanDriver) Connect() {}
+
+func (d *chanDriver) Listen(handler func([]byte, zero.APICaller)) {
+	for ev := range d.events {
+		handler(ev, nil)
+	}
+}
+
+// lastCaller 记录最后一次调用的 APICaller
+type lastCaller struct {
+	mu  sync.Mutex
+	req zero.APIRequest
+}
+
+func (c *lastCaller) CallAPI(_ context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	c.mu.Lock()
+	c.req = req
+	c.mu.Unlock()
+	return zero.APIResponse{Status: "ok", Data: gjson.Parse(`{"message_id":1}`)}, nil
+}
+
+func (c *lastCaller) last() zero.APIRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.req
+}
+
+func TestRelay(t *testing.T) {
+	const selfID = 60000
+	up := &lastCaller{}
+	zero.AddCaller(selfID, up)
+	defer zero.RemoveCaller(selfID, up)
+
+	type posted struct{ body, sig, selfID string }
+	posts := make(chan posted, 64)
+	post := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		select {
+		case posts <- posted{string(b), req.Header.Get("X-Signature"), req.Header.Get("X-Self-ID")}:
+		default:
+		}
+	}))
+	defer post.Close()
+
+	drv := &chanDriver{events: make(chan []byte)}
+	r := NewRelay(drv, "ws://127.0.0.1:0", "tok")
+	r.Auth = &ServerAuth{Tokens: map[string][]int64{"other": {1}}}
+	r.Post = []string{post.URL}
+	r.Filter = filter.NewField("message_type").Any(filter.Equal("group"))
+	r.Heartbeat = 50 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		r.Listen(func([]byte, zero.APICaller) {})
+		close(done)
+	}()
+	srv := httptest.NewServer(http.HandlerFunc(r.any))
+	defer srv.Close()
+
+	// 鉴权: 缺少 token, token 错误, token 不允许该账号, 账号未连接
+	status := func(token, self string) int {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/get_status", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("X-Self-ID", self)
+		rsp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		_ = rsp.Body.Close()
+		return rsp.StatusCode
+	}
+	assert.Equal(t, http.StatusUnauthorized, status("", ""))
+	assert.Equal(t, http.StatusForbidden, status("bad", ""))
+	assert.Equal(t, http.StatusForbidden, status("other", "60000"))
+	assert.Equal(t, http.StatusServiceUnavailable, status("tok", "60002"))
+	assert.Equal(t, http.StatusOK, status("tok", ""))
+
+	// HTTP API 的表单参数转为数字与布尔值
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/send_msg", strings.NewReader(url.Values{
+		"group_id": {"10"}, "message": {"123"}, "auto_escape": {"true"}, "self_id": {"60000"},
+	}.Encode()))
+	req.Header.Set("Authorization", "Bearer tok")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rsp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		b, _ := io.ReadAll(rsp.Body)
+		_ = rsp.Body.Close()
+		assert.Equal(t, int64(1), gjson.GetBytes(b, "data.message_id").Int())
+	}
+	assert.Equal(t, zero.APIRequest{Action: "send_msg", Params: zero.Params{"group_id": int64(10), "message": "123", "auto_escape": true}}, up.last())
+
+	// WebSocket 连接先收到 lifecycle 事件, API 响应带回下游的 echo
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), http.Header{"Authorization": {"Bearer tok"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	next := func(match func(gjson.Result) bool) gjson.Result {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			_, b, err := conn.ReadMessage()
+			if !assert.NoError(t, err) {
+				return gjson.Result{}
+			}
+			if e := gjson.ParseBytes(b); match(e) {
+				return e
+			}
+		}
+	}
+	isEvent := func(typ string) func(gjson.Result) bool {
+		return func(e gjson.Result) bool { return e.Get("meta_event_type").Str == typ || e.Get("post_type").Str == typ }
+	}
+	e := next(func(gjson.Result) bool { return true })
+	assert.Equal(t, "lifecycle", e.Get("meta_event_type").Str)
+	assert.Equal(t, int64(selfID), e.Get("self_id").Int())
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"delete_msg","params":{"message_id":5},"echo":{"seq":"a"}}`)))
+	e = next(func(e gjson.Result) bool { return e.Get("echo").Exists() })
+	assert.JSONEq(t, `{"seq":"a"}`, e.Get("echo").Raw)
+	assert.Equal(t, "ok", e.Get("status").Str)
+	assert.Equal(t, "delete_msg", up.last().Action)
+
+	// 定时生成心跳事件
+	e = next(isEvent("heartbeat"))
+	assert.Equal(t, int64(selfID), e.Get("self_id").Int())
+	assert.Equal(t, int64(50), e.Get("interval").Int())
+
+	// 不满足 Filter 的事件与其他账号的事件不转发给该连接
+	drv.events <- []byte(`{"post_type":"message","message_type":"private","self_id":60000,"message_id":1}`)
+	drv.events <- []byte(`{"post_type":"message","message_type":"group","self_id":60001,"message_id":2}`)
+	drv.events <- []byte(`{"post_type":"message","message_type":"group","self_id":60000,"message_id":3}`)
+	e = next(isEvent("message"))
+	assert.Equal(t, int64(3), e.Get("message_id").Int())
+
+	// 上报地址依次收到满足 Filter 的事件与心跳, 附带签名
+	var ids []int64
+	heartbeat := false
+	timeout := time.After(time.Second)
+	for len(ids) < 2 || !heartbeat {
+		select {
+		case p := <-posts:
+			mac := hmac.New(sha1.New, []byte("tok"))
+			_, _ = mac.Write([]byte(p.body))
+			assert.Equal(t, "sha1="+hex.EncodeToString(mac.Sum(nil)), p.sig)
+			assert.Equal(t, gjson.Get(p.body, "self_id").String(), p.selfID)
+			if gjson.Get(p.body, "meta_event_type").Str == "heartbeat" {
+				heartbeat = true
+				continue
+			}
+			ids = append(ids, gjson.Get(p.body, "message_id").Int())
+		case <-timeout:
+			t.Fatal("timeout waiting for posted events", ids, heartbeat)
+		}
+	}
+	assert.Equal(t, []int64{2, 3}, ids)
+
+	close(drv.events)
+	<-done
+}
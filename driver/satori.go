@@ -0,0 +1,484 @@
+package driver
+
+import (
+	"encoding/json"
+	"hash/crc64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FloatTech/ttl"
+	"github.com/RomiChan/websocket"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/utils/helper"
+)
+
+// Satori 信令
+const (
+	satoriOpEvent = iota
+	satoriOpPing
+	satoriOpPong
+	satoriOpIdentify
+	satoriOpReady
+)
+
+const (
+	// satoriMessageTTL 非数字消息 ID 与消息所在频道的记录时长
+	satoriMessageTTL = 24 * time.Hour
+	// satoriIDTTL 非数字的用户, 频道与群组 ID 以及频道与群组对应关系的记录时长, 每次出现时刷新
+	satoriIDTTL = 7 * 24 * time.Hour
+)
+
+var crc64Table = crc64.MakeTable(crc64.ISO)
+
+// satoriIDs Satori 的字符串 ID 与 zero 中 int64 ID 的对应
+//
+// 数字 ID 直接转换, 其余以 crc64 映射到 2^32 以上, 与 guild 消息的处理相同
+type satoriIDs struct {
+	strs *ttl.Cache[int64, string] // 用户, 频道与群组
+	msgs *ttl.Cache[int64, string]
+}
+
+func newSatoriIDs() *satoriIDs {
+	return &satoriIDs{strs: ttl.NewCache[int64, string](satoriIDTTL), msgs: ttl.NewCache[int64, string](satoriMessageTTL)}
+}
+
+func satoriHash(s string) int64 {
+	r := int64(crc64.Checksum(helper.StringToBytes(s), crc64Table) & 0x7fff_ffff_ffff_ffff)
+	if r <= 0xffff_ffff {
+		r |= 0x1_0000_0000 // 确保不与正常号码重叠
+	}
+	return r
+}
+
+// id 将 Satori ID 转为 int64
+func (ids *satoriIDs) id(s string) int64 {
+	if s == "" {
+		return 0
+	}
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil && id > 0 && strconv.FormatInt(id, 10) == s {
+		return id
+	}
+	r := satoriHash(s)
+	ids.strs.Set(r, s)
+	return r
+}
+
+// str 将 int64 转回 Satori ID
+func (ids *satoriIDs) str(id int64) string {
+	if s := ids.strs.Get(id); s != "" {
+		return s
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// msgID 将 Satori 消息 ID 转为 int64
+func (ids *satoriIDs) msgID(s string) int64 {
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil && id > 0 && strconv.FormatInt(id, 10) == s {
+		return id
+	}
+	r := satoriHash(s)
+	ids.msgs.Set(r, s)
+	return r
+}
+
+// msgStr 将 int64 转回 Satori 消息 ID
+func (ids *satoriIDs) msgStr(id int64) string {
+	if s := ids.msgs.Get(id); s != "" {
+		return s
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// Satori 连接 Satori 协议实现端的驱动, 将事件转为 OneBot 11 格式, 并由 SatoriCaller 翻译 API 调用
+//
+// 群聊使用频道 ID 作为 group_id, 成员与管理操作使用该频道所属的群组 (guild);
+// 只含群组的事件 (如成员变动与加群请求) 使用该群组最近出现的频道, 未知时使用群组 ID.
+// 非数字 ID 映射为 2^32 以上的数字
+type Satori struct {
+	URL   string     // 服务地址, 如 http://127.0.0.1:5500, 不含 /v1
+	Token string     // 鉴权令牌
+	TLS   *TLSConfig // https:// 与 wss:// 的客户端配置
+
+	mu       sync.Mutex // 写锁
+	conn     *websocket.Conn
+	seq      int64 // 最后收到的事件序号, 用于断线恢复
+	client   httpClient
+	ids      *satoriIDs
+	stateMu  sync.Mutex
+	callers  map[string]*SatoriCaller   // platform/self_id
+	guilds   *ttl.Cache[string, string] // 频道到群组
+	gchans   *ttl.Cache[string, string] // 群组到最近的频道
+	dms      *ttl.Cache[string, string] // 用户到私聊频道
+	channels *ttl.Cache[int64, string]  // 消息到频道
+}
+
+// NewSatori 连接 url 的 Satori 驱动
+func NewSatori(url, token string) *Satori {
+	return &Satori{URL: strings.TrimSuffix(url, "/"), Token: token}
+}
+
+func (s *Satori) init() {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.ids == nil {
+		s.ids = newSatoriIDs()
+		s.callers = map[string]*SatoriCaller{}
+		s.guilds = ttl.NewCache[string, string](satoriIDTTL)
+		s.gchans = ttl.NewCache[string, string](satoriIDTTL)
+		s.dms = ttl.NewCache[string, string](satoriIDTTL)
+		s.channels = ttl.NewCache[int64, string](satoriMessageTTL)
+	}
+}
+
+// Connect 连接事件推送并鉴权
+func (s *Satori) Connect() {
+	s.init()
+	address := s.URL + "/v1/events"
+	switch {
+	case strings.HasPrefix(address, "https://"):
+		address = "wss://" + address[len("https://"):]
+	case strings.HasPrefix(address, "http://"):
+		address = "ws://" + address[len("http://"):]
+	}
+	zero.Log("satori").Info("开始尝试连接到 Satori 服务器", zero.F("url", address))
+	dialer := websocket.Dialer{WriteBufferPool: &wspool}
+	for {
+		if s.TLS != nil && dialer.TLSClientConfig == nil {
+			cfg, err := s.TLS.ClientConfig()
+			if err != nil {
+				metricConnectErrors.With("satori", s.URL).Inc()
+				zero.Log("satori").Error("加载 TLS 配置失败", zero.F("url", s.URL), zero.F(zero.FieldError, err))
+				time.Sleep(2 * time.Second) // 等待两秒后重试
+				continue
+			}
+			dialer.TLSClientConfig = cfg
+		}
+		conn, res, err := dialer.Dial(address, http.Header{"User-Agent": []string{"ZeroBot/1.6.3"}})
+		if err != nil {
+			metricConnectErrors.With("satori", s.URL).Inc()
+			zero.Log("satori").Warn("连接到 Satori 服务器时出现错误", zero.F("url", s.URL), zero.F(zero.FieldError, err))
+			time.Sleep(2 * time.Second) // 等待两秒后重新连接
+			continue
+		}
+		_ = res.Body.Close()
+		body := map[string]any{"token": s.Token}
+		if s.seq > 0 {
+			body["sequence"] = s.seq
+			body["sn"] = s.seq
+		}
+		err = conn.WriteJSON(map[string]any{"op": satoriOpIdentify, "body": body})
+		var ready struct {
+			Op   int             `json:"op"`
+			Body json.RawMessage `json:"body"`
+		}
+		if err == nil {
+			err = conn.ReadJSON(&ready)
+		}
+		if err != nil || ready.Op != satoriOpReady {
+			_ = conn.Close()
+			metricConnectErrors.With("satori", s.URL).Inc()
+			zero.Log("satori").Warn("与 Satori 服务器握手时出现错误", zero.F("url", s.URL), zero.F(zero.FieldError, err),
+				zero.F("op", ready.Op))
+			time.Sleep(2 * time.Second) // 等待两秒后重新连接
+			continue
+		}
+		s.mu.Lock()
+		s.conn = conn
+		s.mu.Unlock()
+		gjson.GetBytes(ready.Body, "logins").ForEach(func(_, login gjson.Result) bool {
+			s.login(login)
+			return true
+		})
+		go s.heartbeat(conn)
+		metricConnects.With("satori", s.URL).Inc()
+		zero.Log("satori").Info("连接 Satori 服务器成功", zero.F("url", s.URL))
+		return
+	}
+}
+
+// heartbeat 每 10 秒发送一次 PING, 连接被替换或断开后退出
+func (s *Satori) heartbeat(conn *websocket.Conn) {
+	t := time.NewTicker(10 * time.Second)
+	defer t.Stop()
+	for range t.C {
+		s.mu.Lock()
+		if s.conn != conn {
+			s.mu.Unlock()
+			return
+		}
+		err := conn.WriteJSON(map[string]int{"op": satoriOpPing})
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// loginSelf 返回 login 的平台与账号, 兼容 v1 的 self_id 与 v1.1 的 user.id
+func loginSelf(login gjson.Result) (platform, selfID string) {
+	selfID = login.Get("self_id").String()
+	if selfID == "" {
+		selfID = login.Get("user.id").String()
+	}
+	return login.Get("platform").Str, selfID
+}
+
+// login 登记 login 资源中的账号
+func (s *Satori) login(login gjson.Result) *SatoriCaller {
+	return s.caller(loginSelf(login))
+}
+
+// caller 返回账号的 APICaller, 未登记时登记
+func (s *Satori) caller(platform, selfID string) *SatoriCaller {
+	if selfID == "" {
+		return nil
+	}
+	s.stateMu.Lock()
+	c, ok := s.callers[platform+"/"+selfID]
+	if !ok {
+		c = &SatoriCaller{s: s, Platform: platform, SelfID: selfID}
+		s.callers[platform+"/"+selfID] = c
+	}
+	s.stateMu.Unlock()
+	if !ok {
+		zero.AddCaller(s.ids.id(selfID), c)
+		zero.Log("satori").Info("已登记账号", zero.F("platform", platform), zero.F(zero.FieldSelfID, selfID))
+	}
+	return c
+}
+
+// logout 移除账号
+func (s *Satori) logout(platform, selfID string) {
+	s.stateMu.Lock()
+	c, ok := s.callers[platform+"/"+selfID]
+	delete(s.callers, platform+"/"+selfID)
+	s.stateMu.Unlock()
+	if ok {
+		zero.RemoveCaller(s.ids.id(selfID), c)
+	}
+}
+
+// Listen 开始监听事件, 断开后重新连接并以最后的序号恢复
+func (s *Satori) Listen(handler func([]byte, zero.APICaller)) {
+	for {
+		s.mu.Lock()
+		conn := s.conn
+		s.mu.Unlock()
+		t, payload, err := conn.ReadMessage()
+		if err != nil { // reconnect
+			s.stateMu.Lock()
+			callers := s.callers
+			s.callers = map[string]*SatoriCaller{}
+			s.stateMu.Unlock()
+			for _, c := range callers {
+				zero.RemoveCaller(s.ids.id(c.SelfID), c)
+			}
+			metricDisconnects.With("satori", s.URL).Inc()
+			zero.Log("satori").Warn("Satori 服务器连接断开", zero.F("url", s.URL), zero.F(zero.FieldError, err))
+			time.Sleep(time.Millisecond * time.Duration(3))
+			s.Connect()
+			continue
+		}
+		if t != websocket.TextMessage {
+			continue
+		}
+		sig := gjson.ParseBytes(payload)
+		if sig.Get("op").Int() != satoriOpEvent {
+			continue
+		}
+		body := sig.Get("body")
+		if sn := body.Get("sn"); sn.Exists() {
+			s.seq = sn.Int()
+		} else if id := body.Get("id"); id.Type == gjson.Number {
+			s.seq = id.Int()
+		}
+		zero.Log("satori").Debug("接收到事件", zero.F("payload", helper.BytesToString(payload)))
+		caller, event := s.event(body)
+		if event != nil && caller != nil {
+			handler(event, caller)
+		}
+	}
+}
+
+// event 将 Satori 事件转为 OneBot 11 事件, 不需处理时返回 nil
+func (s *Satori) event(body gjson.Result) (*SatoriCaller, []byte) {
+	typ := body.Get("type").Str
+	platform := body.Get("platform").Str
+	selfID := body.Get("self_id").String()
+	if selfID == "" {
+		selfID = body.Get("login.user.id").String()
+	}
+	switch typ {
+	case "login-added", "login-updated":
+		s.login(body.Get("login"))
+		return nil, nil
+	case "login-removed":
+		s.logout(loginSelf(body.Get("login")))
+		return nil, nil
+	}
+	caller := s.caller(platform, selfID)
+	ids := s.ids
+	userID := body.Get("user.id").Str
+	channelID := body.Get("channel.id").Str
+	guildID := body.Get("guild.id").Str
+	direct := body.Get("channel.type").Int() == 1
+	if channelID != "" && guildID != "" && !direct {
+		s.guilds.Set(channelID, guildID)
+		s.gchans.Set(guildID, channelID)
+	}
+	if direct && channelID != "" && userID != "" && userID != selfID {
+		s.dms.Set(userID, channelID)
+	}
+	ev := map[string]any{
+		"time":    body.Get("timestamp").Int() / 1000,
+		"self_id": ids.id(selfID),
+		"user_id": ids.id(userID),
+		"satori":  json.RawMessage(body.Raw), // 原始事件
+	}
+	if channelID == "" {
+		channelID = s.channelOf(guildID)
+	}
+	groupID := ids.id(channelID)
+	if !direct && groupID != 0 {
+		ev["group_id"] = groupID
+	}
+	if op := body.Get("operator.id").Str; op != "" {
+		ev["operator_id"] = ids.id(op)
+	}
+	msgID := body.Get("message.id").Str
+	if msgID != "" && channelID != "" {
+		s.channels.Set(ids.msgID(msgID), channelID)
+	}
+	switch typ {
+	case "message-created":
+		msg := ids.fromSatori(body.Get("message.content").Str)
+		ev["post_type"] = "message"
+		if userID == selfID {
+			ev["post_type"] = "message_sent"
+		}
+		ev["message_id"] = ids.msgID(msgID)
+		ev["message"] = msg
+		ev["raw_message"] = msg.CQString()
+		ev["font"] = 0
+		sender := map[string]any{
+			"user_id":  ids.id(userID),
+			"nickname": body.Get("user.name").Str,
+			"card":     body.Get("member.nick").Str,
+		}
+		if direct {
+			ev["message_type"] = "private"
+			ev["sub_type"] = "friend"
+		} else {
+			ev["message_type"] = "group"
+			ev["sub_type"] = "normal"
+			sender["role"] = satoriRole(body.Get("member"))
+		}
+		ev["sender"] = sender
+		return caller, marshalEvent(ev)
+	case "message-deleted":
+		ev["post_type"] = "notice"
+		ev["notice_type"] = "group_recall"
+		if direct {
+			ev["notice_type"] = "friend_recall"
+		}
+		ev["message_id"] = ids.msgID(msgID)
+	case "guild-member-added":
+		ev["post_type"] = "notice"
+		ev["notice_type"] = "group_increase"
+		ev["sub_type"] = "approve"
+	case "guild-member-removed":
+		ev["post_type"] = "notice"
+		ev["notice_type"] = "group_decrease"
+		ev["sub_type"] = "leave"
+		if op := body.Get("operator.id").Str; op != "" && op != userID {
+			ev["sub_type"] = "kick"
+		}
+	case "friend-request":
+		ev["post_type"] = "request"
+		ev["request_type"] = "friend"
+		ev["flag"] = msgID
+		ev["comment"] = body.Get("message.content").Str
+	case "guild-member-request":
+		ev["post_type"] = "request"
+		ev["request_type"] = "group"
+		ev["sub_type"] = "add"
+		ev["flag"] = msgID
+		ev["comment"] = body.Get("message.content").Str
+	case "guild-request":
+		ev["post_type"] = "request"
+		ev["request_type"] = "group"
+		ev["sub_type"] = "invite"
+		ev["flag"] = msgID
+	default: // 其余事件以 Satori 的类型作为 notice_type
+		ev["post_type"] = "notice"
+		ev["notice_type"] = typ
+	}
+	return caller, marshalEvent(ev)
+}
+
+func marshalEvent(ev map[string]any) []byte {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		zero.Log("satori").Warn("序列化事件失败", zero.F(zero.FieldError, err))
+		return nil
+	}
+	return b
+}
+
+// guildOf 返回频道所属的群组, 未知时视为与频道相同
+func (s *Satori) guildOf(channelID string) string {
+	if g := s.guilds.Get(channelID); g != "" {
+		return g
+	}
+	return channelID
+}
+
+// channelOf 返回群组最近出现的频道, 未知时视为与群组相同
+func (s *Satori) channelOf(guildID string) string {
+	if ch := s.gchans.Get(guildID); ch != "" {
+		return ch
+	}
+	return guildID
+}
+
+// satoriRole 由成员的角色得出 OneBot 的 owner, admin 或 member
+//
+// roles 可为角色 ID 或名称的字符串, 或含 id 与 name 的对象
+func satoriRole(member gjson.Result) string {
+	role := "member"
+	member.Get("roles").ForEach(func(_, r gjson.Result) bool {
+		names := []string{r.String()}
+		if r.IsObject() {
+			names = []string{r.Get("id").Str, r.Get("name").Str}
+		}
+		for _, name := range names {
+			switch strings.ToLower(name) {
+			case "owner":
+				role = "owner"
+				return false
+			case "admin", "administrator":
+				role = "admin"
+			}
+		}
+		return true
+	})
+	return role
+}
+
+// Status implements zero.DriverStatus
+func (s *Satori) Status() zero.DriverState {
+	s.stateMu.Lock()
+	ids := make([]int64, 0, len(s.callers))
+	for _, c := range s.callers {
+		ids = append(ids, s.ids.id(c.SelfID))
+	}
+	s.stateMu.Unlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return zero.DriverState{Type: "satori", URL: s.URL, Connected: len(ids) > 0, SelfIDs: ids}
+}
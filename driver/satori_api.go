@@ -0,0 +1,282 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// satoriMaxPages 分页接口最多读取的页数
+const satoriMaxPages = 100
+
+// SatoriCaller 将 OneBot 11 的 API 调用翻译为 Satori 的 HTTP API
+//
+// 支持收发与撤回消息, 查询账号, 用户, 群组与成员, 踢出与禁言成员, 处理好友与加群请求;
+// 其余 action 返回 retcode 1404
+type SatoriCaller struct {
+	s        *Satori
+	Platform string
+	SelfID   string
+}
+
+// errSatoriUnknownMessage 撤回或获取不在记录中的消息
+var errSatoriUnknownMessage = errors.New("satori: unknown message")
+
+// call 调用 Satori 的 HTTP API
+func (c *SatoriCaller) call(ctx context.Context, method string, body any) (gjson.Result, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.s.URL+"/v1/"+method, bytes.NewReader(payload))
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ZeroBot/1.6.3")
+	req.Header.Set("X-Platform", c.Platform)
+	req.Header.Set("X-Self-ID", c.SelfID)
+	req.Header.Set("Satori-Platform", c.Platform)
+	req.Header.Set("Satori-User-ID", c.SelfID)
+	if c.s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.s.Token)
+	}
+	client, err := c.s.client.get(c.s.TLS)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return gjson.Result{}, fmt.Errorf("satori: %s returned %d: %s", method, resp.StatusCode, content)
+	}
+	return gjson.ParseBytes(content), nil
+}
+
+// list 读取分页接口的所有数据
+func (c *SatoriCaller) list(ctx context.Context, method string, body map[string]any) ([]gjson.Result, error) {
+	var all []gjson.Result
+	for i := 0; i < satoriMaxPages; i++ {
+		rsp, err := c.call(ctx, method, body)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, rsp.Get("data").Array()...)
+		next := rsp.Get("next").Str
+		if next == "" {
+			break
+		}
+		body["next"] = next
+	}
+	return all, nil
+}
+
+// channel 返回 OneBot 目标对应的 Satori 频道, 私聊频道不存在时创建
+func (c *SatoriCaller) channel(ctx context.Context, groupID, userID int64) (string, error) {
+	ids := c.s.ids
+	if groupID != 0 {
+		return ids.str(groupID), nil
+	}
+	user := ids.str(userID)
+	if ch := c.s.dms.Get(user); ch != "" {
+		return ch, nil
+	}
+	rsp, err := c.call(ctx, "user.channel.create", map[string]any{"user_id": user})
+	if err != nil {
+		return "", err
+	}
+	ch := rsp.Get("id").Str
+	c.s.dms.Set(user, ch)
+	return ch, nil
+}
+
+// member 将 Satori 的 GuildMember 转为 OneBot 的群成员信息
+func (c *SatoriCaller) member(groupID int64, m gjson.Result) map[string]any {
+	name := m.Get("user.name").Str
+	if name == "" {
+		name = m.Get("user.nick").Str
+	}
+	return map[string]any{
+		"group_id":  groupID,
+		"user_id":   c.s.ids.id(m.Get("user.id").Str),
+		"nickname":  name,
+		"card":      m.Get("nick").Str,
+		"role":      satoriRole(m),
+		"join_time": m.Get("joined_at").Int() / 1000,
+	}
+}
+
+// CallAPI 翻译并调用 API
+func (c *SatoriCaller) CallAPI(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	data, err := c.do(ctx, req)
+	if err != nil {
+		zero.Log("satori").Warn("调用 Satori API 失败", zero.F(zero.FieldAction, req.Action), zero.F(zero.FieldError, err))
+		return zero.APIResponse{Status: "failed", RetCode: 100, Message: err.Error()}, err
+	}
+	if data == nil {
+		return zero.APIResponse{Status: "failed", RetCode: 1404, Message: "satori: unsupported action " + req.Action}, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nullResponse, err
+	}
+	return zero.APIResponse{Status: "ok", Data: gjson.ParseBytes(b)}, nil
+}
+
+// do 执行 action, 不支持时返回 nil
+func (c *SatoriCaller) do(ctx context.Context, req zero.APIRequest) (any, error) {
+	ids := c.s.ids
+	p := req.Params
+	groupID, userID := paramInt(p["group_id"]), paramInt(p["user_id"])
+	guild := c.s.guildOf(ids.str(groupID))
+	switch req.Action {
+	case "send_msg", "send_group_msg", "send_private_msg":
+		if req.Action == "send_private_msg" || p["message_type"] == "private" {
+			groupID = 0
+		}
+		ch, err := c.channel(ctx, groupID, userID)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err := c.call(ctx, "message.create", map[string]any{
+			"channel_id": ch,
+			"content":    ids.toSatori(paramMessage(p["message"])),
+		})
+		if err != nil {
+			return nil, err
+		}
+		var id int64
+		if msgID := rsp.Get("0.id").Str; msgID != "" {
+			id = ids.msgID(msgID)
+			c.s.channels.Set(id, ch)
+		}
+		return map[string]any{"message_id": id}, nil
+	case "delete_msg", "get_msg":
+		id := paramInt(p["message_id"])
+		ch := c.s.channels.Get(id)
+		if ch == "" {
+			return nil, errSatoriUnknownMessage
+		}
+		body := map[string]any{"channel_id": ch, "message_id": ids.msgStr(id)}
+		if req.Action == "delete_msg" {
+			_, err := c.call(ctx, "message.delete", body)
+			return map[string]any{}, err
+		}
+		rsp, err := c.call(ctx, "message.get", body)
+		if err != nil {
+			return nil, err
+		}
+		msg := ids.fromSatori(rsp.Get("content").Str)
+		typ := "group"
+		if rsp.Get("channel.type").Int() == 1 {
+			typ = "private"
+		}
+		return map[string]any{
+			"message_id":   id,
+			"message_type": typ,
+			"time":         rsp.Get("created_at").Int() / 1000,
+			"message":      msg,
+			"raw_message":  msg.CQString(),
+			"sender": map[string]any{
+				"user_id":  ids.id(rsp.Get("user.id").Str),
+				"nickname": rsp.Get("user.name").Str,
+			},
+		}, nil
+	case "get_login_info":
+		rsp, err := c.call(ctx, "login.get", map[string]any{})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"user_id": ids.id(c.SelfID), "nickname": rsp.Get("user.name").Str}, nil
+	case "get_stranger_info":
+		rsp, err := c.call(ctx, "user.get", map[string]any{"user_id": ids.str(userID)})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"user_id": userID, "nickname": rsp.Get("name").Str, "sex": "unknown", "age": 0}, nil
+	case "get_friend_list":
+		users, err := c.list(ctx, "friend.list", map[string]any{})
+		if err != nil {
+			return nil, err
+		}
+		friends := make([]map[string]any, 0, len(users))
+		for _, u := range users {
+			friends = append(friends, map[string]any{"user_id": ids.id(u.Get("id").Str), "nickname": u.Get("name").Str, "remark": u.Get("nick").Str})
+		}
+		return friends, nil
+	case "get_group_info":
+		rsp, err := c.call(ctx, "guild.get", map[string]any{"guild_id": guild})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"group_id": groupID, "group_name": rsp.Get("name").Str}, nil
+	case "get_group_list":
+		guilds, err := c.list(ctx, "guild.list", map[string]any{})
+		if err != nil {
+			return nil, err
+		}
+		groups := make([]map[string]any, 0, len(guilds))
+		for _, g := range guilds {
+			groups = append(groups, map[string]any{"group_id": ids.id(c.s.channelOf(g.Get("id").Str)), "group_name": g.Get("name").Str})
+		}
+		return groups, nil
+	case "get_group_member_info":
+		rsp, err := c.call(ctx, "guild.member.get", map[string]any{"guild_id": guild, "user_id": ids.str(userID)})
+		if err != nil {
+			return nil, err
+		}
+		m := c.member(groupID, rsp)
+		if !rsp.Get("user.id").Exists() { // 部分实现不返回 user
+			m["user_id"] = userID
+		}
+		return m, nil
+	case "get_group_member_list":
+		ms, err := c.list(ctx, "guild.member.list", map[string]any{"guild_id": guild})
+		if err != nil {
+			return nil, err
+		}
+		members := make([]map[string]any, 0, len(ms))
+		for _, m := range ms {
+			members = append(members, c.member(groupID, m))
+		}
+		return members, nil
+	case "set_group_kick":
+		reject, _ := p["reject_add_request"].(bool)
+		_, err := c.call(ctx, "guild.member.kick", map[string]any{"guild_id": guild, "user_id": ids.str(userID), "permanent": reject})
+		return map[string]any{}, err
+	case "set_group_ban":
+		// Satori 的禁言时长以毫秒为单位
+		_, err := c.call(ctx, "guild.member.mute", map[string]any{"guild_id": guild, "user_id": ids.str(userID), "duration": paramInt(p["duration"]) * 1000})
+		return map[string]any{}, err
+	case "set_friend_add_request":
+		approve, _ := p["approve"].(bool)
+		remark, _ := p["remark"].(string)
+		_, err := c.call(ctx, "friend.approve", map[string]any{"message_id": p["flag"], "approve": approve, "comment": remark})
+		return map[string]any{}, err
+	case "set_group_add_request":
+		approve, _ := p["approve"].(bool)
+		reason, _ := p["reason"].(string)
+		method := "guild.member.approve"
+		if p["sub_type"] == "invite" || p["type"] == "invite" {
+			method = "guild.approve"
+		}
+		_, err := c.call(ctx, method, map[string]any{"message_id": p["flag"], "approve": approve, "comment": reason})
+		return map[string]any{}, err
+	}
+	return nil, nil
+}
@@ -0,0 +1,144 @@
+package driver
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+var satoriEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// satoriMedia 将 OneBot 的 file 转为 Satori 的 src
+func satoriMedia(file, mime string) string {
+	if b64, ok := strings.CutPrefix(file, "base64://"); ok {
+		return "data:" + mime + ";base64," + b64
+	}
+	return file
+}
+
+// toSatori 将 message.Message 转为 Satori 消息元素
+func (ids *satoriIDs) toSatori(m message.Message) string {
+	sb := strings.Builder{}
+	elem := func(tag string, attrs ...string) {
+		sb.WriteString("<" + tag)
+		for i := 0; i+1 < len(attrs); i += 2 {
+			sb.WriteString(" " + attrs[i] + `="` + satoriEscaper.Replace(attrs[i+1]) + `"`)
+		}
+		sb.WriteString("/>")
+	}
+	for _, seg := range m {
+		d := seg.Data
+		switch seg.Type {
+		case "text":
+			sb.WriteString(satoriEscaper.Replace(d["text"]))
+		case "at":
+			if d["qq"] == "all" {
+				elem("at", "type", "all")
+				continue
+			}
+			id, _ := strconv.ParseInt(d["qq"], 10, 64)
+			elem("at", "id", ids.str(id))
+		case "image":
+			elem("img", "src", satoriMedia(d["file"], "image/png"))
+		case "record":
+			elem("audio", "src", satoriMedia(d["file"], "audio/amr"))
+		case "video":
+			elem("video", "src", satoriMedia(d["file"], "video/mp4"))
+		case "file":
+			elem("file", "src", satoriMedia(d["file"], "application/octet-stream"), "title", d["name"])
+		case "reply":
+			id, _ := strconv.ParseInt(d["id"], 10, 64)
+			elem("quote", "id", ids.msgStr(id))
+		case "face":
+			elem("face", "id", d["id"])
+		default:
+			// Satori 没有对应的元素, 以文本代替
+			sb.WriteString(satoriEscaper.Replace(message.Message{seg}.Console(nil)))
+		}
+	}
+	return sb.String()
+}
+
+// fromSatori 将 Satori 消息元素转为 message.Message, 不认识的元素只保留其中的文本
+func (ids *satoriIDs) fromSatori(content string) message.Message {
+	m := message.Message{}
+	text := func(s string) {
+		if s == "" {
+			return
+		}
+		if n := len(m); n > 0 && m[n-1].Type == "text" {
+			m[n-1].Data["text"] += s
+			return
+		}
+		m = append(m, message.Text(s))
+	}
+	d := xml.NewDecoder(strings.NewReader("<root>" + content + "</root>"))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	skip := 0 // 忽略 quote, author 等元素的子元素
+	for {
+		tok, err := d.Token()
+		if err != nil { // io.EOF
+			break
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			if skip == 0 {
+				text(string(t))
+			}
+		case xml.EndElement:
+			switch {
+			case skip > 0:
+				skip--
+			case t.Name.Local == "p":
+				text("\n")
+			}
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			attr := map[string]string{}
+			for _, a := range t.Attr {
+				attr[a.Name.Local] = a.Value
+			}
+			switch t.Name.Local {
+			case "at":
+				switch {
+				case attr["type"] == "all" || attr["type"] == "here":
+					m = append(m, message.AtAll())
+				case attr["id"] != "":
+					m = append(m, message.At(ids.id(attr["id"])))
+				default:
+					text("@" + attr["name"])
+				}
+			case "sharp":
+				text("#" + attr["name"])
+			case "img", "image":
+				m = append(m, message.Segment{Type: "image", Data: map[string]string{"file": attr["src"], "url": attr["src"]}})
+			case "audio":
+				m = append(m, message.Segment{Type: "record", Data: map[string]string{"file": attr["src"], "url": attr["src"]}})
+			case "video":
+				m = append(m, message.Segment{Type: "video", Data: map[string]string{"file": attr["src"], "url": attr["src"]}})
+			case "file":
+				m = append(m, message.Segment{Type: "file", Data: map[string]string{"file": attr["src"], "url": attr["src"], "name": attr["title"]}})
+			case "face":
+				id, _ := strconv.Atoi(attr["id"])
+				m = append(m, message.Face(id))
+			case "br":
+				text("\n")
+			case "quote":
+				if attr["id"] != "" {
+					m = append(m, message.Segment{Type: "reply", Data: map[string]string{"id": strconv.FormatInt(ids.msgID(attr["id"]), 10)}})
+				}
+				skip = 1
+			case "author":
+				skip = 1
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,130 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+func TestSatoriIDs(t *testing.T) {
+	ids := newSatoriIDs()
+	assert.Equal(t, int64(0), ids.id(""))
+	assert.Equal(t, int64(12345), ids.id("12345"))
+	assert.Equal(t, "12345", ids.str(12345))
+
+	// 非数字与带前导 0 的 ID 映射到 2^32 以上, 并可转回
+	for _, s := range []string{"abc", "0123", "-1"} {
+		id := ids.id(s)
+		assert.Greater(t, id, int64(0xffff_ffff), s)
+		assert.Equal(t, s, ids.str(id))
+	}
+	msg := ids.msgID("m-1")
+	assert.Greater(t, msg, int64(0xffff_ffff))
+	assert.Equal(t, "m-1", ids.msgStr(msg))
+	assert.Equal(t, int64(42), ids.msgID("42"))
+	assert.Equal(t, "42", ids.msgStr(42))
+}
+
+func TestSatoriMessage(t *testing.T) {
+	ids := newSatoriIDs()
+	user := ids.id("u1")
+	reply := ids.msgID("m1")
+	m := message.Message{
+		message.Text("a<b & \"c\""), message.At(user), message.At(10001), message.AtAll(),
+		message.Image("base64://AAAA"), message.Record("https://a/b.amr"), message.Reply(reply), message.Face(14),
+	}
+	s := ids.toSatori(m)
+	assert.Equal(t, `a&lt;b &amp; &quot;c&quot;<at id="u1"/><at id="10001"/><at type="all"/><img src="data:image/png;base64,AAAA"/>`+
+		`<audio src="https://a/b.amr"/><quote id="m1"/><face id="14"/>`, s)
+
+	back := ids.fromSatori(s)
+	assert.Equal(t, `a<b & "c"`, back[0].Data["text"])
+	assert.Equal(t, message.At(user), back[1])
+	assert.Equal(t, message.At(10001), back[2])
+	assert.Equal(t, message.AtAll(), back[3])
+	assert.Equal(t, "image", back[4].Type)
+	assert.Equal(t, "data:image/png;base64,AAAA", back[4].Data["file"])
+	assert.Equal(t, "record", back[5].Type)
+	assert.Equal(t, message.Reply(reply).Data, back[6].Data)
+	assert.Equal(t, message.Face(14), back[7])
+
+	// 段落与换行转为文本, quote 与 author 的内容被忽略, 未知元素只保留文本
+	back = ids.fromSatori(`<quote id="m1"><author id="u1"/>old</quote><p>x</p>y<br/><b>z</b><sharp id="c" name="ch"/>`)
+	assert.Len(t, back, 2)
+	assert.Equal(t, "reply", back[0].Type)
+	assert.Equal(t, "x\ny\nz#ch", back[1].Data["text"])
+}
+
+func TestSatoriRole(t *testing.T) {
+	assert.Equal(t, "member", satoriRole(gjson.Parse(`{}`)))
+	assert.Equal(t, "admin", satoriRole(gjson.Parse(`{"roles":["Admin","x"]}`)))
+	assert.Equal(t, "owner", satoriRole(gjson.Parse(`{"roles":["administrator","owner"]}`)))
+	assert.Equal(t, "admin", satoriRole(gjson.Parse(`{"roles":[{"id":"1","name":"Administrator"}]}`)))
+	assert.Equal(t, "owner", satoriRole(gjson.Parse(`{"roles":[{"id":"owner"}]}`)))
+}
+
+func TestSatoriEvent(t *testing.T) {
+	s := NewSatori("http://127.0.0.1:5500", "")
+	s.init()
+	defer s.logout("test", "50000")
+
+	// 群聊使用频道 ID 作为 group_id, 成员角色由 roles 得出
+	c, ev := s.event(gjson.Parse(`{"type":"message-created","platform":"test","self_id":"50000","timestamp":1700000000000,
+		"user":{"id":"50001","name":"alice"},"channel":{"id":"ch1","type":0},"guild":{"id":"g1"},
+		"member":{"nick":"A","roles":["admin"]},"message":{"id":"m1","content":"hi<at id=\"50000\"/>"}}`))
+	assert.NotNil(t, c)
+	e := gjson.ParseBytes(ev)
+	ch := s.ids.id("ch1")
+	assert.Equal(t, "message", e.Get("post_type").Str)
+	assert.Equal(t, "group", e.Get("message_type").Str)
+	assert.Equal(t, int64(1700000000), e.Get("time").Int())
+	assert.Equal(t, int64(50000), e.Get("self_id").Int())
+	assert.Equal(t, ch, e.Get("group_id").Int())
+	assert.Equal(t, s.ids.msgID("m1"), e.Get("message_id").Int())
+	assert.Equal(t, "admin", e.Get("sender.role").Str)
+	assert.Equal(t, "A", e.Get("sender.card").Str)
+	assert.Equal(t, "hi[CQ:at,qq=50000]", e.Get("raw_message").Str)
+	assert.Equal(t, "g1", s.guildOf("ch1"))
+	v, ok := zero.APICallers.Load(int64(50000))
+	assert.True(t, ok)
+	assert.True(t, v.(*zero.CallerGroup).Contains(c))
+
+	// 只含群组的事件使用该群组最近的频道
+	_, ev = s.event(gjson.Parse(`{"type":"guild-member-added","platform":"test","self_id":"50000",
+		"user":{"id":"50002"},"guild":{"id":"g1"}}`))
+	e = gjson.ParseBytes(ev)
+	assert.Equal(t, "group_increase", e.Get("notice_type").Str)
+	assert.Equal(t, ch, e.Get("group_id").Int())
+	_, ev = s.event(gjson.Parse(`{"type":"guild-member-request","platform":"test","self_id":"50000",
+		"user":{"id":"50002"},"guild":{"id":"g1"},"message":{"id":"req1","content":"let me in"}}`))
+	e = gjson.ParseBytes(ev)
+	assert.Equal(t, "request", e.Get("post_type").Str)
+	assert.Equal(t, ch, e.Get("group_id").Int())
+	assert.Equal(t, "req1", e.Get("flag").Str)
+	// 未见过频道的群组使用群组 ID
+	_, ev = s.event(gjson.Parse(`{"type":"guild-member-removed","platform":"test","self_id":"50000",
+		"user":{"id":"50002"},"operator":{"id":"50001"},"guild":{"id":"g2"}}`))
+	e = gjson.ParseBytes(ev)
+	assert.Equal(t, "kick", e.Get("sub_type").Str)
+	assert.Equal(t, s.ids.id("g2"), e.Get("group_id").Int())
+
+	// 私聊没有 group_id, 并记录私聊频道
+	_, ev = s.event(gjson.Parse(`{"type":"message-created","platform":"test","self_id":"50000",
+		"user":{"id":"50001"},"channel":{"id":"dm1","type":1},"message":{"id":"m2","content":"yo"}}`))
+	e = gjson.ParseBytes(ev)
+	assert.Equal(t, "private", e.Get("message_type").Str)
+	assert.False(t, e.Get("group_id").Exists())
+	assert.False(t, e.Get("sender.role").Exists())
+	assert.Equal(t, "dm1", s.dms.Get("50001"))
+
+	// login 事件不交给 zero
+	c, ev = s.event(gjson.Parse(`{"type":"login-removed","login":{"platform":"test","self_id":"50000"}}`))
+	assert.Nil(t, c)
+	assert.Nil(t, ev)
+	_, ok = zero.APICallers.Load(int64(50000))
+	assert.False(t, ok)
+}
@@ -0,0 +1,70 @@
+package driver
+
+import (
+	"sort"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// connectedIDs 返回 APICallers 中有连接满足 match 的账号
+func connectedIDs(match func(zero.APICaller) bool) []int64 {
+	ids := []int64{}
+	zero.APICallers.Range(func(id int64, c zero.APICaller) bool {
+		callers := []zero.APICaller{c}
+		if g, ok := c.(*zero.CallerGroup); ok {
+			callers = g.Callers()
+		}
+		for _, c := range callers {
+			if matchUnwrap(c, match) {
+				ids = append(ids, id)
+				break
+			}
+		}
+		return true
+	})
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// matchUnwrap 依次对 c 及其包装的 APICaller 调用 match, 如 Recorder 的包装
+func matchUnwrap(c zero.APICaller, match func(zero.APICaller) bool) bool {
+	for c != nil {
+		if match(c) {
+			return true
+		}
+		w, ok := c.(zero.CallerWrapper)
+		if !ok {
+			return false
+		}
+		c = w.Unwrap()
+	}
+	return false
+}
+
+// Status implements zero.DriverStatus
+func (ws *WSClient) Status() zero.DriverState {
+	ids := connectedIDs(func(c zero.APICaller) bool { return c == ws })
+	return zero.DriverState{Type: "wsclient", URL: ws.URL, Connected: len(ids) > 0, SelfIDs: ids}
+}
+
+// Status implements zero.DriverStatus, 账号包括只有事件连接的账号
+func (wss *WSServer) Status() zero.DriverState {
+	seen := map[int64]bool{}
+	ids := []int64{}
+	wss.mu.Lock()
+	for slot := range wss.slots {
+		if !seen[slot.selfID] {
+			seen[slot.selfID] = true
+			ids = append(ids, slot.selfID)
+		}
+	}
+	wss.mu.Unlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return zero.DriverState{Type: "wsserver", URL: wss.URL, Connected: len(ids) > 0, SelfIDs: ids}
+}
+
+// Status implements zero.DriverStatus
+func (h *HTTP) Status() zero.DriverState {
+	ids := connectedIDs(func(c zero.APICaller) bool { return c == h.caller })
+	return zero.DriverState{Type: "http", URL: h.URL, Connected: len(ids) > 0, SelfIDs: ids}
+}
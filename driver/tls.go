@@ -0,0 +1,120 @@
+package driver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig 驱动的 TLS 配置, 文件均为 PEM 格式
+//
+// 作为服务端 (WSServer, HTTP) 时 CertFile 与 KeyFile 必填, 设置 CAFile 时要求并校验客户端证书 (mTLS);
+// 作为客户端 (WSClient, HTTPCaller) 时 CAFile 用于校验服务端证书, CertFile 与 KeyFile 为 mTLS 的客户端证书
+type TLSConfig struct {
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	CAFile             string `json:"ca_file"`
+	ServerName         string `json:"server_name"`          // 客户端校验的服务端名称, 为空时使用 URL 中的主机名
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 客户端不校验服务端证书, 仅用于测试
+}
+
+func (c *TLSConfig) certPool() (*x509.CertPool, error) {
+	pem, err := os.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("driver: no certificate found in " + c.CAFile)
+	}
+	return pool, nil
+}
+
+// ServerConfig 返回服务端使用的 tls.Config
+func (c *TLSConfig) ServerConfig() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("driver: tls server requires cert_file and key_file")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.CAFile != "" {
+		cfg.ClientCAs, err = c.certPool()
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// ClientConfig 返回客户端使用的 tls.Config
+func (c *TLSConfig) ClientConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec
+		MinVersion:         tls.VersionTLS12,
+	}
+	if c.CAFile != "" {
+		pool, err := c.certPool()
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// listenTLS 在 c 不为 nil 时将 l 包装为 TLS 监听
+func listenTLS(l net.Listener, c *TLSConfig) (net.Listener, error) {
+	if c == nil {
+		return l, nil
+	}
+	cfg, err := c.ServerConfig()
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return tls.NewListener(l, cfg), nil
+}
+
+// httpClient HTTPCaller 使用的客户端, 未配置 TLS 时为 http.DefaultClient
+type httpClient struct {
+	once   sync.Once
+	client *http.Client
+	err    error
+}
+
+func (h *httpClient) get(c *TLSConfig) (*http.Client, error) {
+	if c == nil {
+		return http.DefaultClient, nil
+	}
+	h.once.Do(func() {
+		cfg, err := c.ClientConfig()
+		if err != nil {
+			h.err = err
+			return
+		}
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.TLSClientConfig = cfg
+		h.client = &http.Client{Transport: tr, Timeout: 5 * time.Minute}
+	})
+	return h.client, h.err
+}
@@ -3,6 +3,7 @@ package driver
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -12,7 +13,6 @@ import (
 	"time"
 
 	"github.com/RomiChan/websocket"
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 
 	zero "github.com/wdvxdr1123/ZeroBot"
@@ -31,6 +31,7 @@ type WSClient struct {
 	seqMap      seqSyncMap
 	URL         string // ws连接地址
 	AccessToken string
+	TLS         *TLSConfig // wss:// 连接的客户端配置, 为 nil 时使用系统默认
 	selfID      int64
 }
 
@@ -44,7 +45,7 @@ func NewWebSocketClient(url, accessToken string) *WSClient {
 
 // Connect 连接ws服务端
 func (ws *WSClient) Connect() {
-	log.Infof("[ws] 开始尝试连接到Websocket服务器: %v", ws.URL)
+	zero.Log("ws").Info("开始尝试连接到Websocket服务器", zero.F("url", ws.URL))
 	header := http.Header{
 		"X-Client-Role": []string{"Universal"},
 		"User-Agent":    []string{"ZeroBot/1.6.3"},
@@ -72,9 +73,20 @@ func (ws *WSClient) Connect() {
 	}
 
 	for {
+		if ws.TLS != nil && dialer.TLSClientConfig == nil {
+			cfg, err := ws.TLS.ClientConfig()
+			if err != nil {
+				metricConnectErrors.With("wsclient", ws.URL).Inc()
+				zero.Log("ws").Error("加载 TLS 配置失败", zero.F("url", ws.URL), zero.F(zero.FieldError, err))
+				time.Sleep(2 * time.Second) // 等待两秒后重试
+				continue
+			}
+			dialer.TLSClientConfig = cfg
+		}
 		conn, res, err := dialer.Dial(address, header)
 		if err != nil {
-			log.Warnf("[ws] 连接到Websocket服务器 %v 时出现错误: %v", ws.URL, err)
+			metricConnectErrors.With("wsclient", ws.URL).Inc()
+			zero.Log("ws").Warn("连接到Websocket服务器时出现错误", zero.F("url", ws.URL), zero.F(zero.FieldError, err))
 			time.Sleep(2 * time.Second) // 等待两秒后重新连接
 			continue
 		}
@@ -85,13 +97,15 @@ func (ws *WSClient) Connect() {
 		}
 		err = ws.conn.ReadJSON(&rsp)
 		if err != nil {
-			log.Warnf("[ws] 与Websocket服务器 %v 握手时出现错误: %v", ws.URL, err)
+			metricConnectErrors.With("wsclient", ws.URL).Inc()
+			zero.Log("ws").Warn("与Websocket服务器握手时出现错误", zero.F("url", ws.URL), zero.F(zero.FieldError, err))
 			time.Sleep(2 * time.Second) // 等待两秒后重新连接
 			continue
 		}
 		ws.selfID = rsp.SelfID
-		zero.APICallers.Store(ws.selfID, ws) // 添加Caller到 APICaller list...
-		log.Infof("[ws] 连接Websocket服务器: %s 成功, 账号: %d", ws.URL, rsp.SelfID)
+		zero.AddCaller(ws.selfID, ws) // 添加Caller到 APICaller list...
+		metricConnects.With("wsclient", ws.URL).Inc()
+		zero.Log("ws").Info("连接Websocket服务器成功", zero.F("url", ws.URL), zero.F(zero.FieldSelfID, rsp.SelfID))
 		break
 	}
 }
@@ -101,8 +115,9 @@ func (ws *WSClient) Listen(handler func([]byte, zero.APICaller)) {
 	for {
 		t, payload, err := ws.conn.ReadMessage()
 		if err != nil { // reconnect
-			zero.APICallers.Delete(ws.selfID) // 断开从apicaller中删除
-			log.Warn("[ws] Websocket服务器连接断开...")
+			zero.RemoveCaller(ws.selfID, ws) // 断开从apicaller中删除
+			metricDisconnects.With("wsclient", ws.URL).Inc()
+			zero.Log("ws").Warn("Websocket服务器连接断开", zero.F("url", ws.URL), zero.F(zero.FieldSelfID, ws.selfID))
 			time.Sleep(time.Millisecond * time.Duration(3))
 			ws.Connect()
 			continue
@@ -112,7 +127,7 @@ func (ws *WSClient) Listen(handler func([]byte, zero.APICaller)) {
 		}
 		rsp := gjson.Parse(helper.BytesToString(payload))
 		if rsp.Get("echo").Exists() { // 存在echo字段，是api调用的返回
-			log.Debug("[ws] 接收到API调用返回: ", strings.TrimSpace(helper.BytesToString(payload)))
+			zero.Log("ws").Debug("接收到API调用返回", zero.F(zero.FieldSelfID, ws.selfID), zero.F("payload", strings.TrimSpace(helper.BytesToString(payload))))
 			if c, ok := ws.seqMap.LoadAndDelete(rsp.Get("echo").Uint()); ok {
 				msg := rsp.Get("message").Str
 				if msg == "" {
@@ -133,7 +148,7 @@ func (ws *WSClient) Listen(handler func([]byte, zero.APICaller)) {
 		if rsp.Get("meta_event_type").Str == "heartbeat" { // 忽略心跳事件
 			continue
 		}
-		log.Debug("[ws] 接收到事件: ", helper.BytesToString(payload))
+		zero.Log("ws").Debug("接收到事件", zero.F(zero.FieldSelfID, ws.selfID), zero.F("payload", helper.BytesToString(payload)))
 		handler(payload, ws)
 	}
 }
@@ -153,10 +168,12 @@ func (ws *WSClient) CallAPI(c context.Context, req zero.APIRequest) (zero.APIRes
 	err := ws.conn.WriteJSON(&req)
 	ws.mu.Unlock()
 	if err != nil {
-		log.Warn("[ws] 向WebsocketServer发送API请求失败: ", err.Error())
-		return nullResponse, err
+		zero.Log("ws").Warn("向WebsocketServer发送API请求失败", zero.F(zero.FieldAction, req.Action), zero.F(zero.FieldError, err))
+		return nullResponse, fmt.Errorf("%w: %w", zero.ErrNotSent, err)
+	}
+	if l := zero.Log("ws"); l.Enabled(zero.LevelDebug) {
+		l.Debug("向服务器发送请求", zero.F(zero.FieldSelfID, ws.selfID), zero.F("payload", req.String()))
 	}
-	log.Debug("[ws] 向服务器发送请求: ", &req)
 
 	select { // 等待数据返回
 	case rsp, ok := <-ch:
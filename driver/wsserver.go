@@ -3,10 +3,13 @@ package driver
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,7 +17,6 @@ import (
 	"unsafe"
 
 	"github.com/RomiChan/websocket"
-	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 
 	zero "github.com/wdvxdr1123/ZeroBot"
@@ -25,17 +27,38 @@ import (
 type WSServer struct {
 	URL         string // ws连接地址
 	AccessToken string
+	TLS         *TLSConfig  // 不为 nil 时以 TLS 监听, 可选校验客户端证书
+	Auth        *ServerAuth // 鉴权与限制, 为 nil 时使用默认限制
 	lstn        net.Listener
 	caller      chan *WSSCaller
 
+	authOnce sync.Once
+	mu       sync.Mutex
+	slots    map[wssSlot]*WSSCaller // 各账号当前的事件与 API 连接
+
 	json.Unmarshaler
 }
 
+// 反向 WS 连接的角色, 见 X-Client-Role
+const (
+	RoleUniversal = "Universal"
+	RoleEvent     = "Event"
+	RoleAPI       = "API"
+)
+
+// wssSlot 账号的一个连接位置, Universal 连接同时占用 event 与 api
+type wssSlot struct {
+	selfID int64
+	api    bool
+}
+
 // UnmarshalJSON init WSServer with waitn=16
 func (wss *WSServer) UnmarshalJSON(data []byte) error {
 	type jsoncfg struct {
 		URL         string // ws连接地址
 		AccessToken string
+		TLS         *TLSConfig
+		Auth        *ServerAuth
 	}
 	err := json.Unmarshal(data, (*jsoncfg)(unsafe.Pointer(wss)))
 	if err != nil {
@@ -61,12 +84,32 @@ type WSSCaller struct {
 	conn   *websocket.Conn
 	selfID int64
 	seq    uint64
+	url    string // 所属 WSServer 的地址
+	role   string
+	bound  bool // token 限定了账号, 丢弃其他账号的事件
+}
+
+// Role 返回连接的角色: Universal, Event 或 API
+func (wssc *WSSCaller) Role() string {
+	return wssc.role
 }
 
+// errNoAPIConn 账号只有事件连接时调用 API 返回的错误
+var errNoAPIConn = errors.New("wss: no API connection")
+
+// wssEventCaller 事件连接上的事件通过同一账号当前的 API 连接调用 API
+type wssEventCaller int64
+
+func (id wssEventCaller) CallAPI(c context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	caller, ok := zero.APICallers.Load(int64(id))
+	if !ok {
+		return nullResponse, errNoAPIConn
+	}
+	return caller.CallAPI(c, req)
+}
+
+// upgrader 使用默认的 CheckOrigin: 不带 Origin 头或与 Host 相同时放行
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(_ *http.Request) bool {
-		return true
-	},
 	WriteBufferPool: &wspool,
 }
 
@@ -79,73 +122,180 @@ func (wss *WSServer) Connect() {
 	}
 
 	listener, err := net.Listen(network, address)
+	if err == nil {
+		listener, err = listenTLS(listener, wss.TLS)
+	}
 	if err != nil {
-		log.Warn("[wss] Websocket服务器监听失败:", err)
+		zero.Log("wss").Warn("Websocket服务器监听失败", zero.F(zero.FieldError, err))
 		wss.lstn = nil
 		return
 	}
 
 	wss.lstn = listener
-	log.Infoln("[wss] Websocket服务器开始监听:", listener.Addr())
+	zero.Log("wss").Info("Websocket服务器开始监听", zero.F("addr", listener.Addr().String()))
 }
 
-func checkAuth(req *http.Request, token string) int {
-	if token == "" { // quick path
-		return http.StatusOK
-	}
-
-	auth := req.Header.Get("Authorization")
-	if auth == "" {
-		auth = req.URL.Query().Get("access_token")
-	} else {
-		_, after, ok := strings.Cut(auth, " ")
-		if ok {
-			auth = after
+func (wss *WSServer) auth() *ServerAuth {
+	wss.authOnce.Do(func() {
+		if wss.Auth == nil {
+			wss.Auth = &ServerAuth{}
 		}
-	}
+	})
+	return wss.Auth
+}
 
-	switch auth {
-	case token:
-		return http.StatusOK
-	case "":
-		return http.StatusUnauthorized
-	default:
-		return http.StatusForbidden
+// clientRole 由 X-Client-Role 或路径 (/event, /api) 判断连接角色
+func clientRole(r *http.Request) string {
+	switch strings.ToLower(r.Header.Get("X-Client-Role")) {
+	case "event":
+		return RoleEvent
+	case "api":
+		return RoleAPI
+	case "universal":
+		return RoleUniversal
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case strings.HasSuffix(path, "/event"):
+		return RoleEvent
+	case strings.HasSuffix(path, "/api"):
+		return RoleAPI
 	}
+	return RoleUniversal
 }
 
 func (wss *WSServer) any(w http.ResponseWriter, r *http.Request) {
-	status := checkAuth(r, wss.AccessToken)
+	a := wss.auth()
+	ip := remoteIP(r)
+	if status := a.admit(ip); status != http.StatusOK {
+		zero.Log("wss").Warn("已拒绝 WebSocket 请求: 来源受限", zero.F("remote", r.RemoteAddr), zero.F("code", status))
+		w.WriteHeader(status)
+		return
+	}
+	ids, status := a.checkToken(r, wss.AccessToken)
 	if status != http.StatusOK {
-		log.Warnf("[wss] 已拒绝 %v 的 WebSocket 请求: Token鉴权失败(code:%d)", r.RemoteAddr, status)
+		a.fail(ip)
+		zero.Log("wss").Warn("已拒绝 WebSocket 请求: Token鉴权失败", zero.F("remote", r.RemoteAddr), zero.F("code", status))
 		w.WriteHeader(status)
 		return
 	}
+	role := clientRole(r)
+	selfID, _ := strconv.ParseInt(r.Header.Get("X-Self-ID"), 10, 64)
+	if selfID == 0 && role == RoleAPI { // API 连接不会上报事件, 无法从首个事件获得账号
+		zero.Log("wss").Warn("已拒绝 WebSocket 请求: API 连接缺少 X-Self-ID", zero.F("remote", r.RemoteAddr))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if selfID != 0 && !permitted(ids, selfID) {
+		a.fail(ip)
+		zero.Log("wss").Warn("已拒绝 WebSocket 请求: Token 不允许该账号", zero.F("remote", r.RemoteAddr), zero.F(zero.FieldSelfID, selfID))
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Warnf("[wss] 处理 WebSocket 请求时出现错误: %v", err)
+		zero.Log("wss").Warn("处理 WebSocket 请求时出现错误", zero.F("remote", r.RemoteAddr), zero.F(zero.FieldError, err))
 		return
 	}
-
-	var rsp struct {
-		SelfID int64 `json:"self_id"`
+	if size := a.messageSize(); size > 0 {
+		conn.SetReadLimit(size)
 	}
-	err = conn.ReadJSON(&rsp)
-	if err != nil {
-		log.Warnf("[wss] 与Websocket服务器 %v 握手时出现错误: %v", wss.URL, err)
-		return
+
+	if selfID == 0 {
+		var rsp struct {
+			SelfID int64 `json:"self_id"`
+		}
+		err = conn.ReadJSON(&rsp)
+		if err != nil {
+			zero.Log("wss").Warn("与Websocket服务器握手时出现错误", zero.F("url", wss.URL), zero.F(zero.FieldError, err))
+			_ = conn.Close()
+			return
+		}
+		selfID = rsp.SelfID
+		if !permitted(ids, selfID) {
+			a.fail(ip)
+			zero.Log("wss").Warn("已拒绝 WebSocket 连接: Token 不允许该账号", zero.F("remote", r.RemoteAddr), zero.F(zero.FieldSelfID, selfID))
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "self_id not permitted"), time.Now().Add(time.Second))
+			_ = conn.Close()
+			return
+		}
 	}
+	a.succeed(ip)
 
 	c := &WSSCaller{
 		conn:   conn,
-		selfID: rsp.SelfID,
+		selfID: selfID,
+		url:    wss.URL,
+		role:   role,
+		bound:  len(ids) > 0,
 	}
-	zero.APICallers.Store(rsp.SelfID, c) // 添加Caller到 APICaller list...
-	log.Infof("[wss] 连接Websocket服务器: %s 成功, 账号: %d", wss.URL, rsp.SelfID)
+	wss.replace(c)
+	metricConnects.With("wsserver", wss.URL).Inc()
+	zero.Log("wss").Info("连接Websocket服务器成功", zero.F("url", wss.URL), zero.F(zero.FieldSelfID, selfID), zero.F("role", role))
 	wss.caller <- c
 }
 
+// slotsOf 返回连接占用的位置
+func (wssc *WSSCaller) slotsOf() []wssSlot {
+	switch wssc.role {
+	case RoleEvent:
+		return []wssSlot{{wssc.selfID, false}}
+	case RoleAPI:
+		return []wssSlot{{wssc.selfID, true}}
+	}
+	return []wssSlot{{wssc.selfID, false}, {wssc.selfID, true}}
+}
+
+// replace 登记新连接, 关闭同一账号占用相同位置的旧连接
+//
+// 新连接先加入账号的连接组再关闭旧连接, 旧连接退出时只从组中移除自身, 不会误删新连接
+func (wss *WSServer) replace(c *WSSCaller) {
+	wss.mu.Lock()
+	defer wss.mu.Unlock()
+	if wss.slots == nil {
+		wss.slots = map[wssSlot]*WSSCaller{}
+	}
+	if c.role != RoleEvent {
+		zero.AddCaller(c.selfID, c) // 添加Caller到 APICaller list...
+	}
+	var stale []*WSSCaller
+	for _, slot := range c.slotsOf() {
+		if old, ok := wss.slots[slot]; ok && old != c {
+			stale = append(stale, old)
+			// Universal 旧连接的另一位置同样失效
+			for _, s := range old.slotsOf() {
+				if wss.slots[s] == old {
+					delete(wss.slots, s)
+				}
+			}
+		}
+		wss.slots[slot] = c
+	}
+	for _, old := range stale {
+		zero.Log("wss").Info("同一账号的新连接替换了旧连接", zero.F(zero.FieldSelfID, c.selfID), zero.F("role", old.role))
+		if old.role != RoleEvent {
+			zero.RemoveCaller(old.selfID, old)
+		}
+		_ = old.conn.Close()
+	}
+}
+
+// release 连接断开时释放其占用的位置
+func (wss *WSServer) release(c *WSSCaller) {
+	wss.mu.Lock()
+	defer wss.mu.Unlock()
+	for _, slot := range c.slotsOf() {
+		if wss.slots[slot] == c {
+			delete(wss.slots, slot)
+		}
+	}
+	if c.role != RoleEvent {
+		zero.RemoveCaller(c.selfID, c) // 断开从apicaller中删除
+	}
+}
+
 // Listen 开始监听事件
 func (wss *WSServer) Listen(handler func([]byte, zero.APICaller)) {
 	mux := http.ServeMux{}
@@ -157,25 +307,33 @@ func (wss *WSServer) Listen(handler func([]byte, zero.APICaller)) {
 				wss.Connect()
 				continue
 			}
-			log.Infof("[wss] WebSocket 服务器开始处理: %v", wss.lstn.Addr())
+			zero.Log("wss").Info("WebSocket 服务器开始处理", zero.F("addr", wss.lstn.Addr().String()))
 			err := http.Serve(wss.lstn, &mux)
 			if err != nil {
-				log.Warn("[wss] Websocket服务器在端点", wss.lstn.Addr(), "失败:", err)
+				metricConnectErrors.With("wsserver", wss.URL).Inc()
+				zero.Log("wss").Warn("Websocket服务器在端点失败", zero.F("addr", wss.lstn.Addr().String()), zero.F(zero.FieldError, err))
 				wss.lstn = nil
 			}
 		}
 	}()
 	for wssc := range wss.caller {
-		go wssc.listen(handler)
+		go func(wssc *WSSCaller) {
+			wssc.listen(handler)
+			wss.release(wssc)
+		}(wssc)
 	}
 }
 
 func (wssc *WSSCaller) listen(handler func([]byte, zero.APICaller)) {
+	var caller zero.APICaller = wssc
+	if wssc.role == RoleEvent {
+		caller = wssEventCaller(wssc.selfID)
+	}
 	for {
 		t, payload, err := wssc.conn.ReadMessage()
 		if err != nil { // reconnect
-			zero.APICallers.Delete(wssc.selfID) // 断开从apicaller中删除
-			log.Warnln("[wss] Websocket服务器连接断开, 账号:", wssc.selfID)
+			metricDisconnects.With("wsserver", wssc.url).Inc()
+			zero.Log("wss").Warn("Websocket服务器连接断开", zero.F(zero.FieldSelfID, wssc.selfID), zero.F("role", wssc.role))
 			return
 		}
 		if t != websocket.TextMessage {
@@ -183,7 +341,7 @@ func (wssc *WSSCaller) listen(handler func([]byte, zero.APICaller)) {
 		}
 		rsp := gjson.Parse(helper.BytesToString(payload))
 		if rsp.Get("echo").Exists() { // 存在echo字段，是api调用的返回
-			log.Debug("[wss] 接收到API调用返回: ", strings.TrimSpace(helper.BytesToString(payload)))
+			zero.Log("wss").Debug("接收到API调用返回", zero.F(zero.FieldSelfID, wssc.selfID), zero.F("payload", strings.TrimSpace(helper.BytesToString(payload))))
 			if c, ok := wssc.seqMap.LoadAndDelete(rsp.Get("echo").Uint()); ok {
 				msg := rsp.Get("message").Str
 				if msg == "" {
@@ -204,8 +362,12 @@ func (wssc *WSSCaller) listen(handler func([]byte, zero.APICaller)) {
 		if rsp.Get("meta_event_type").Str == "heartbeat" { // 忽略心跳事件
 			continue
 		}
-		log.Debug("[wss] 接收到事件: ", helper.BytesToString(payload))
-		handler(payload, wssc)
+		if wssc.bound && rsp.Get("self_id").Int() != wssc.selfID {
+			zero.Log("wss").Warn("已丢弃其他账号的事件", zero.F(zero.FieldSelfID, wssc.selfID), zero.F("event_self_id", rsp.Get("self_id").Int()))
+			continue
+		}
+		zero.Log("wss").Debug("接收到事件", zero.F(zero.FieldSelfID, wssc.selfID), zero.F("payload", helper.BytesToString(payload)))
+		handler(payload, caller)
 	}
 }
 
@@ -224,10 +386,12 @@ func (wssc *WSSCaller) CallAPI(c context.Context, req zero.APIRequest) (zero.API
 	err := wssc.conn.WriteJSON(&req)
 	wssc.mu.Unlock()
 	if err != nil {
-		log.Warn("[wss] 向WebsocketServer发送API请求失败: ", err.Error())
-		return nullResponse, err
+		zero.Log("wss").Warn("向WebsocketServer发送API请求失败", zero.F(zero.FieldAction, req.Action), zero.F(zero.FieldError, err))
+		return nullResponse, fmt.Errorf("%w: %w", zero.ErrNotSent, err)
+	}
+	if l := zero.Log("wss"); l.Enabled(zero.LevelDebug) {
+		l.Debug("向服务器发送请求", zero.F(zero.FieldSelfID, wssc.selfID), zero.F("payload", req.String()))
 	}
-	log.Debug("[wss] 向服务器发送请求: ", &req)
 
 	select { // 等待数据返回
 	case rsp, ok := <-ch:
@@ -1,30 +1,49 @@
 package zero
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 // New 生成空引擎
 func New() *Engine {
-	return &Engine{
+	e := &Engine{
+		name:        callerPackage(1),
 		preHandler:  []Rule{},
 		midHandler:  []Rule{},
 		postHandler: []Handler{},
 	}
+	registerEngine(e)
+	return e
 }
 
-var defaultEngine = New()
+var defaultEngine = New().SetName("default")
 
 // Engine is the pre_handler, post_handler manager
 type Engine struct {
+	id          int
+	name        string
 	preHandler  []Rule
 	midHandler  []Rule
 	postHandler []Handler
 	block       bool
 	matchers    []*Matcher
+	matcherSeq  int // 已分配的 Matcher.id, 由 matcherLock 保护
+	banList     *BanList
+	banListSet  bool
+
+	stateMu  sync.RWMutex
+	disabled bool
+	groups   map[int64]bool // gid -> 是否启用
+	hasState atomic.Bool    // 是否有 disabled 或 groups 设置
 }
 
-// Delete 移除该 Engine 注册的所有 Matchers
+// Delete 移除该 Engine 注册的所有 Matchers, 并将其从 Engines 中移除
 func (e *Engine) Delete() {
 	for _, m := range e.matchers {
 		m.Delete()
 	}
+	unregisterEngine(e)
 }
 
 func (e *Engine) SetBlock(block bool) *Engine {
@@ -2,12 +2,12 @@
 package manager
 
 import (
-	"bytes"
 	"encoding/binary"
-	"io"
 	"strconv"
 	"sync"
 
+	log "github.com/sirupsen/logrus"
+
 	zero "github.com/wdvxdr1123/ZeroBot"
 	"github.com/wdvxdr1123/ZeroBot/extension"
 	"github.com/wdvxdr1123/ZeroBot/extension/kv"
@@ -15,14 +15,47 @@ import (
 )
 
 var (
-	bucket   = kv.New("Manager")
+	bucket   = kv.NewTyped[string, map[int64]bool](kv.New("Manager"), kv.JSON)
+	migrate  sync.Once
 	managers = map[string]*Manager{}
 	mu       = sync.RWMutex{}
 )
 
+// migrations 数据版本
+//
+//	1: 由手写的二进制编码迁移至 JSON
+var migrations = []kv.Migration{
+	{Version: 1, Up: func(b kv.Bucket) error {
+		old := map[string][]byte{}
+		err := b.Range(nil, nil, func(k, v []byte) bool {
+			old[string(k)] = append([]byte{}, v...)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		return bucket.Tx(func(tx *kv.Tx[string, map[int64]bool]) error {
+			for k, v := range old {
+				if err := tx.Put(k, unpackV0(v)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}},
+}
+
 // New returns Manager with settings.
 func New(service string, o *Options) *Manager {
-	data, _ := bucket.Get([]byte(service))
+	migrate.Do(func() {
+		if err := bucket.Migrate(migrations...); err != nil {
+			log.Errorln("[manager] 迁移数据失败:", err)
+		}
+	})
+	states, err := bucket.Get(service)
+	if states == nil || err != nil {
+		states = map[int64]bool{}
+	}
 	m := &Manager{
 		service: service,
 		options: func() Options {
@@ -31,7 +64,7 @@ func New(service string, o *Options) *Manager {
 			}
 			return *o
 		}(),
-		states: unpack(data),
+		states: states,
 	}
 	mu.Lock()
 	defer mu.Unlock()
@@ -52,7 +85,7 @@ func (m *Manager) Enable(groupID int64) {
 	m.Lock()
 	defer m.Unlock()
 	m.states[groupID] = true
-	_ = bucket.Put([]byte(m.service), pack(m.states))
+	_ = bucket.Put(m.service, m.states)
 }
 
 // Disable disables a group to pass the Manager.
@@ -60,7 +93,7 @@ func (m *Manager) Disable(groupID int64) {
 	m.Lock()
 	defer m.Unlock()
 	m.states[groupID] = false
-	_ = bucket.Put([]byte(m.service), pack(m.states))
+	_ = bucket.Put(m.service, m.states)
 }
 
 // Handler 返回 预处理器
@@ -111,35 +144,12 @@ func copyMap(m map[string]*Manager) map[string]*Manager {
 	return ret
 }
 
-func pack(m map[int64]bool) []byte {
-	var (
-		buf bytes.Buffer
-		b   = make([]byte, 8)
-	)
-	for k, v := range m {
-		binary.LittleEndian.PutUint64(b, uint64(k))
-		if v {
-			b[7] |= 0x80
-		}
-		buf.Write(b[:8])
-	}
-	return buf.Bytes()
-}
-
-func unpack(v []byte) map[int64]bool {
-	var (
-		m      = make(map[int64]bool)
-		b      = make([]byte, 8)
-		reader = bytes.NewReader(v)
-		k      uint64
-	)
-	for {
-		_, err := reader.Read(b)
-		if err == io.EOF {
-			break
-		}
-		k = binary.LittleEndian.Uint64(b)
-		m[int64(k&0x7fff_ffff_ffff_ffff)] = k&8000_0000_0000_0000 != 0
+// unpackV0 解码版本 0 的数据, 每组 8 字节小端序, 最高位为启用状态
+func unpackV0(v []byte) map[int64]bool {
+	m := make(map[int64]bool, len(v)/8)
+	for ; len(v) >= 8; v = v[8:] {
+		k := binary.LittleEndian.Uint64(v)
+		m[int64(k&0x7fff_ffff_ffff_ffff)] = k&0x8000_0000_0000_0000 != 0
 	}
 	return m
 }
@@ -0,0 +1,312 @@
+// Package admin provides an embedded HTTP admin server for a running bot
+//
+//	s := admin.New("secret-token")
+//	go s.ListenAndServe("127.0.0.1:8081")
+//
+// 所有 /api/ 请求需带有 Authorization: Bearer <token>,
+// 访问 / 可打开内置的管理页面
+package admin
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// ErrNoToken 未设置 Token 时拒绝启动
+var ErrNoToken = errors.New("admin: token is required")
+
+// Server 管理接口
+type Server struct {
+	Token  string       // 鉴权令牌, 为空时拒绝所有 API 请求
+	Reload func() error // 重新加载配置, 为 nil 时 /api/reload 返回 501
+
+	mux *http.ServeMux
+}
+
+// New 返回使用 token 鉴权的管理接口
+func New(token string) *Server {
+	s := &Server{Token: token, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/", s.index)
+	s.mux.HandleFunc("/api/status", s.get(s.status))
+	s.mux.HandleFunc("/api/engines", s.get(s.engines))
+	s.mux.HandleFunc("/api/events", s.get(s.events))
+	s.mux.HandleFunc("/api/engines/enable", s.post(s.setEngine((*zero.Engine).Enable)))
+	s.mux.HandleFunc("/api/engines/disable", s.post(s.setEngine((*zero.Engine).Disable)))
+	s.mux.HandleFunc("/api/engines/reset", s.post(s.setEngine((*zero.Engine).Reset)))
+	s.mux.HandleFunc("/api/reload", s.post(s.reload))
+	s.mux.HandleFunc("/api/send", s.post(s.send))
+	return s
+}
+
+// ListenAndServe 在 addr 上提供管理接口
+func (s *Server) ListenAndServe(addr string) error {
+	if s.Token == "" {
+		return ErrNoToken
+	}
+	zero.Log("admin").Info("管理接口开始监听", zero.F("addr", addr))
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	return srv.ListenAndServe()
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") && !s.authorized(r) {
+		zero.Log("admin").Warn("已拒绝未授权的请求", zero.F("remote", r.RemoteAddr), zero.F("path", r.URL.Path))
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+type handler func(r *http.Request) (any, error)
+
+// httpError 带状态码的错误
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e *httpError) Error() string { return e.err.Error() }
+
+func badRequest(format string, a ...any) error {
+	return &httpError{code: http.StatusBadRequest, err: fmt.Errorf(format, a...)}
+}
+
+func (s *Server) get(h handler) http.HandlerFunc {
+	return s.method(http.MethodGet, h)
+}
+
+func (s *Server) post(h handler) http.HandlerFunc {
+	return s.method(http.MethodPost, h)
+}
+
+func (s *Server) method(method string, h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		v, err := h(r)
+		if err != nil {
+			code := http.StatusInternalServerError
+			var he *httpError
+			if errors.As(err, &he) {
+				code = he.code
+			}
+			writeError(w, code, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func decode(r *http.Request, v any) error {
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20)).Decode(v); err != nil {
+		return badRequest("invalid body: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(indexHTML)
+}
+
+// Status /api/status 的返回值
+type Status struct {
+	Uptime  string             `json:"uptime"`
+	Bots    []int64            `json:"bots"`
+	Drivers []zero.DriverState `json:"drivers"`
+	Logs    []zero.LogCount    `json:"logs"`
+}
+
+func (s *Server) status(*http.Request) (any, error) {
+	st := Status{
+		Uptime:  zero.Uptime().Truncate(time.Second).String(),
+		Bots:    []int64{},
+		Drivers: []zero.DriverState{},
+		Logs:    zero.LogCounts(),
+	}
+	zero.RangeBot(func(id int64, _ *zero.Ctx) bool {
+		st.Bots = append(st.Bots, id)
+		return true
+	})
+	for _, d := range zero.GetConfig().Driver {
+		if ds, ok := d.(zero.DriverStatus); ok {
+			st.Drivers = append(st.Drivers, ds.Status())
+		} else {
+			st.Drivers = append(st.Drivers, zero.DriverState{Type: fmt.Sprintf("%T", d)})
+		}
+	}
+	return st, nil
+}
+
+// Engine /api/engines 返回的 Engine 信息
+type Engine struct {
+	ID       int            `json:"id"`
+	Name     string         `json:"name"`
+	Enabled  bool           `json:"enabled"`
+	Groups   map[int64]bool `json:"groups"`
+	Matchers []Matcher      `json:"matchers"`
+}
+
+// Matcher /api/engines 返回的 Matcher 信息
+type Matcher struct {
+	Index     int  `json:"index"`
+	Priority  int  `json:"priority"`
+	Block     bool `json:"block"`
+	Temp      bool `json:"temp"`
+	Break     bool `json:"break"`
+	NoTimeout bool `json:"no_timeout"`
+	Rules     int  `json:"rules"`
+	Handlers  int  `json:"handlers"`
+}
+
+func (s *Server) engines(*http.Request) (any, error) {
+	es := []Engine{}
+	for _, e := range zero.Engines() {
+		info := Engine{
+			ID:       e.ID(),
+			Name:     e.Name(),
+			Enabled:  e.IsEnabledIn(0),
+			Groups:   e.GroupStates(),
+			Matchers: []Matcher{},
+		}
+		for j, m := range e.Matchers() {
+			if !m.Registered() {
+				continue
+			}
+			info.Matchers = append(info.Matchers, Matcher{
+				Index:     j,
+				Priority:  m.Priority,
+				Block:     m.Block,
+				Temp:      m.Temp,
+				Break:     m.Break,
+				NoTimeout: m.NoTimeout,
+				Rules:     len(m.Rules),
+				Handlers:  len(m.Handler),
+			})
+		}
+		es = append(es, info)
+	}
+	return es, nil
+}
+
+func (s *Server) events(*http.Request) (any, error) {
+	return zero.RecentEvents(), nil
+}
+
+// EngineRequest /api/engines/{enable,disable,reset} 的请求
+type EngineRequest struct {
+	ID  int   `json:"id"`
+	GID int64 `json:"gid"` // 0 为全局, 私聊为 -user_id
+}
+
+func (s *Server) setEngine(set func(e *zero.Engine, gid int64) *zero.Engine) handler {
+	return func(r *http.Request) (any, error) {
+		var req EngineRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		var e *zero.Engine
+		for _, en := range zero.Engines() {
+			if en.ID() == req.ID {
+				e = en
+				break
+			}
+		}
+		if e == nil {
+			return nil, badRequest("no engine %d", req.ID)
+		}
+		e = set(e, req.GID)
+		zero.Log("admin").Info("修改 Engine 启用状态", zero.F("engine", e.Name()), zero.F("path", r.URL.Path),
+			zero.F("gid", req.GID))
+		return map[string]any{"enabled": e.IsEnabledIn(req.GID)}, nil
+	}
+}
+
+func (s *Server) reload(*http.Request) (any, error) {
+	if s.Reload == nil {
+		return nil, &httpError{code: http.StatusNotImplemented, err: errors.New("reload is not supported")}
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	zero.Log("admin").Info("已重新加载配置")
+	return map[string]any{}, nil
+}
+
+// SendRequest /api/send 的请求, message 为 CQ 码
+type SendRequest struct {
+	SelfID  int64  `json:"self_id"` // 为 0 时使用任意已连接的 bot
+	GroupID int64  `json:"group_id"`
+	UserID  int64  `json:"user_id"`
+	Message string `json:"message"`
+}
+
+func (s *Server) send(r *http.Request) (any, error) {
+	var req SendRequest
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	if req.Message == "" || (req.GroupID == 0 && req.UserID == 0) {
+		return nil, badRequest("message and group_id or user_id are required")
+	}
+	var ctx *zero.Ctx
+	if req.SelfID != 0 {
+		ctx = zero.GetBot(req.SelfID)
+	} else {
+		zero.RangeBot(func(_ int64, c *zero.Ctx) bool {
+			ctx = c
+			return false
+		})
+	}
+	if ctx == nil {
+		return nil, badRequest("no bot %d", req.SelfID)
+	}
+	msg := message.ParseMessageFromString(req.Message)
+	var id int64
+	if req.GroupID != 0 {
+		id = ctx.SendGroupMessage(req.GroupID, msg)
+	} else {
+		id = ctx.SendPrivateMessage(req.UserID, msg)
+	}
+	if id == 0 {
+		return nil, errors.New("send message failed")
+	}
+	return map[string]any{"message_id": id}, nil
+}
@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func do(t *testing.T, s *Server, method, path, token, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	var v map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &v)
+	return rec.Code, v
+}
+
+func TestAuth(t *testing.T) {
+	s := New("secret")
+	code, _ := do(t, s, http.MethodGet, "/api/status", "", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = do(t, s, http.MethodGet, "/api/status", "wrong", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, v := do(t, s, http.MethodGet, "/api/status", "secret", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, v, "uptime")
+	code, _ = do(t, s, http.MethodGet, "/", "", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.ErrorIs(t, New("").ListenAndServe(":0"), ErrNoToken)
+	code, _ = do(t, New(""), http.MethodGet, "/api/status", "", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestEngines(t *testing.T) {
+	s := New("secret")
+	e := zero.New().SetName("admin-test")
+	defer e.Delete()
+	e.OnMessage().SetPriority(5)
+	id := e.ID()
+
+	code, v := do(t, s, http.MethodPost, "/api/engines/disable", "secret", `{"id":`+strconv.Itoa(id)+`,"gid":100}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, v["enabled"])
+	assert.False(t, e.IsEnabledIn(100))
+	assert.True(t, e.IsEnabledIn(200))
+
+	code, _ = do(t, s, http.MethodGet, "/api/engines/disable", "secret", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = do(t, s, http.MethodPost, "/api/engines/enable", "secret", `{"id":-1}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/engines", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	var es []Engine
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &es))
+	assert.Equal(t, "admin-test", es[id].Name)
+	assert.Equal(t, map[int64]bool{100: false}, es[id].Groups)
+	assert.Equal(t, []Matcher{{Index: 0, Priority: 5}}, es[id].Matchers)
+
+	code, _ = do(t, s, http.MethodPost, "/api/reload", "secret", "{}")
+	assert.Equal(t, http.StatusNotImplemented, code)
+	reloaded := false
+	s.Reload = func() error { reloaded = true; return nil }
+	code, _ = do(t, s, http.MethodPost, "/api/reload", "secret", "{}")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, reloaded)
+
+	code, _ = do(t, s, http.MethodPost, "/api/send", "secret", `{"group_id":1,"message":"hi"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
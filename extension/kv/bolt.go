@@ -0,0 +1,92 @@
+package kv
+
+import (
+	"bytes"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var boltBucket = []byte("kv")
+
+type boltDB struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens a single file bbolt backend at path.
+func OpenBolt(path string) (Backend, error) {
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &boltDB{db: db}, nil
+}
+
+func (b *boltDB) Get(k []byte) (v []byte, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(boltBucket).Get(k)
+		if val == nil {
+			return ErrNotFound
+		}
+		v = append([]byte{}, val...)
+		return nil
+	})
+	return
+}
+
+func (b *boltDB) Put(k, v []byte) error {
+	return b.Write([]Op{{Key: k, Value: v}})
+}
+
+func (b *boltDB) Delete(k []byte) error {
+	return b.Write([]Op{{Key: k, Delete: true}})
+}
+
+func (b *boltDB) Write(ops []Op) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(boltBucket)
+		for _, op := range ops {
+			var err error
+			if op.Delete {
+				err = bk.Delete(op.Key)
+			} else {
+				err = bk.Put(op.Key, op.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltDB) Scan(start, limit []byte, iter func(k, v []byte) bool) error {
+	return scanChunked(start, func(from []byte, n int) (kvs [][2][]byte, err error) {
+		err = b.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket(boltBucket).Cursor()
+			var k, v []byte
+			if from == nil {
+				k, v = c.First()
+			} else {
+				k, v = c.Seek(from)
+			}
+			for ; k != nil && len(kvs) < n; k, v = c.Next() {
+				if limit != nil && bytes.Compare(k, limit) >= 0 {
+					break
+				}
+				kvs = append(kvs, [2][]byte{append([]byte{}, k...), append([]byte{}, v...)})
+			}
+			return nil
+		})
+		return
+	}, iter)
+}
+
+func (b *boltDB) Close() error { return b.db.Close() }
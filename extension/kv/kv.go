@@ -0,0 +1,407 @@
+// Package kv provides a simple multi bucket key-value database
+// with pluggable storage backends
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// ErrNotFound is returned when the key does not exist or has expired.
+//
+// 与 leveldb.ErrNotFound 相同, 以兼容旧代码
+var ErrNotFound = leveldb.ErrNotFound
+
+// ErrClosed is returned when operating a closed database.
+var ErrClosed = errors.New("kv: database closed")
+
+// Op is a single write operation in a batch.
+type Op struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// Backend is the flat, ordered key-value storage of a DB.
+//
+// 实现需保证 Write 的原子性, Scan 按键的字节序升序遍历,
+// 传入回调的 k, v 仅在回调内有效, 回调中允许写入同一 Backend.
+// 内置实现每次读取 scanChunk 个键, 在读取之外调用回调, 回调中的写入对之后的段可见
+type Backend interface {
+	Get(k []byte) ([]byte, error)
+	Put(k, v []byte) error
+	Delete(k []byte) error
+	// Write applies all ops atomically.
+	Write(ops []Op) error
+	// Scan iterates keys in [start, limit), nil limit means no upper bound.
+	Scan(start, limit []byte, iter func(k, v []byte) bool) error
+	Close() error
+}
+
+// scanChunk 内置 Backend 的 Scan 每段读取的键数
+const scanChunk = 64
+
+// scanChunked 以 read 分段读取并调用 iter, iter 返回 false 时不再读取之后的段
+//
+// read 返回不小于 from 的至多 n 个键值对 (需复制), 少于 n 个时视为读完
+func scanChunked(start []byte, read func(from []byte, n int) ([][2][]byte, error), iter func(k, v []byte) bool) error {
+	from := start
+	for {
+		kvs, err := read(from, scanChunk)
+		if err != nil {
+			return err
+		}
+		for _, e := range kvs {
+			if !iter(e[0], e[1]) {
+				return nil
+			}
+		}
+		if len(kvs) < scanChunk {
+			return nil
+		}
+		from = append(kvs[len(kvs)-1][0], 0) // 大于上一个键的最小键
+	}
+}
+
+// Opener opens a Backend by the path part of the uri.
+type Opener func(path string) (Backend, error)
+
+var (
+	openers   = map[string]Opener{}
+	openersMu sync.RWMutex
+)
+
+// Register 注册 scheme 对应的 Backend, 用于 Open("scheme://path")
+func Register(scheme string, o Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	openers[scheme] = o
+}
+
+func init() {
+	Register("leveldb", OpenLevelDB)
+	Register("memory", func(string) (Backend, error) { return NewMemory(), nil })
+	Register("bolt", OpenBolt)
+	Register("sqlite", OpenSQLite)
+	zero.OnShutdown(func() { _ = Close() })
+}
+
+// DB is a database holding many buckets.
+type DB struct {
+	backend Backend
+	locks   sync.Map // bucket 名到 Typed 事务使用的 *sync.Mutex
+}
+
+// Open 按 uri 打开数据库, 如
+//
+//	leveldb://.db  memory://  bolt://data.db  sqlite://data.db
+//
+// 不带 scheme 时视为 LevelDB 路径
+func Open(uri string) (*DB, error) {
+	scheme, path, ok := strings.Cut(uri, "://")
+	if !ok {
+		scheme, path = "leveldb", uri
+	}
+	openersMu.RLock()
+	o, ok := openers[scheme]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, errors.New("kv: unknown backend " + scheme)
+	}
+	b, err := o(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewDB(b), nil
+}
+
+// NewDB wraps a Backend as DB.
+func NewDB(b Backend) *DB {
+	return &DB{backend: b}
+}
+
+// Backend returns the underlying storage.
+func (db *DB) Backend() Backend {
+	return db.backend
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	return db.backend.Close()
+}
+
+// Bucket returns a Bucket with specific name in db.
+func (db *DB) Bucket(name string) Bucket {
+	return &bucket{name: []byte(name), db: func() (*DB, error) { return db, nil }}
+}
+
+// Sweep 删除所有已过期的键
+func (db *DB) Sweep() error {
+	now := time.Now().UnixNano()
+	var ops []Op
+	err := db.backend.Scan(nil, nil, func(k, v []byte) bool {
+		i := indexSep(k, sepTTL)
+		if i >= 0 && expired(v, now) {
+			vk := append([]byte{}, k...)
+			vk[i] = sepValue
+			ops = append(ops, Op{Key: append([]byte{}, k...), Delete: true}, Op{Key: vk, Delete: true})
+		}
+		return true
+	})
+	if err != nil || len(ops) == 0 {
+		return err
+	}
+	return db.backend.Write(ops)
+}
+
+// DefaultPath 默认数据库的打开路径, 需在首次使用默认数据库前修改
+var DefaultPath = "leveldb://.db"
+
+var (
+	defaultDB   *DB
+	defaultErr  error
+	defaultOnce sync.Mutex
+)
+
+// SetDefault 设置 New 与包级函数使用的默认数据库
+func SetDefault(db *DB) {
+	defaultOnce.Lock()
+	defer defaultOnce.Unlock()
+	defaultDB, defaultErr = db, nil
+}
+
+// Default 返回默认数据库, 未设置时在首次调用时打开 DefaultPath
+func Default() (*DB, error) {
+	defaultOnce.Lock()
+	defer defaultOnce.Unlock()
+	if defaultDB == nil && defaultErr == nil {
+		defaultDB, defaultErr = Open(DefaultPath)
+	}
+	return defaultDB, defaultErr
+}
+
+// Close 关闭默认数据库, 已注册为 zero.Shutdown 的清理函数
+func Close() error {
+	defaultOnce.Lock()
+	defer defaultOnce.Unlock()
+	if defaultDB == nil {
+		return nil
+	}
+	err := defaultDB.Close()
+	defaultDB, defaultErr = nil, ErrClosed
+	return err
+}
+
+// Bucket is the interface of the database bucket
+type Bucket interface {
+	Get(k []byte) ([]byte, error)
+	Put(k []byte, v []byte) error
+	Delete(k []byte) error
+	Iterator(func(k, v []byte) bool)
+
+	// PutTTL 写入在 ttl 后过期的键
+	PutTTL(k, v []byte, ttl time.Duration) error
+	// Prefix 遍历以 prefix 开头的键
+	Prefix(prefix []byte, iter func(k, v []byte) bool) error
+	// Range 遍历 [start, limit) 的键, limit 为 nil 时不设上界
+	Range(start, limit []byte, iter func(k, v []byte) bool) error
+	// Batch 原子地执行 f 中的所有写入
+	Batch(f func(b *Batch)) error
+}
+
+const (
+	sepValue = 0x02
+	sepTTL   = 0x03
+)
+
+type bucket struct {
+	name []byte
+	db   func() (*DB, error)
+}
+
+var defaultBucket = New("\x01")
+
+// New returns a Bucket with specific name in the default database.
+func New(name string) Bucket {
+	return &bucket{name: []byte(name), db: Default}
+}
+
+func pack(name []byte, sep byte, k []byte) []byte {
+	b := make([]byte, 0, len(name)+1+len(k))
+	return append(append(append(b, name...), sep), k...)
+}
+
+// prefixEnd returns the smallest key greater than all keys with prefix p.
+func prefixEnd(p []byte) []byte {
+	end := append([]byte{}, p...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+func indexSep(k []byte, sep byte) int {
+	for i, c := range k {
+		if c == sepValue || c == sepTTL {
+			if c == sep {
+				return i
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+func expired(ttl []byte, now int64) bool {
+	return len(ttl) == 8 && int64(binary.BigEndian.Uint64(ttl)) <= now
+}
+
+// Get returns a value for the given key from the default bucket.
+func Get(k []byte) ([]byte, error) { return defaultBucket.Get(k) }
+
+// Get returns a value for the given key from the bucket.
+func (b *bucket) Get(k []byte) ([]byte, error) {
+	db, err := b.db()
+	if err != nil {
+		return nil, err
+	}
+	v, err := db.backend.Get(pack(b.name, sepValue, k))
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := db.backend.Get(pack(b.name, sepTTL, k))
+	if err == nil && expired(ttl, time.Now().UnixNano()) {
+		_ = b.Delete(k)
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+// Put push/update a key value pair to the default bucket.
+func Put(k []byte, v []byte) error { return defaultBucket.Put(k, v) }
+
+// Put push/update a key value pair to the bucket.
+func (b *bucket) Put(k []byte, v []byte) error {
+	return b.Batch(func(bt *Batch) { bt.Put(k, v) })
+}
+
+// PutTTL push/update a key value pair which expires after ttl to the bucket.
+func (b *bucket) PutTTL(k, v []byte, ttl time.Duration) error {
+	return b.Batch(func(bt *Batch) { bt.PutTTL(k, v, ttl) })
+}
+
+// Delete deletes a key from the default bucket.
+func Delete(k []byte) error { return defaultBucket.Delete(k) }
+
+// Delete deletes a key from the bucket.
+func (b *bucket) Delete(k []byte) error {
+	return b.Batch(func(bt *Batch) { bt.Delete(k) })
+}
+
+func (b *bucket) Iterator(iter func(k, v []byte) bool) {
+	_ = b.Range(nil, nil, iter)
+}
+
+func (b *bucket) Prefix(prefix []byte, iter func(k, v []byte) bool) error {
+	limit := prefixEnd(prefix)
+	if limit == nil {
+		return b.Range(prefix, nil, iter)
+	}
+	return b.Range(prefix, limit, iter)
+}
+
+func (b *bucket) Range(start, limit []byte, iter func(k, v []byte) bool) error {
+	db, err := b.db()
+	if err != nil {
+		return err
+	}
+	// 先收集已过期的键, ttl 键通常很少
+	now := time.Now().UnixNano()
+	expiredKeys := map[string]struct{}{}
+	ttlStart := pack(b.name, sepTTL, nil)
+	err = db.backend.Scan(ttlStart, prefixEnd(ttlStart), func(k, v []byte) bool {
+		if expired(v, now) {
+			expiredKeys[string(k[len(ttlStart):])] = struct{}{}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	s := pack(b.name, sepValue, start)
+	var l []byte
+	if limit != nil {
+		l = pack(b.name, sepValue, limit)
+	} else {
+		l = prefixEnd(pack(b.name, sepValue, nil))
+	}
+	n := len(b.name) + 1
+	return db.backend.Scan(s, l, func(k, v []byte) bool {
+		if len(expiredKeys) > 0 {
+			if _, ok := expiredKeys[string(k[n:])]; ok {
+				return true
+			}
+		}
+		return iter(k[n:], v)
+	})
+}
+
+func (b *bucket) Batch(f func(b *Batch)) error {
+	db, err := b.db()
+	if err != nil {
+		return err
+	}
+	bt := &Batch{name: b.name}
+	f(bt)
+	if len(bt.ops) == 0 {
+		return nil
+	}
+	return db.backend.Write(bt.ops)
+}
+
+// Batch collects writes of a bucket to be applied atomically.
+type Batch struct {
+	name []byte
+	ops  []Op
+}
+
+// Put adds a put operation.
+func (bt *Batch) Put(k, v []byte) {
+	bt.ops = append(bt.ops,
+		Op{Key: pack(bt.name, sepValue, k), Value: v},
+		Op{Key: pack(bt.name, sepTTL, k), Delete: true},
+	)
+}
+
+// PutTTL adds a put operation whose key expires after ttl.
+func (bt *Batch) PutTTL(k, v []byte, ttl time.Duration) {
+	exp := make([]byte, 8)
+	binary.BigEndian.PutUint64(exp, uint64(time.Now().Add(ttl).UnixNano()))
+	bt.ops = append(bt.ops,
+		Op{Key: pack(bt.name, sepValue, k), Value: v},
+		Op{Key: pack(bt.name, sepTTL, k), Value: exp},
+	)
+}
+
+// Delete adds a delete operation.
+func (bt *Batch) Delete(k []byte) {
+	bt.ops = append(bt.ops,
+		Op{Key: pack(bt.name, sepValue, k), Delete: true},
+		Op{Key: pack(bt.name, sepTTL, k), Delete: true},
+	)
+}
+
+// Len returns the number of keys written in the batch.
+func (bt *Batch) Len() int {
+	return len(bt.ops) / 2
+}
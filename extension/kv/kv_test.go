@@ -0,0 +1,153 @@
+package kv
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func testBackend(t *testing.T, uri string) {
+	db, err := Open(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	a, b := db.Bucket("a"), db.Bucket("b")
+	assert.NoError(t, a.Put([]byte("k1"), []byte("v1")))
+	assert.NoError(t, b.Put([]byte("k1"), []byte("b1")))
+	v, err := a.Get([]byte("k1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v)
+	_, err = a.Get([]byte("none"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, a.Batch(func(bt *Batch) {
+		bt.Put([]byte("k2"), []byte("v2"))
+		bt.Put([]byte("x1"), []byte("x"))
+		bt.Delete([]byte("k1"))
+	}))
+	var keys []string
+	a.Iterator(func(k, _ []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	assert.Equal(t, []string{"k2", "x1"}, keys)
+
+	assert.NoError(t, a.Put([]byte("k3"), []byte("v3")))
+	keys = keys[:0]
+	assert.NoError(t, a.Prefix([]byte("k"), func(k, _ []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	}))
+	assert.Equal(t, []string{"k2", "k3"}, keys)
+	keys = keys[:0]
+	assert.NoError(t, a.Range([]byte("k3"), []byte("x2"), func(k, _ []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	}))
+	assert.Equal(t, []string{"k3", "x1"}, keys)
+
+	assert.NoError(t, a.PutTTL([]byte("t"), []byte("t"), time.Millisecond))
+	assert.NoError(t, a.PutTTL([]byte("u"), []byte("u"), time.Hour))
+	time.Sleep(5 * time.Millisecond)
+	_, err = a.Get([]byte("t"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	v, err = a.Get([]byte("u"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("u"), v)
+	// Put 覆盖后不再过期
+	assert.NoError(t, a.PutTTL([]byte("t"), []byte("t"), time.Millisecond))
+	assert.NoError(t, a.Put([]byte("t"), []byte("t2")))
+	time.Sleep(5 * time.Millisecond)
+	v, err = a.Get([]byte("t"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("t2"), v)
+
+	v, err = b.Get([]byte("k1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b1"), v)
+
+	// 遍历时写入不应死锁
+	keys = keys[:0]
+	assert.NoError(t, a.Prefix([]byte("k"), func(k, _ []byte) bool {
+		keys = append(keys, string(k))
+		assert.NoError(t, a.Delete(k))
+		return true
+	}))
+	assert.Equal(t, []string{"k2", "k3"}, keys)
+	_, err = a.Get([]byte("k2"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// 跨越多段的遍历, 可提前结束, 回调中写入不影响尚未读取的键
+	c := db.Bucket("c")
+	assert.NoError(t, c.Batch(func(bt *Batch) {
+		for i := 0; i < 3*scanChunk; i++ {
+			bt.Put([]byte(fmt.Sprintf("%03d", i)), []byte("v"))
+		}
+	}))
+	n := 0
+	assert.NoError(t, c.Prefix(nil, func(_, _ []byte) bool {
+		n++
+		return false
+	}))
+	assert.Equal(t, 1, n)
+	n = 0
+	c.Iterator(func(k, _ []byte) bool {
+		n++
+		assert.NoError(t, c.Delete(k))
+		return true
+	})
+	assert.Equal(t, 3*scanChunk, n)
+	c.Iterator(func(_, _ []byte) bool {
+		t.Fail()
+		return false
+	})
+}
+
+func TestBackends(t *testing.T) {
+	defer func(d string) { SQLDriver = d }(SQLDriver)
+	SQLDriver = "kvtest-sqlite"
+	dir := t.TempDir()
+	for _, uri := range []string{
+		"memory://",
+		"leveldb://" + filepath.Join(dir, "leveldb"),
+		"bolt://" + filepath.Join(dir, "bolt.db"),
+		"sqlite://" + filepath.Join(dir, "sqlite.db"),
+	} {
+		t.Run(uri, func(t *testing.T) { testBackend(t, uri) })
+	}
+}
+
+func TestSweep(t *testing.T) {
+	db := NewDB(NewMemory())
+	bk := db.Bucket("s")
+	assert.NoError(t, bk.PutTTL([]byte("a"), []byte("a"), time.Millisecond))
+	assert.NoError(t, bk.Put([]byte("b"), []byte("b")))
+	time.Sleep(5 * time.Millisecond)
+	assert.NoError(t, db.Sweep())
+	n := 0
+	assert.NoError(t, db.Backend().Scan(nil, nil, func(_, _ []byte) bool {
+		n++
+		return true
+	}))
+	assert.Equal(t, 1, n)
+}
+
+func TestDefault(t *testing.T) {
+	SetDefault(NewDB(NewMemory()))
+	assert.NoError(t, Put([]byte("k"), []byte("v")))
+	v, err := New("\x01").Get([]byte("k"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v"), v)
+	zero.Shutdown()
+	_, err = Get([]byte("k"))
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = Open("unknown://x")
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package kv
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// memory 有序切片实现的内存数据库, 适用于测试与临时数据
+type memory struct {
+	mu     sync.RWMutex
+	keys   []string
+	values map[string][]byte
+	closed bool
+}
+
+// NewMemory returns an empty in-memory backend.
+func NewMemory() Backend {
+	return &memory{values: map[string][]byte{}}
+}
+
+func (m *memory) Get(k []byte) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	v, ok := m.values[string(k)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, v...), nil
+}
+
+func (m *memory) Put(k, v []byte) error {
+	return m.Write([]Op{{Key: k, Value: v}})
+}
+
+func (m *memory) Delete(k []byte) error {
+	return m.Write([]Op{{Key: k, Delete: true}})
+}
+
+func (m *memory) Write(ops []Op) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	for _, op := range ops {
+		k := string(op.Key)
+		i := sort.SearchStrings(m.keys, k)
+		found := i < len(m.keys) && m.keys[i] == k
+		if op.Delete {
+			if found {
+				m.keys = append(m.keys[:i], m.keys[i+1:]...)
+				delete(m.values, k)
+			}
+			continue
+		}
+		if !found {
+			m.keys = append(m.keys, "")
+			copy(m.keys[i+1:], m.keys[i:])
+			m.keys[i] = k
+		}
+		m.values[k] = append([]byte{}, op.Value...)
+	}
+	return nil
+}
+
+func (m *memory) Scan(start, limit []byte, iter func(k, v []byte) bool) error {
+	return scanChunked(start, func(from []byte, n int) ([][2][]byte, error) {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		if m.closed {
+			return nil, ErrClosed
+		}
+		kvs := make([][2][]byte, 0, n)
+		for i := sort.SearchStrings(m.keys, string(from)); i < len(m.keys) && len(kvs) < n; i++ {
+			k := []byte(m.keys[i])
+			if limit != nil && bytes.Compare(k, limit) >= 0 {
+				break
+			}
+			kvs = append(kvs, [2][]byte{k, m.values[m.keys[i]]}) // Write 总是替换值而不修改, 可以共用
+		}
+		return kvs, nil
+	}, iter)
+}
+
+func (m *memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.keys, m.values = nil, nil
+	return nil
+}
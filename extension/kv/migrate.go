@@ -0,0 +1,81 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Migration 将 bucket 的数据由上一版本升级到 Version
+type Migration struct {
+	Version int
+	Up      func(b Bucket) error
+}
+
+// schemaBucket 记录各 bucket 的数据版本
+const schemaBucket = "\x00schema"
+
+func schemaOf(b Bucket) (Bucket, []byte, error) {
+	bk, ok := b.(*bucket)
+	if !ok {
+		return nil, nil, errors.New("kv: migrate unsupported bucket")
+	}
+	return &bucket{name: []byte(schemaBucket), db: bk.db}, bk.name, nil
+}
+
+// SchemaVersion 返回 b 当前的数据版本, 从未迁移过时为 0
+func SchemaVersion(b Bucket) (int, error) {
+	meta, name, err := schemaOf(b)
+	if err != nil {
+		return 0, err
+	}
+	v, err := meta.Get(name)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, errors.New("kv: invalid schema version of " + strconv.Quote(string(name)))
+	}
+	return int(binary.BigEndian.Uint64(v)), nil
+}
+
+// Migrate 按版本顺序执行高于当前版本的迁移, 每步成功后记录版本
+//
+// 应在使用 bucket 前 (如插件初始化时) 执行, 迁移中可使用 Typed 的 Update 与 Tx
+func Migrate(b Bucket, migrations ...Migration) error {
+	meta, name, err := schemaOf(b)
+	if err != nil {
+		return err
+	}
+	cur, err := SchemaVersion(b)
+	if err != nil {
+		return err
+	}
+	ms := append([]Migration{}, migrations...)
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	for _, m := range ms {
+		if m.Version <= cur {
+			continue
+		}
+		if err = m.Up(b); err != nil {
+			return fmt.Errorf("kv: migrate %q to version %d: %w", name, m.Version, err)
+		}
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(m.Version))
+		if err = meta.Put(name, v); err != nil {
+			return err
+		}
+		cur = m.Version
+	}
+	return nil
+}
+
+// Migrate 对 t 的 bucket 执行迁移, 见 Migrate
+func (t *Typed[K, V]) Migrate(migrations ...Migration) error {
+	return Migrate(t.bucket, migrations...)
+}
@@ -0,0 +1,107 @@
+package kv
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// SQLDriver 打开 sqlite:// 时使用的 database/sql 驱动名
+//
+// kv 不依赖具体的 SQLite 实现, 使用前需导入注册了该驱动的包,
+// 如 modernc.org/sqlite (sqlite) 或 github.com/mattn/go-sqlite3 (sqlite3)
+var SQLDriver = "sqlite"
+
+type sqlDB struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens a SQLite backend at path with driver SQLDriver.
+//
+// 只使用一个连接, 避免多个连接同时写入时出现 SQLITE_BUSY
+func OpenSQLite(path string) (Backend, error) {
+	db, err := sql.Open(SQLDriver, path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return NewSQL(db)
+}
+
+// NewSQL uses table kv of an opened SQLite compatible db as backend.
+func NewSQL(db *sql.DB) (Backend, error) {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS kv (k BLOB PRIMARY KEY, v BLOB NOT NULL)")
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &sqlDB{db: db}, nil
+}
+
+func (s *sqlDB) Get(k []byte) (v []byte, err error) {
+	err = s.db.QueryRow("SELECT v FROM kv WHERE k = ?", k).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	return
+}
+
+func (s *sqlDB) Put(k, v []byte) error {
+	return s.Write([]Op{{Key: k, Value: v}})
+}
+
+func (s *sqlDB) Delete(k []byte) error {
+	return s.Write([]Op{{Key: k, Delete: true}})
+}
+
+func (s *sqlDB) Write(ops []Op) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if op.Delete {
+			_, err = tx.Exec("DELETE FROM kv WHERE k = ?", op.Key)
+		} else {
+			v := op.Value
+			if v == nil {
+				v = []byte{}
+			}
+			_, err = tx.Exec("INSERT OR REPLACE INTO kv (k, v) VALUES (?, ?)", op.Key, v)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlDB) Scan(start, limit []byte, iter func(k, v []byte) bool) error {
+	return scanChunked(start, func(from []byte, n int) ([][2][]byte, error) {
+		if from == nil {
+			from = []byte{}
+		}
+		var rows *sql.Rows
+		var err error
+		if limit == nil {
+			rows, err = s.db.Query("SELECT k, v FROM kv WHERE k >= ? ORDER BY k LIMIT ?", from, n)
+		} else {
+			rows, err = s.db.Query("SELECT k, v FROM kv WHERE k >= ? AND k < ? ORDER BY k LIMIT ?", from, limit, n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		kvs := make([][2][]byte, 0, n)
+		for rows.Next() {
+			var k, v []byte
+			if err = rows.Scan(&k, &v); err != nil {
+				return nil, err
+			}
+			kvs = append(kvs, [2][]byte{k, v})
+		}
+		return kvs, rows.Err()
+	}, iter)
+}
+
+func (s *sqlDB) Close() error { return s.db.Close() }
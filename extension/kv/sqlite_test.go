@@ -0,0 +1,132 @@
+package kv
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// memSQL 只理解 sqlDB 所用语句的 database/sql 驱动, 以 memory 保存数据,
+// 用于在没有 SQLite 实现时测试 sqlite:// (SQLite 的语义由真实驱动保证)
+type memSQL struct {
+	mu  sync.Mutex
+	dbs map[string]Backend
+}
+
+func init() {
+	sql.Register("kvtest-sqlite", &memSQL{dbs: map[string]Backend{}})
+}
+
+func (d *memSQL) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b, ok := d.dbs[name]
+	if !ok {
+		b = NewMemory()
+		d.dbs[name] = b
+	}
+	return &memSQLConn{b: b}, nil
+}
+
+type memSQLConn struct {
+	b  Backend
+	tx []Op // 不为 nil 时在事务中
+}
+
+func (c *memSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &memSQLStmt{c: c, query: query}, nil
+}
+
+func (c *memSQLConn) Close() error { return nil }
+
+func (c *memSQLConn) Begin() (driver.Tx, error) {
+	c.tx = []Op{}
+	return c, nil
+}
+
+func (c *memSQLConn) Commit() error {
+	ops := c.tx
+	c.tx = nil
+	return c.b.Write(ops)
+}
+
+func (c *memSQLConn) Rollback() error {
+	c.tx = nil
+	return nil
+}
+
+type memSQLStmt struct {
+	c     *memSQLConn
+	query string
+}
+
+func (s *memSQLStmt) Close() error  { return nil }
+func (s *memSQLStmt) NumInput() int { return -1 }
+
+func (s *memSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	var op Op
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE"):
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.query, "INSERT OR REPLACE"):
+		op = Op{Key: args[0].([]byte), Value: args[1].([]byte)}
+	case strings.HasPrefix(s.query, "DELETE"):
+		op = Op{Key: args[0].([]byte), Delete: true}
+	default:
+		return nil, errors.New("memsql: unsupported exec " + s.query)
+	}
+	if s.c.tx != nil {
+		s.c.tx = append(s.c.tx, op)
+		return driver.RowsAffected(1), nil
+	}
+	return driver.RowsAffected(1), s.c.b.Write([]Op{op})
+}
+
+func (s *memSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(s.query, "SELECT v FROM kv WHERE k = ?"):
+		v, err := s.c.b.Get(args[0].([]byte))
+		if errors.Is(err, ErrNotFound) {
+			return &memSQLRows{}, nil
+		}
+		return &memSQLRows{rows: [][]driver.Value{{v}}}, err
+	case strings.HasPrefix(s.query, "SELECT k, v FROM kv WHERE k >= ?"):
+		var limit []byte
+		if len(args) == 3 {
+			limit = args[1].([]byte)
+		}
+		n := args[len(args)-1].(int64)
+		r := &memSQLRows{}
+		err := s.c.b.Scan(args[0].([]byte), limit, func(k, v []byte) bool {
+			r.rows = append(r.rows, []driver.Value{append([]byte{}, k...), append([]byte{}, v...)})
+			return int64(len(r.rows)) < n
+		})
+		return r, err
+	}
+	return nil, errors.New("memsql: unsupported query " + s.query)
+}
+
+type memSQLRows struct {
+	rows [][]driver.Value
+}
+
+func (r *memSQLRows) Columns() []string {
+	if len(r.rows) > 0 && len(r.rows[0]) == 2 {
+		return []string{"k", "v"}
+	}
+	return []string{"v"}
+}
+
+func (r *memSQLRows) Close() error { return nil }
+
+func (r *memSQLRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,279 @@
+package kv
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Typed 在 Bucket 之上以 Codec 编码值的类型化存储
+//
+// 整数键以保持顺序的大端序编码, 字符串键按原样存储, 其它类型的键以 JSON 编码
+type Typed[K comparable, V any] struct {
+	bucket Bucket
+	codec  Codec
+	mu     sync.Mutex // 非本包实现的 Bucket 使用的事务锁
+}
+
+// NewTyped returns a Typed view of b, codec 为 nil 时使用 JSON.
+//
+// 同一 DB 中同名 bucket 上的 Typed 共享同一把锁, 以保证 Update 与 Tx 的原子性
+func NewTyped[K comparable, V any](b Bucket, codec Codec) *Typed[K, V] {
+	if codec == nil {
+		codec = JSON
+	}
+	return &Typed[K, V]{bucket: b, codec: codec}
+}
+
+// lock 返回事务锁, 本包的 bucket 使用所在 DB 为该 bucket 保存的锁
+func (t *Typed[K, V]) lock() (*sync.Mutex, error) {
+	bk, ok := t.bucket.(*bucket)
+	if !ok {
+		return &t.mu, nil
+	}
+	db, err := bk.db()
+	if err != nil {
+		return nil, err
+	}
+	mu, _ := db.locks.LoadOrStore(string(bk.name), &sync.Mutex{})
+	return mu.(*sync.Mutex), nil
+}
+
+// Bucket returns the underlying bucket.
+func (t *Typed[K, V]) Bucket() Bucket {
+	return t.bucket
+}
+
+// Get 读取 k 的值, 不存在时返回 ErrNotFound
+func (t *Typed[K, V]) Get(k K) (v V, err error) {
+	data, err := t.bucket.Get(encodeKey(k))
+	if err != nil {
+		return
+	}
+	err = t.codec.Unmarshal(data, &v)
+	return
+}
+
+// Put 写入 k 的值
+func (t *Typed[K, V]) Put(k K, v V) error {
+	data, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.bucket.Put(encodeKey(k), data)
+}
+
+// PutTTL 写入在 ttl 后过期的值
+func (t *Typed[K, V]) PutTTL(k K, v V, ttl time.Duration) error {
+	data, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.bucket.PutTTL(encodeKey(k), data, ttl)
+}
+
+// Delete 删除 k
+func (t *Typed[K, V]) Delete(k K) error {
+	return t.bucket.Delete(encodeKey(k))
+}
+
+// ForEach 按键序遍历所有值, 遇到无法解码的值时返回错误
+func (t *Typed[K, V]) ForEach(f func(k K, v V) bool) error {
+	var ierr error
+	err := t.bucket.Range(nil, nil, func(kb, vb []byte) bool {
+		var (
+			k K
+			v V
+		)
+		if ierr = decodeKey(kb, &k); ierr != nil {
+			return false
+		}
+		if ierr = t.codec.Unmarshal(vb, &v); ierr != nil {
+			return false
+		}
+		return f(k, v)
+	})
+	if err != nil {
+		return err
+	}
+	return ierr
+}
+
+// Update 原子地读取, 修改并写回 k 的值, k 不存在时 ok 为 false
+//
+// f 返回错误时不写入
+func (t *Typed[K, V]) Update(k K, f func(v V, ok bool) (V, error)) error {
+	return t.Tx(func(tx *Tx[K, V]) error {
+		v, err := tx.Get(k)
+		ok := err == nil
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		v, err = f(v, ok)
+		if err != nil {
+			return err
+		}
+		return tx.Put(k, v)
+	})
+}
+
+// Tx 在事务中执行 f, f 返回 nil 时其中的写入被原子地提交
+//
+// 事务只与同一 bucket 上的其它 Update/Tx 串行执行, 不可嵌套;
+// Typed 与 Bucket 的 Put, Delete 等直接写入不受事务保护
+func (t *Typed[K, V]) Tx(f func(tx *Tx[K, V]) error) error {
+	mu, err := t.lock()
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	tx := &Tx[K, V]{t: t, writes: map[string]*txWrite{}}
+	if err := f(tx); err != nil {
+		return err
+	}
+	if len(tx.writes) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(tx.writes))
+	for k := range tx.writes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return t.bucket.Batch(func(b *Batch) {
+		for _, k := range keys {
+			w := tx.writes[k]
+			switch {
+			case w.delete:
+				b.Delete([]byte(k))
+			case w.ttl > 0:
+				b.PutTTL([]byte(k), w.value, w.ttl)
+			default:
+				b.Put([]byte(k), w.value)
+			}
+		}
+	})
+}
+
+type txWrite struct {
+	value  []byte
+	ttl    time.Duration
+	delete bool
+}
+
+// Tx is a multi-key transaction of Typed.
+type Tx[K comparable, V any] struct {
+	t      *Typed[K, V]
+	writes map[string]*txWrite
+}
+
+// Get 读取 k 的值, 可读到本事务中尚未提交的写入
+func (tx *Tx[K, V]) Get(k K) (v V, err error) {
+	kb := encodeKey(k)
+	var data []byte
+	if w, ok := tx.writes[string(kb)]; ok {
+		if w.delete {
+			return v, ErrNotFound
+		}
+		data = w.value
+	} else if data, err = tx.t.bucket.Get(kb); err != nil {
+		return
+	}
+	err = tx.t.codec.Unmarshal(data, &v)
+	return
+}
+
+// Put 在事务中写入 k 的值
+func (tx *Tx[K, V]) Put(k K, v V) error {
+	return tx.PutTTL(k, v, 0)
+}
+
+// PutTTL 在事务中写入在 ttl 后过期的值, ttl 为 0 时不过期
+func (tx *Tx[K, V]) PutTTL(k K, v V, ttl time.Duration) error {
+	data, err := tx.t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tx.writes[string(encodeKey(k))] = &txWrite{value: data, ttl: ttl}
+	return nil
+}
+
+// Delete 在事务中删除 k
+func (tx *Tx[K, V]) Delete(k K) {
+	tx.writes[string(encodeKey(k))] = &txWrite{delete: true}
+}
+
+func encodeKey[K comparable](k K) []byte {
+	b := make([]byte, 8)
+	switch k := any(k).(type) {
+	case string:
+		return []byte(k)
+	case int:
+		binary.BigEndian.PutUint64(b, uint64(k)^1<<63)
+	case int8:
+		binary.BigEndian.PutUint64(b, uint64(k)^1<<63)
+	case int16:
+		binary.BigEndian.PutUint64(b, uint64(k)^1<<63)
+	case int32:
+		binary.BigEndian.PutUint64(b, uint64(k)^1<<63)
+	case int64:
+		binary.BigEndian.PutUint64(b, uint64(k)^1<<63)
+	case uint:
+		binary.BigEndian.PutUint64(b, uint64(k))
+	case uint8:
+		binary.BigEndian.PutUint64(b, uint64(k))
+	case uint16:
+		binary.BigEndian.PutUint64(b, uint64(k))
+	case uint32:
+		binary.BigEndian.PutUint64(b, uint64(k))
+	case uint64:
+		binary.BigEndian.PutUint64(b, k)
+	default:
+		b, _ = json.Marshal(k)
+	}
+	return b
+}
+
+func decodeKey[K comparable](b []byte, k *K) error {
+	if p, ok := any(k).(*string); ok {
+		*p = string(b)
+		return nil
+	}
+	var n uint64
+	switch any(k).(type) {
+	case *int, *int8, *int16, *int32, *int64, *uint, *uint8, *uint16, *uint32, *uint64:
+		if len(b) != 8 {
+			return errors.New("kv: invalid integer key " + strconv.Quote(string(b)))
+		}
+		n = binary.BigEndian.Uint64(b)
+	}
+	switch p := any(k).(type) {
+	case *int:
+		*p = int(n ^ 1<<63)
+	case *int8:
+		*p = int8(n ^ 1<<63)
+	case *int16:
+		*p = int16(n ^ 1<<63)
+	case *int32:
+		*p = int32(n ^ 1<<63)
+	case *int64:
+		*p = int64(n ^ 1<<63)
+	case *uint:
+		*p = uint(n)
+	case *uint8:
+		*p = uint8(n)
+	case *uint16:
+		*p = uint16(n)
+	case *uint32:
+		*p = uint32(n)
+	case *uint64:
+		*p = n
+	default:
+		return json.Unmarshal(b, k)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package kv
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestTyped(t *testing.T) {
+	db := NewDB(NewMemory())
+	for _, c := range []Codec{JSON, Gob, MsgPack} {
+		tp := NewTyped[int64, point](db.Bucket("typed"), c)
+		assert.NoError(t, tp.Put(-1, point{1, 2}))
+		assert.NoError(t, tp.Put(2, point{3, 4}))
+		v, err := tp.Get(-1)
+		assert.NoError(t, err)
+		assert.Equal(t, point{1, 2}, v)
+		_, err = tp.Get(3)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		var keys []int64
+		assert.NoError(t, tp.ForEach(func(k int64, _ point) bool {
+			keys = append(keys, k)
+			return true
+		}))
+		assert.Equal(t, []int64{-1, 2}, keys)
+		assert.NoError(t, tp.Delete(-1))
+		assert.NoError(t, tp.Delete(2))
+	}
+}
+
+func TestTypedUpdate(t *testing.T) {
+	tp := NewTyped[string, int](NewDB(NewMemory()).Bucket("counter"), nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = tp.Update("n", func(v int, _ bool) (int, error) { return v + 1, nil })
+		}()
+	}
+	wg.Wait()
+	n, err := tp.Get("n")
+	assert.NoError(t, err)
+	assert.Equal(t, 50, n)
+
+	errAbort := errors.New("abort")
+	err = tp.Tx(func(tx *Tx[string, int]) error {
+		_ = tx.Put("a", 1)
+		tx.Delete("n")
+		_, err := tx.Get("n")
+		assert.ErrorIs(t, err, ErrNotFound)
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	n, _ = tp.Get("n")
+	assert.Equal(t, 50, n)
+
+	assert.NoError(t, tp.Tx(func(tx *Tx[string, int]) error {
+		n, _ := tx.Get("n")
+		_ = tx.Put("a", n)
+		tx.Delete("n")
+		return nil
+	}))
+	n, _ = tp.Get("a")
+	assert.Equal(t, 50, n)
+	_, err = tp.Get("n")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestTypedTxLock(t *testing.T) {
+	// 不同 DB 中的同名 bucket 互不阻塞
+	a := NewTyped[string, int](NewDB(NewMemory()).Bucket("x"), nil)
+	b := NewTyped[string, int](NewDB(NewMemory()).Bucket("x"), nil)
+	assert.NoError(t, a.Tx(func(*Tx[string, int]) error {
+		return b.Update("n", func(v int, _ bool) (int, error) { return v + 1, nil })
+	}))
+
+	// 同一 DB 中的同名 bucket 共享锁
+	db := NewDB(NewMemory())
+	c, d := NewTyped[string, int](db.Bucket("x"), nil), NewTyped[string, int](db.Bucket("x"), nil)
+	mc, err := c.lock()
+	assert.NoError(t, err)
+	md, err := d.lock()
+	assert.NoError(t, err)
+	assert.Same(t, mc, md)
+	me, _ := NewTyped[string, int](db.Bucket("y"), nil).lock()
+	assert.NotSame(t, mc, me)
+}
+
+func TestMigrate(t *testing.T) {
+	b := NewDB(NewMemory()).Bucket("m")
+	assert.NoError(t, b.Put([]byte("k"), []byte("1")))
+	tp := NewTyped[string, string](b, nil)
+	ran := 0
+	ms := []Migration{
+		{Version: 2, Up: func(Bucket) error {
+			ran++
+			return tp.Update("k", func(v string, _ bool) (string, error) { return v + "2", nil })
+		}},
+		{Version: 1, Up: func(b Bucket) error {
+			ran++
+			v, _ := b.Get([]byte("k"))
+			return tp.Put("k", string(v))
+		}},
+	}
+	assert.NoError(t, tp.Migrate(ms...))
+	assert.NoError(t, tp.Migrate(ms...))
+	assert.Equal(t, 2, ran)
+	v, err := tp.Get("k")
+	assert.NoError(t, err)
+	assert.Equal(t, "12", v)
+	ver, err := SchemaVersion(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ver)
+}
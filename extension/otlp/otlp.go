@@ -0,0 +1,311 @@
+// Package otlp provides a zero.Tracer which exports spans
+// to an OpenTelemetry collector by OTLP/HTTP JSON
+//
+//	t := otlp.New("http://127.0.0.1:4318/v1/traces", "zerobot")
+//	zero.SetTracer(t)
+//	defer t.Shutdown(context.Background())
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// Tracer 以 OTLP/HTTP JSON 批量导出 Span 的追踪器
+type Tracer struct {
+	Endpoint    string        // 如 http://127.0.0.1:4318/v1/traces
+	ServiceName string        // service.name 资源属性
+	BatchSize   int           // 单次导出的最大 Span 数, 默认 512
+	Interval    time.Duration // 导出间隔, 默认 5s
+	Client      *http.Client  // 为 nil 时使用 http.DefaultClient
+
+	once   sync.Once
+	spans  chan *span
+	flush  chan chan struct{}
+	closed chan struct{}
+}
+
+// New 返回导出到 endpoint 的 Tracer
+func New(endpoint, serviceName string) *Tracer {
+	return &Tracer{Endpoint: endpoint, ServiceName: serviceName}
+}
+
+func (t *Tracer) init() {
+	t.once.Do(func() {
+		if t.BatchSize <= 0 {
+			t.BatchSize = 512
+		}
+		if t.Interval <= 0 {
+			t.Interval = 5 * time.Second
+		}
+		t.spans = make(chan *span, t.BatchSize*4)
+		t.flush = make(chan chan struct{})
+		t.closed = make(chan struct{})
+		go t.loop()
+	})
+}
+
+type spanKey struct{}
+
+// Start implements zero.Tracer.
+func (t *Tracer) Start(c context.Context, name string, attrs ...zero.Attr) (context.Context, zero.Span) {
+	t.init()
+	s := &span{
+		tracer: t,
+		name:   name,
+		start:  time.Now(),
+		attrs:  attrs,
+	}
+	_, _ = rand.Read(s.spanID[:])
+	if parent, ok := c.Value(spanKey{}).(*span); ok {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	// api Span 为向 OneBot 实现发起的调用
+	if strings.HasPrefix(name, "api ") {
+		s.kind = 3 // SPAN_KIND_CLIENT
+	} else {
+		s.kind = 1 // SPAN_KIND_INTERNAL
+	}
+	return context.WithValue(c, spanKey{}, s), s
+}
+
+// Flush 立即导出已结束的 Span
+func (t *Tracer) Flush() {
+	t.init()
+	done := make(chan struct{})
+	select {
+	case t.flush <- done:
+		<-done
+	case <-t.closed:
+	}
+}
+
+// Shutdown 导出剩余的 Span 并停止导出
+func (t *Tracer) Shutdown(c context.Context) error {
+	t.init()
+	done := make(chan struct{})
+	go func() {
+		t.Flush()
+		select {
+		case <-t.closed:
+		default:
+			close(t.closed)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+type span struct {
+	tracer   *Tracer
+	mu       sync.Mutex
+	name     string
+	kind     int
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	start    time.Time
+	end      time.Time
+	attrs    []zero.Attr
+	err      error
+	ended    bool
+}
+
+func (s *span) SetAttributes(attrs ...zero.Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func (s *span) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	select {
+	case s.tracer.spans <- s:
+	case <-s.tracer.closed:
+	default:
+		zero.Log("otlp").Warn("导出队列已满, 丢弃 Span", zero.F("span", s.name))
+	}
+}
+
+func (t *Tracer) loop() {
+	tick := time.NewTicker(t.Interval)
+	defer tick.Stop()
+	batch := make([]*span, 0, t.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			zero.Log("otlp").Warn("导出 Span 失败", zero.F(zero.FieldError, err))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) >= t.BatchSize {
+				export()
+			}
+		case <-tick.C:
+			export()
+		case done := <-t.flush:
+			for n := len(t.spans); n > 0; n-- {
+				batch = append(batch, <-t.spans)
+			}
+			export()
+			close(done)
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+type (
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    string   `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+	status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            status     `json:"status"`
+	}
+)
+
+func toValue(v any) anyValue {
+	switch v := v.(type) {
+	case string:
+		return anyValue{StringValue: &v}
+	case bool:
+		return anyValue{BoolValue: &v}
+	case int:
+		return anyValue{IntValue: strconv.Itoa(v)}
+	case int64:
+		return anyValue{IntValue: strconv.FormatInt(v, 10)}
+	case uint64:
+		return anyValue{IntValue: strconv.FormatUint(v, 10)}
+	case float64:
+		return anyValue{DoubleValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return anyValue{StringValue: &s}
+	}
+}
+
+func (s *span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            status{Code: 1},
+	}
+	if s.parentID != [8]byte{} {
+		o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for _, a := range s.attrs {
+		o.Attributes = append(o.Attributes, keyValue{Key: a.Key, Value: toValue(a.Value)})
+	}
+	if s.err != nil {
+		o.Status = status{Code: 2, Message: s.err.Error()}
+	}
+	return o
+}
+
+func (t *Tracer) export(batch []*span) error {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = s.otlp()
+	}
+	service := t.ServiceName
+	if service == "" {
+		service = "zerobot"
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []keyValue{{Key: "service.name", Value: toValue(service)}},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": "github.com/wdvxdr1123/ZeroBot"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(c, http.MethodPost, t.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func TestExport(t *testing.T) {
+	bodies := make(chan []byte, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- b
+	}))
+	defer srv.Close()
+
+	tr := New(srv.URL, "test")
+	tr.Interval = time.Hour
+	c, parent := tr.Start(context.Background(), "event", zero.Attr{Key: "zerobot.self_id", Value: int64(1)})
+	_, child := tr.Start(c, "api send_msg")
+	child.SetError(errors.New("failed"))
+	child.End()
+	parent.End()
+	assert.NoError(t, tr.Shutdown(context.Background()))
+
+	body := <-bodies
+	assert.True(t, json.Valid(body))
+	res := gjson.ParseBytes(body).Get("resourceSpans.0")
+	assert.Equal(t, "test", res.Get("resource.attributes.0.value.stringValue").Str)
+	spans := res.Get("scopeSpans.0.spans").Array()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "api send_msg", spans[0].Get("name").Str)
+	assert.Equal(t, int64(3), spans[0].Get("kind").Int())
+	assert.Equal(t, int64(2), spans[0].Get("status.code").Int())
+	assert.Equal(t, spans[1].Get("spanId").Str, spans[0].Get("parentSpanId").Str)
+	assert.Equal(t, spans[1].Get("traceId").Str, spans[0].Get("traceId").Str)
+	assert.Len(t, spans[1].Get("traceId").Str, 32)
+	assert.Equal(t, "1", spans[1].Get("attributes.0.value.intValue").Str)
+}
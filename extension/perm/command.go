@@ -0,0 +1,190 @@
+package perm
+
+import (
+	"strconv"
+	"strings"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/extension"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+const usage = `用法:
+perm grant <qq> <节点> [global]
+perm revoke <qq> <节点> [global]
+perm assign <qq> <角色> [global]
+perm unassign <qq> <角色> [global]
+perm list [qq]
+perm roles
+perm role <角色> [节点...]
+qq 可用 @ 代替, 群聊中默认作用于本群, 带 global 时作用于全局
+global 与 role 需要超级用户或全局的 perm.manage, 群内只能授予自己拥有的节点`
+
+// RegisterCommands 以默认管理器在 engine 上注册权限管理命令
+func RegisterCommands(engine *zero.Engine) {
+	Default().RegisterCommands(engine)
+}
+
+// RegisterCommands 在 engine 上注册权限管理命令, 需要 NodeManage 节点
+func (m *Manager) RegisterCommands(engine *zero.Engine) {
+	engine.OnCommand("perm", m.Rule(NodeManage)).SetBlock(true).
+		Handle(func(ctx *zero.Ctx) {
+			model := extension.CommandModel{}
+			_ = ctx.Parse(&model)
+			ctx.Send(message.Text(m.command(ctx, strings.Fields(model.Args))))
+		})
+}
+
+func (m *Manager) command(ctx *zero.Ctx, args []string) string {
+	if len(args) == 0 {
+		return usage
+	}
+	sub, args := args[0], args[1:]
+	group := ctx.Event.GroupID
+	if n := len(args); n > 0 && args[n-1] == "global" {
+		group, args = Global, args[:n-1]
+	}
+	scope := "全局"
+	if group != Global {
+		scope = "群" + strconv.FormatInt(group, 10)
+	}
+	globalManager := m.isGlobalManager(ctx.Event.UserID)
+	switch sub {
+	case "grant", "revoke", "assign", "unassign":
+		user, args, ok := target(ctx, args)
+		if !ok || len(args) != 1 {
+			return usage
+		}
+		if !globalManager {
+			if group == Global {
+				return errNeedGlobal
+			}
+			nodes := []string{args[0]}
+			if sub == "assign" || sub == "unassign" {
+				nodes = m.roleNodes(args[0])
+			}
+			for _, node := range nodes {
+				if !m.grantable(ctx, node) {
+					return "权限不足: 你没有节点 " + node
+				}
+			}
+		}
+		var err error
+		switch sub {
+		case "grant":
+			err = m.Grant(group, user, args[0])
+		case "revoke":
+			err = m.Revoke(group, user, args[0])
+		case "assign":
+			err = m.Assign(group, user, args[0])
+		case "unassign":
+			err = m.Unassign(group, user, args[0])
+		}
+		if err != nil {
+			return "操作失败: " + err.Error()
+		}
+		return "已在" + scope + "对 " + strconv.FormatInt(user, 10) + " 执行 " + sub + " " + args[0]
+	case "list":
+		user, _, ok := target(ctx, args)
+		if !ok {
+			user = ctx.Event.UserID
+		}
+		sb := strings.Builder{}
+		sb.WriteString(strconv.FormatInt(user, 10))
+		for _, g := range []int64{Global, ctx.Event.GroupID} {
+			s := m.Subject(g, user)
+			name := "全局"
+			if g != Global {
+				name = "本群"
+			}
+			sb.WriteString("\n" + name + "角色: " + strings.Join(s.Roles, ", "))
+			sb.WriteString("\n" + name + "节点: " + strings.Join(s.Nodes, ", "))
+			if ctx.Event.GroupID == Global {
+				break
+			}
+		}
+		return sb.String()
+	case "roles":
+		sb := strings.Builder{}
+		sb.WriteString("---角色列表---")
+		for _, r := range m.Roles() {
+			sb.WriteString("\n" + r.Name)
+			if len(r.Inherits) > 0 {
+				sb.WriteString(" < " + strings.Join(r.Inherits, ", "))
+			}
+			if len(r.Nodes) > 0 {
+				sb.WriteString(": " + strings.Join(r.Nodes, ", "))
+			}
+		}
+		return sb.String()
+	case "role":
+		if len(args) == 0 {
+			return usage
+		}
+		if !globalManager {
+			return errNeedGlobal
+		}
+		r, _ := m.Role(args[0])
+		r.Name, r.Nodes = args[0], args[1:]
+		if err := m.DefineRole(r); err != nil {
+			return "操作失败: " + err.Error()
+		}
+		return "已设置角色 " + r.Name + ": " + strings.Join(r.Nodes, ", ")
+	}
+	return usage
+}
+
+const errNeedGlobal = "权限不足: 需要超级用户或全局的 " + NodeManage
+
+// isGlobalManager 判断用户是否为超级用户或在全局范围拥有 NodeManage
+func (m *Manager) isGlobalManager(user int64) bool {
+	return isSuperUser(user) || m.Check(Global, user, "", NodeManage)
+}
+
+// grantable 判断发送者能否授予 node: 需拥有 node,
+// 且通配节点不能覆盖发送者自身被拒绝的节点, 否则被授予者会得到发送者没有的权限
+func (m *Manager) grantable(ctx *zero.Ctx, node string) bool {
+	deny := strings.HasPrefix(node, "-")
+	node = strings.TrimPrefix(node, "-")
+	if !m.Has(ctx, node) {
+		return false
+	}
+	if deny || node != "*" && !strings.HasSuffix(node, ".*") {
+		return true
+	}
+	role := ""
+	if ctx.Event.Sender != nil {
+		role = ctx.Event.Sender.Role
+	}
+	for _, p := range m.Nodes(ctx.Event.GroupID, ctx.Event.UserID, role) {
+		if d, ok := strings.CutPrefix(p, "-"); ok && match(node, strings.TrimSuffix(d, ".*")) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// roleNodes 返回角色及其继承的角色的所有节点
+func (m *Manager) roleNodes(name string) []string {
+	m.load()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.expand([]string{name}, nil)
+}
+
+// target 取消息中首个 @ 的用户, 否则取 args 中第一个数字
+func target(ctx *zero.Ctx, args []string) (int64, []string, bool) {
+	for _, seg := range ctx.Event.Message {
+		if seg.Type == "at" {
+			if id, err := strconv.ParseInt(seg.Data["qq"], 10, 64); err == nil {
+				return id, args, true
+			}
+		}
+	}
+	if len(args) > 0 {
+		if id, err := strconv.ParseInt(args[0], 10, 64); err == nil {
+			return id, args[1:], true
+		}
+	}
+	return 0, args, false
+}
@@ -0,0 +1,402 @@
+// Package perm provides a role and permission node system
+// persisted by extension/kv
+//
+// 权限节点以 . 分隔, 如 music.play, 授予 music.* 即拥有 music 下的所有节点,
+// * 为所有节点, 以 - 开头表示拒绝, 如 -music.play.
+// 匹配时越具体的节点优先, 同样具体时拒绝优先
+package perm
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/extension/kv"
+)
+
+// 内置角色, 超级用户与 QQ 群主/管理员/成员自动拥有对应角色
+const (
+	RoleSuperUser = "superuser"
+	RoleOwner     = "owner"
+	RoleAdmin     = "admin"
+	RoleMember    = "member"
+)
+
+// Global 全局范围的群号
+const Global int64 = 0
+
+// NodeManage 管理权限所需的节点
+const NodeManage = "perm.manage"
+
+// ErrUnknownRole 角色不存在
+var ErrUnknownRole = errors.New("perm: unknown role")
+
+// Role 角色, 拥有 Nodes 及其继承的角色的所有节点
+type Role struct {
+	Name     string   `json:"name"`
+	Inherits []string `json:"inherits,omitempty"`
+	Nodes    []string `json:"nodes,omitempty"`
+}
+
+// Subject 用户在某一范围 (群或全局) 内被授予的角色与节点
+type Subject struct {
+	Roles []string `json:"roles,omitempty"`
+	Nodes []string `json:"nodes,omitempty"`
+}
+
+var builtinRoles = map[string]Role{
+	RoleSuperUser: {Name: RoleSuperUser, Nodes: []string{"*"}},
+	RoleOwner:     {Name: RoleOwner, Inherits: []string{RoleAdmin}},
+	RoleAdmin:     {Name: RoleAdmin, Inherits: []string{RoleMember}},
+	RoleMember:    {Name: RoleMember},
+}
+
+// Manager 角色与权限管理器
+type Manager struct {
+	roles    *kv.Typed[string, Role]
+	subjects *kv.Typed[string, Subject]
+
+	once      sync.Once
+	mu        sync.RWMutex
+	roleCache map[string]Role
+	subCache  map[string]Subject
+}
+
+// NewManager 使用 db 中的 perm.roles 与 perm.subjects 存储, db 为 nil 时使用 kv 默认数据库
+func NewManager(db *kv.DB) *Manager {
+	bucket := kv.New
+	if db != nil {
+		bucket = db.Bucket
+	}
+	return &Manager{
+		roles:    kv.NewTyped[string, Role](bucket("perm.roles"), kv.JSON),
+		subjects: kv.NewTyped[string, Subject](bucket("perm.subjects"), kv.JSON),
+	}
+}
+
+var (
+	defaultManager *Manager
+	defaultMu      sync.Mutex
+)
+
+// Default 返回默认管理器, 首次调用时以 kv 默认数据库创建
+func Default() *Manager {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultManager == nil {
+		defaultManager = NewManager(nil)
+	}
+	return defaultManager
+}
+
+// SetDefault 设置 PermissionRule 等包级函数使用的管理器
+func SetDefault(m *Manager) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultManager = m
+}
+
+func subjectKey(group, user int64) string {
+	return strconv.FormatInt(group, 10) + ":" + strconv.FormatInt(user, 10)
+}
+
+func (m *Manager) load() {
+	m.once.Do(func() {
+		m.roleCache = map[string]Role{}
+		m.subCache = map[string]Subject{}
+		err := m.roles.ForEach(func(k string, r Role) bool {
+			m.roleCache[k] = r
+			return true
+		})
+		if err == nil {
+			err = m.subjects.ForEach(func(k string, s Subject) bool {
+				m.subCache[k] = s
+				return true
+			})
+		}
+		if err != nil {
+			zero.Log("perm").Error("读取权限数据失败", zero.F(zero.FieldError, err))
+		}
+	})
+}
+
+// DefineRole 定义或覆盖角色, 可覆盖内置角色
+func (m *Manager) DefineRole(r Role) error {
+	if r.Name == "" {
+		return errors.New("perm: empty role name")
+	}
+	m.load()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.roles.Put(r.Name, r); err != nil {
+		return err
+	}
+	m.roleCache[r.Name] = r
+	return nil
+}
+
+// DeleteRole 删除自定义角色, 被覆盖的内置角色恢复默认
+func (m *Manager) DeleteRole(name string) error {
+	m.load()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.roles.Delete(name); err != nil {
+		return err
+	}
+	delete(m.roleCache, name)
+	return nil
+}
+
+// Role 返回角色定义
+func (m *Manager) Role(name string) (Role, bool) {
+	m.load()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.role(name)
+}
+
+func (m *Manager) role(name string) (Role, bool) {
+	if r, ok := m.roleCache[name]; ok {
+		return r, true
+	}
+	r, ok := builtinRoles[name]
+	return r, ok
+}
+
+// Roles 返回按名称排序的所有角色
+func (m *Manager) Roles() []Role {
+	m.load()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.roleCache)+len(builtinRoles))
+	for name := range builtinRoles {
+		names = append(names, name)
+	}
+	for name := range m.roleCache {
+		if _, ok := builtinRoles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	roles := make([]Role, 0, len(names))
+	for _, name := range names {
+		r, _ := m.role(name)
+		roles = append(roles, r)
+	}
+	return roles
+}
+
+// Subject 返回用户在 group 范围内被直接授予的角色与节点, group 为 Global 时为全局
+func (m *Manager) Subject(group, user int64) Subject {
+	m.load()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.subCache[subjectKey(group, user)]
+}
+
+func (m *Manager) updateSubject(group, user int64, f func(s *Subject) bool) error {
+	m.load()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := subjectKey(group, user)
+	s := m.subCache[key]
+	s = Subject{Roles: append([]string{}, s.Roles...), Nodes: append([]string{}, s.Nodes...)}
+	if !f(&s) {
+		return nil
+	}
+	var err error
+	if len(s.Roles) == 0 && len(s.Nodes) == 0 {
+		err = m.subjects.Delete(key)
+		if err == nil {
+			delete(m.subCache, key)
+		}
+		return err
+	}
+	if err = m.subjects.Put(key, s); err == nil {
+		m.subCache[key] = s
+	}
+	return err
+}
+
+func add(list []string, s string) ([]string, bool) {
+	for _, v := range list {
+		if v == s {
+			return list, false
+		}
+	}
+	return append(list, s), true
+}
+
+func remove(list []string, s string) ([]string, bool) {
+	for i, v := range list {
+		if v == s {
+			return append(list[:i], list[i+1:]...), true
+		}
+	}
+	return list, false
+}
+
+// Assign 在 group 范围内赋予用户角色
+func (m *Manager) Assign(group, user int64, role string) error {
+	if _, ok := m.Role(role); !ok {
+		return ErrUnknownRole
+	}
+	return m.updateSubject(group, user, func(s *Subject) (ok bool) {
+		s.Roles, ok = add(s.Roles, role)
+		return
+	})
+}
+
+// Unassign 在 group 范围内移除用户的角色
+func (m *Manager) Unassign(group, user int64, role string) error {
+	return m.updateSubject(group, user, func(s *Subject) (ok bool) {
+		s.Roles, ok = remove(s.Roles, role)
+		return
+	})
+}
+
+// Grant 在 group 范围内授予用户节点, 以 - 开头的节点表示拒绝
+func (m *Manager) Grant(group, user int64, node string) error {
+	return m.updateSubject(group, user, func(s *Subject) (ok bool) {
+		s.Nodes, ok = add(s.Nodes, node)
+		return
+	})
+}
+
+// Revoke 在 group 范围内撤销授予用户的节点
+func (m *Manager) Revoke(group, user int64, node string) error {
+	return m.updateSubject(group, user, func(s *Subject) (ok bool) {
+		s.Nodes, ok = remove(s.Nodes, node)
+		return
+	})
+}
+
+// Check 判断用户是否拥有 node
+//
+// qqRole 为用户在群内的 QQ 身份 (owner/admin/member), 可为空
+func (m *Manager) Check(group, user int64, qqRole, node string) bool {
+	m.load()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return allowed(m.nodes(group, user, qqRole), node)
+}
+
+// Nodes 返回用户在 group 内生效的所有节点
+func (m *Manager) Nodes(group, user int64, qqRole string) []string {
+	m.load()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.nodes(group, user, qqRole)
+}
+
+func (m *Manager) nodes(group, user int64, qqRole string) []string {
+	var roles, nodes []string
+	if isSuperUser(user) {
+		roles = append(roles, RoleSuperUser)
+	}
+	switch qqRole {
+	case RoleOwner, RoleAdmin:
+		roles = append(roles, qqRole)
+	}
+	roles = append(roles, RoleMember)
+	scopes := []int64{Global}
+	if group != Global {
+		scopes = append(scopes, group)
+	}
+	for _, g := range scopes {
+		s := m.subCache[subjectKey(g, user)]
+		roles = append(roles, s.Roles...)
+		nodes = append(nodes, s.Nodes...)
+	}
+	return m.expand(roles, nodes)
+}
+
+// expand 将 roles 及其继承的角色的节点追加到 nodes
+func (m *Manager) expand(roles, nodes []string) []string {
+	seen := map[string]bool{}
+	for len(roles) > 0 {
+		name := roles[0]
+		roles = roles[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if r, ok := m.role(name); ok {
+			nodes = append(nodes, r.Nodes...)
+			roles = append(roles, r.Inherits...)
+		}
+	}
+	return nodes
+}
+
+func isSuperUser(user int64) bool {
+	for _, su := range zero.GetConfig().SuperUsers {
+		if su == user {
+			return true
+		}
+	}
+	return false
+}
+
+// Has 判断事件的发送者是否拥有 node
+func (m *Manager) Has(ctx *zero.Ctx, node string) bool {
+	role := ""
+	if ctx.Event.Sender != nil {
+		role = ctx.Event.Sender.Role
+	}
+	return m.Check(ctx.Event.GroupID, ctx.Event.UserID, role, node)
+}
+
+// Rule 要求发送者拥有 node
+func (m *Manager) Rule(node string) zero.Rule {
+	return func(ctx *zero.Ctx) bool {
+		return m.Has(ctx, node)
+	}
+}
+
+// Apply 要求 engine 下的所有 matcher 的触发者拥有 node
+func (m *Manager) Apply(engine *zero.Engine, node string) {
+	engine.UsePreHandler(m.Rule(node))
+}
+
+// PermissionRule 要求发送者在默认管理器中拥有 node
+func PermissionRule(node string) zero.Rule {
+	return func(ctx *zero.Ctx) bool {
+		return Default().Has(ctx, node)
+	}
+}
+
+// allowed 以最具体的匹配决定是否拥有 node
+func allowed(patterns []string, node string) bool {
+	best, ok := -1, false
+	for _, p := range patterns {
+		deny := strings.HasPrefix(p, "-")
+		spec := match(strings.TrimPrefix(p, "-"), node)
+		if spec < 0 {
+			continue
+		}
+		if spec > best || (spec == best && deny) {
+			best, ok = spec, !deny
+		}
+	}
+	return ok
+}
+
+// match 返回 pattern 匹配 node 的具体程度, 不匹配时为 -1
+func match(pattern, node string) int {
+	switch {
+	case pattern == "*":
+		return 0
+	case pattern == node:
+		return 2*strings.Count(node, ".") + 3
+	case strings.HasSuffix(pattern, ".*"):
+		base := pattern[:len(pattern)-2]
+		if node == base || strings.HasPrefix(node, base+".") {
+			return 2*strings.Count(base, ".") + 2
+		}
+	}
+	return -1
+}
@@ -0,0 +1,99 @@
+package perm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/extension/kv"
+)
+
+func TestAllowed(t *testing.T) {
+	assert.True(t, allowed([]string{"*"}, "music.play"))
+	assert.True(t, allowed([]string{"music.*"}, "music"))
+	assert.True(t, allowed([]string{"music.*"}, "music.play.vip"))
+	assert.False(t, allowed([]string{"music.*"}, "musicx"))
+	assert.False(t, allowed([]string{"*", "-music.*"}, "music.play"))
+	assert.True(t, allowed([]string{"-music.*", "music.play"}, "music.play"))
+	assert.False(t, allowed([]string{"music.play", "-music.play"}, "music.play"))
+	assert.False(t, allowed(nil, "music.play"))
+}
+
+func TestManager(t *testing.T) {
+	db := kv.NewDB(kv.NewMemory())
+	m := NewManager(db)
+	old := *zero.GetConfig()
+	defer zero.ReloadConfig(&old)
+	zero.ReloadConfig(&zero.Config{SuperUsers: []int64{1}})
+
+	assert.True(t, m.Check(100, 1, "", "anything"))
+	assert.False(t, m.Check(100, 2, "admin", "music.play"))
+
+	assert.NoError(t, m.DefineRole(Role{Name: "moderator", Inherits: []string{RoleMember}, Nodes: []string{"music.*", "-music.admin"}}))
+	assert.NoError(t, m.DefineRole(Role{Name: RoleMember, Nodes: []string{"help"}}))
+	assert.ErrorIs(t, m.Assign(100, 2, "nobody"), ErrUnknownRole)
+	assert.NoError(t, m.Assign(100, 2, "moderator"))
+	assert.True(t, m.Check(100, 2, "", "music.play"))
+	assert.False(t, m.Check(100, 2, "", "music.admin"))
+	assert.False(t, m.Check(200, 2, "", "music.play"))
+	assert.True(t, m.Check(200, 2, "", "help"))
+	assert.True(t, m.Check(200, 3, "owner", "help"))
+
+	assert.NoError(t, m.Grant(Global, 2, "music.admin"))
+	assert.True(t, m.Check(200, 2, "", "music.admin"))
+	// 本群角色的拒绝与全局授予同样具体, 拒绝优先
+	assert.False(t, m.Check(100, 2, "", "music.admin"))
+
+	// 持久化后由新的管理器读取
+	m2 := NewManager(db)
+	assert.Equal(t, Subject{Roles: []string{"moderator"}}, m2.Subject(100, 2))
+	assert.True(t, m2.Check(100, 2, "", "music.play"))
+
+	assert.NoError(t, m.Unassign(100, 2, "moderator"))
+	assert.NoError(t, m.Revoke(Global, 2, "music.admin"))
+	assert.False(t, m.Check(100, 2, "", "music.play"))
+	assert.Equal(t, Subject{}, m.Subject(Global, 2))
+}
+
+func TestCommandScope(t *testing.T) {
+	m := NewManager(kv.NewDB(kv.NewMemory()))
+	old := *zero.GetConfig()
+	defer zero.ReloadConfig(&old)
+	zero.ReloadConfig(&zero.Config{SuperUsers: []int64{1}})
+	ctx := func(group, user int64) *zero.Ctx {
+		return &zero.Ctx{Event: &zero.Event{GroupID: group, UserID: user}}
+	}
+	cmd := func(c *zero.Ctx, args ...string) string { return m.command(c, args) }
+
+	// 超级用户授予 2 在群 100 内的管理权限与 music.*
+	assert.Contains(t, cmd(ctx(100, 1), "grant", "2", NodeManage), "已在")
+	assert.Contains(t, cmd(ctx(100, 1), "grant", "2", "music.*"), "已在")
+	assert.NoError(t, m.DefineRole(Role{Name: "root", Nodes: []string{"*"}}))
+
+	// 群管理员不能提升到全局或重新定义角色
+	assert.Equal(t, errNeedGlobal, cmd(ctx(100, 2), "grant", "2", "*", "global"))
+	assert.Equal(t, errNeedGlobal, cmd(ctx(0, 2), "grant", "2", "*"))
+	assert.Equal(t, errNeedGlobal, cmd(ctx(100, 2), "role", RoleMember, "*"))
+	assert.False(t, m.Check(200, 3, "", "anything"))
+	// 也不能授予自己没有的节点或角色
+	assert.Contains(t, cmd(ctx(100, 2), "grant", "3", "*"), "权限不足")
+	assert.Contains(t, cmd(ctx(100, 2), "assign", "3", "root"), "权限不足")
+	assert.Contains(t, cmd(ctx(200, 2), "grant", "3", "music.play"), "权限不足")
+	assert.Equal(t, Subject{}, m.Subject(100, 3))
+	// 可以在本群授予自己拥有的节点
+	assert.Contains(t, cmd(ctx(100, 2), "grant", "3", "music.play"), "已在")
+	assert.True(t, m.Check(100, 3, "", "music.play"))
+	// 自身被拒绝了 music.admin 时不能授予覆盖它的 music.*
+	assert.NoError(t, m.Grant(100, 2, "-music.admin"))
+	assert.Contains(t, cmd(ctx(100, 2), "grant", "6", "music.*"), "权限不足")
+	assert.Contains(t, cmd(ctx(100, 2), "grant", "6", "*"), "权限不足")
+	assert.False(t, m.Check(100, 6, "", "music.admin"))
+	assert.Contains(t, cmd(ctx(100, 2), "grant", "6", "music.play"), "已在")
+
+	// 全局管理员可以操作全局范围
+	assert.NoError(t, m.Grant(Global, 4, NodeManage))
+	assert.Contains(t, cmd(ctx(100, 4), "role", "dj", "music.*"), "已设置")
+	assert.Contains(t, cmd(ctx(100, 4), "assign", "5", "dj", "global"), "已在")
+	assert.True(t, m.Check(300, 5, "", "music.play"))
+}
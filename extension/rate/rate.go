@@ -87,6 +87,47 @@ func (lim *Limiter) AcquireN(n int) bool {
 	return false
 }
 
+// reserve 有令牌时消耗一个, 否则返回距离下一个令牌可用的时间
+//
+// 消耗后可由 cancel 归还, 用于需要在锁外确认的操作
+func (lim *Limiter) reserve() (time.Duration, bool) {
+	lim.Lock()
+	defer lim.Unlock()
+	lim.advance(time.Now())
+	if lack := 1 - lim.tokens; lack > 0 {
+		return lim.durationFromTokens(lack), false
+	}
+	lim.tokens--
+	return 0, true
+}
+
+// cancel 归还 reserve 消耗的令牌
+func (lim *Limiter) cancel() {
+	lim.Lock()
+	defer lim.Unlock()
+	lim.advance(time.Now())
+	lim.tokens++
+	if b := float64(lim.burst); lim.tokens > b {
+		lim.tokens = b
+	}
+}
+
+// Delay 返回距离下一个令牌可用的时间, 有令牌可用时为 0
+func (lim *Limiter) Delay() time.Duration {
+	return lim.DelayN(1)
+}
+
+// DelayN 返回距离 n 个令牌可用的时间, 有足够令牌时为 0
+func (lim *Limiter) DelayN(n int) time.Duration {
+	lim.Lock()
+	defer lim.Unlock()
+	lim.advance(time.Now())
+	if lack := float64(n) - lim.tokens; lack > 0 {
+		return lim.durationFromTokens(lack)
+	}
+	return 0
+}
+
 func (lim *Limiter) advance(now time.Time) {
 	last := lim.lastTime
 	elapsed := now.Sub(last)
@@ -0,0 +1,285 @@
+package rate
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/extension/kv"
+)
+
+// KeyFunc 由事件生成限制的键
+type KeyFunc func(ctx *zero.Ctx) string
+
+// UserKey 按用户限制
+func UserKey(ctx *zero.Ctx) string {
+	return "u" + strconv.FormatInt(ctx.Event.UserID, 10)
+}
+
+// GroupKey 按群限制, 私聊时按用户限制
+func GroupKey(ctx *zero.Ctx) string {
+	if ctx.Event.GroupID != 0 {
+		return "g" + strconv.FormatInt(ctx.Event.GroupID, 10)
+	}
+	return UserKey(ctx)
+}
+
+// CommandKey 按命令限制, 需位于命令规则之后
+func CommandKey(ctx *zero.Ctx) string {
+	cmd, _ := ctx.State["command"].(string)
+	return "c" + cmd
+}
+
+// Join 组合多个键, 如 Join(CommandKey, UserKey) 为每个用户的每个命令分别限制
+func Join(keys ...KeyFunc) KeyFunc {
+	return func(ctx *zero.Ctx) string {
+		s := ""
+		for i, k := range keys {
+			if i > 0 {
+				s += ":"
+			}
+			s += k(ctx)
+		}
+		return s
+	}
+}
+
+// Option 冷却与配额的配置项
+type Option func(*options)
+
+type options struct {
+	key            KeyFunc
+	exempt         bool
+	notice         func(ctx *zero.Ctx, msg string)
+	noticeInterval time.Duration
+	db             *kv.DB
+}
+
+func newOptions(op []Option) options {
+	o := options{
+		key:            UserKey,
+		exempt:         true,
+		noticeInterval: time.Minute,
+		notice: func(ctx *zero.Ctx, msg string) {
+			ctx.Send(msg)
+		},
+	}
+	for _, option := range op {
+		option(&o)
+	}
+	return o
+}
+
+// WithKey 指定限制的键, 默认为 UserKey
+func WithKey(key KeyFunc) Option {
+	return func(o *options) { o.key = key }
+}
+
+// WithSuperUser 超级用户是否同样受限, 默认不受限
+func WithSuperUser(limited bool) Option {
+	return func(o *options) { o.exempt = !limited }
+}
+
+// WithNotice 指定被限制时的提示方式, 为 nil 时不提示
+func WithNotice(fn func(ctx *zero.Ctx, msg string)) Option {
+	return func(o *options) { o.notice = fn }
+}
+
+// WithNoticeInterval 同一个键两次提示的最小间隔, 默认 1 分钟
+func WithNoticeInterval(d time.Duration) Option {
+	return func(o *options) { o.noticeInterval = d }
+}
+
+// WithDB 指定配额计数的存储, 默认使用 kv 默认数据库
+func WithDB(db *kv.DB) Option {
+	return func(o *options) { o.db = db }
+}
+
+// noticer 对提示本身限速
+type noticer struct {
+	fn      func(ctx *zero.Ctx, msg string)
+	limiter *LimiterManager[string]
+}
+
+func newNoticer(o *options) noticer {
+	return noticer{fn: o.notice, limiter: NewManager[string](o.noticeInterval, 1)}
+}
+
+func (n noticer) send(ctx *zero.Ctx, key, msg string) {
+	if n.fn != nil && n.limiter.Load(key).Acquire() {
+		n.fn(ctx, msg)
+	}
+}
+
+func exempted(o *options, ctx *zero.Ctx) bool {
+	return o.exempt && zero.SuperUserPermission(ctx)
+}
+
+// Cooldown 冷却限制
+type Cooldown struct {
+	o        options
+	limiters *LimiterManager[string]
+	noticer  noticer
+}
+
+// NewCooldown 每个键每 interval 恢复一次, 最多连续触发 burst 次
+func NewCooldown(interval time.Duration, burst int, op ...Option) *Cooldown {
+	c := &Cooldown{
+		o:        newOptions(op),
+		limiters: NewManager[string](interval, burst),
+	}
+	c.noticer = newNoticer(&c.o)
+	return c
+}
+
+// Wait 返回 ctx 距离冷却结束的时间
+func (c *Cooldown) Wait(ctx *zero.Ctx) time.Duration {
+	if exempted(&c.o, ctx) {
+		return 0
+	}
+	return c.limiters.Load(c.o.key(ctx)).Delay()
+}
+
+// Rule 冷却中时拒绝并提示剩余时间
+func (c *Cooldown) Rule() zero.Rule {
+	return func(ctx *zero.Ctx) bool {
+		if exempted(&c.o, ctx) {
+			return true
+		}
+		key := c.o.key(ctx)
+		lim := c.limiters.Load(key)
+		if lim.Acquire() {
+			return true
+		}
+		c.noticeWait(ctx, key, lim.Delay())
+		return false
+	}
+}
+
+func (c *Cooldown) noticeWait(ctx *zero.Ctx, key string, wait time.Duration) {
+	c.noticer.send(ctx, key, "冷却中, 请 "+strconv.Itoa(int(math.Ceil(wait.Seconds())))+"s 后再试")
+}
+
+// Apply 为 engine 下的所有 matcher 添加冷却
+func (c *Cooldown) Apply(engine *zero.Engine) {
+	engine.UseMidHandler(c.Rule())
+}
+
+// Quota 每日配额, 计数持久化于 kv, 次日零点 (本地时间) 重置
+type Quota struct {
+	o       options
+	limit   int
+	counter *kv.Typed[string, int]
+	noticer noticer
+}
+
+// NewQuota 每个键每日最多触发 limit 次, name 区分不同配额的计数
+func NewQuota(name string, limit int, op ...Option) *Quota {
+	q := &Quota{o: newOptions(op), limit: limit}
+	bucket := kv.New
+	if q.o.db != nil {
+		bucket = q.o.db.Bucket
+	}
+	q.counter = kv.NewTyped[string, int](bucket("rate.quota."+name), kv.JSON)
+	q.noticer = newNoticer(&q.o)
+	return q
+}
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+func dayKey(key string) string {
+	return time.Now().Format("20060102") + ":" + key
+}
+
+// Used 返回 ctx 今日已使用的次数
+func (q *Quota) Used(ctx *zero.Ctx) int {
+	n, _ := q.counter.Get(dayKey(q.o.key(ctx)))
+	return n
+}
+
+// Remaining 返回 ctx 今日剩余的次数
+func (q *Quota) Remaining(ctx *zero.Ctx) int {
+	if exempted(&q.o, ctx) {
+		return q.limit
+	}
+	if r := q.limit - q.Used(ctx); r > 0 {
+		return r
+	}
+	return 0
+}
+
+// take 消耗一次配额, 计数只需保留到次日
+func (q *Quota) take(key string) error {
+	day := dayKey(key)
+	return q.counter.Tx(func(tx *kv.Tx[string, int]) error {
+		n, err := tx.Get(day)
+		if err != nil && !errors.Is(err, kv.ErrNotFound) {
+			return err
+		}
+		if n >= q.limit {
+			return errQuotaExceeded
+		}
+		return tx.PutTTL(day, n+1, 48*time.Hour)
+	})
+}
+
+// allow 消耗一次配额, 超出时提示, 存储出错时记录日志并拒绝
+func (q *Quota) allow(ctx *zero.Ctx, key string) bool {
+	err := q.take(key)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errQuotaExceeded):
+		q.noticeExceeded(ctx, key)
+	default:
+		zero.Log("rate").Error("读写配额计数失败", zero.F(zero.FieldError, err))
+	}
+	return false
+}
+
+// Rule 超出配额时拒绝并提示
+func (q *Quota) Rule() zero.Rule {
+	return func(ctx *zero.Ctx) bool {
+		if exempted(&q.o, ctx) {
+			return true
+		}
+		return q.allow(ctx, q.o.key(ctx))
+	}
+}
+
+func (q *Quota) noticeExceeded(ctx *zero.Ctx, key string) {
+	q.noticer.send(ctx, key, "今日次数已用完 ("+strconv.Itoa(q.limit)+"次), 请明天再试")
+}
+
+// Apply 为 engine 下的所有 matcher 添加配额
+func (q *Quota) Apply(engine *zero.Engine) {
+	engine.UseMidHandler(q.Rule())
+}
+
+// Guard 同时应用冷却与配额, 两者均满足时才消耗, 适用于生成图片等开销较大的命令
+//
+// 先预留冷却的令牌, 再在锁外检查配额, 配额不足时归还令牌
+func Guard(c *Cooldown, q *Quota) zero.Rule {
+	return func(ctx *zero.Ctx) bool {
+		quota := func() bool {
+			return exempted(&q.o, ctx) || q.allow(ctx, q.o.key(ctx))
+		}
+		if exempted(&c.o, ctx) {
+			return quota()
+		}
+		key := c.o.key(ctx)
+		lim := c.limiters.Load(key)
+		wait, ok := lim.reserve()
+		if !ok {
+			c.noticeWait(ctx, key, wait)
+			return false
+		}
+		if !quota() {
+			lim.cancel()
+			return false
+		}
+		return true
+	}
+}
@@ -0,0 +1,103 @@
+package rate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/extension/kv"
+)
+
+func newCtx(user, group int64) *zero.Ctx {
+	return &zero.Ctx{Event: &zero.Event{UserID: user, GroupID: group}, State: zero.State{}}
+}
+
+func TestLimiterDelay(t *testing.T) {
+	lim := NewLimiter(time.Second, 1)
+	assert.Equal(t, time.Duration(0), lim.Delay())
+	assert.True(t, lim.Acquire())
+	d := lim.Delay()
+	assert.True(t, d > 900*time.Millisecond && d <= time.Second, d)
+}
+
+func TestCooldown(t *testing.T) {
+	var notices []string
+	notice := WithNotice(func(_ *zero.Ctx, msg string) { notices = append(notices, msg) })
+	c := NewCooldown(time.Minute, 1, WithKey(GroupKey), notice)
+	rule := c.Rule()
+	assert.True(t, rule(newCtx(1, 10)))
+	assert.False(t, rule(newCtx(2, 10)))
+	assert.False(t, rule(newCtx(2, 10)))
+	assert.True(t, rule(newCtx(2, 20)))
+	assert.Equal(t, []string{"冷却中, 请 60s 后再试"}, notices)
+
+	old := *zero.GetConfig()
+	defer zero.ReloadConfig(&old)
+	zero.ReloadConfig(&zero.Config{SuperUsers: []int64{1}})
+	assert.True(t, rule(newCtx(1, 10)))
+	assert.Equal(t, time.Duration(0), c.Wait(newCtx(1, 10)))
+}
+
+func TestQuota(t *testing.T) {
+	db := kv.NewDB(kv.NewMemory())
+	q := NewQuota("draw", 2, WithDB(db), WithNotice(nil))
+	rule := q.Rule()
+	ctx := newCtx(2, 10)
+	assert.True(t, rule(ctx))
+	assert.True(t, rule(ctx))
+	assert.False(t, rule(ctx))
+	assert.Equal(t, 0, q.Remaining(ctx))
+	// 计数持久化
+	assert.Equal(t, 2, NewQuota("draw", 5, WithDB(db)).Used(ctx))
+	assert.Equal(t, 2, q.Remaining(newCtx(3, 10)))
+
+	c := NewCooldown(time.Minute, 1, WithNotice(nil))
+	q = NewQuota("search", 1, WithDB(db), WithNotice(nil))
+	guard := Guard(c, q)
+	assert.True(t, guard(ctx))
+	// 冷却中不消耗配额
+	assert.False(t, guard(ctx))
+	assert.Equal(t, 0, q.Remaining(ctx))
+	other := newCtx(4, 10)
+	_ = q.Rule()(other)
+	// 配额不足不进入冷却
+	assert.False(t, guard(other))
+	assert.Equal(t, time.Duration(0), c.Wait(other))
+}
+
+func TestGuardNoticeOutsideLock(t *testing.T) {
+	// 配额提示中再次经过同一 Guard 不会死锁, 提示时冷却的令牌已归还
+	var guard zero.Rule
+	inner := make(chan bool, 1)
+	q := NewQuota("slow", 0, WithDB(kv.NewDB(kv.NewMemory())), WithNotice(func(ctx *zero.Ctx, _ string) {
+		inner <- guard(ctx)
+	}))
+	c := NewCooldown(time.Minute, 1, WithNotice(nil))
+	guard = Guard(c, q)
+	done := make(chan bool)
+	go func() { done <- guard(newCtx(2, 10)) }()
+	select {
+	case ok := <-done:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("guard blocked while sending the quota notice")
+	}
+	assert.False(t, <-inner)
+	assert.Equal(t, time.Duration(0), c.Wait(newCtx(2, 10)))
+}
+
+type failBackend struct{ kv.Backend }
+
+func (failBackend) Get([]byte) ([]byte, error) { return nil, errors.New("disk failure") }
+
+func TestQuotaStorageError(t *testing.T) {
+	var notices []string
+	q := NewQuota("draw", 2, WithDB(kv.NewDB(failBackend{kv.NewMemory()})),
+		WithNotice(func(_ *zero.Ctx, msg string) { notices = append(notices, msg) }))
+	assert.False(t, q.Rule()(newCtx(2, 10)))
+	assert.Empty(t, notices)
+	assert.NotErrorIs(t, q.take("u2"), errQuotaExceeded)
+}
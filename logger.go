@@ -0,0 +1,249 @@
+package zero
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Level 日志级别, 取值与 log/slog 相同
+type Level int
+
+// 日志级别
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "debug"
+	case l < LevelWarn:
+		return "info"
+	case l < LevelError:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText 解析 debug/info/warn/error 或数字
+func (l *Level) UnmarshalText(b []byte) error {
+	switch strings.ToLower(string(b)) {
+	case "debug":
+		*l = LevelDebug
+	case "info":
+		*l = LevelInfo
+	case "warn", "warning":
+		*l = LevelWarn
+	case "error":
+		*l = LevelError
+	default:
+		n, err := strconv.Atoi(string(b))
+		if err != nil {
+			return errors.New("zero: unknown log level " + strconv.Quote(string(b)))
+		}
+		*l = Level(n)
+	}
+	return nil
+}
+
+// 常用日志字段名
+const (
+	FieldSelfID  = "self_id"
+	FieldGroupID = "group_id"
+	FieldUserID  = "user_id"
+	FieldEvent   = "event" // 事件序号
+	FieldAction  = "action"
+	FieldMatcher = "matcher"
+	FieldError   = "error"
+)
+
+// Field 结构化日志字段
+type Field struct {
+	Key   string
+	Value any
+}
+
+// F 构造日志字段
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger 日志输出, 可由 logrus, log/slog 等适配
+//
+// subsystem 为产生日志的子系统, 如 bot, api, ws, wss, httpserver
+type Logger interface {
+	Log(level Level, subsystem, msg string, fields []Field)
+}
+
+// Redactor 在输出前处理字段值, 用于隐去 token, cookie 等敏感信息
+type Redactor func(key string, value any) any
+
+type logrusLogger struct {
+	l *logrus.Logger
+}
+
+// NewLogrusLogger 以 logrus 输出日志, 消息以 [subsystem] 开头, 字段作为 logrus.Fields
+func NewLogrusLogger(l *logrus.Logger) Logger {
+	if l == nil {
+		l = logrus.StandardLogger()
+	}
+	return logrusLogger{l: l}
+}
+
+func logrusLevel(level Level) logrus.Level {
+	switch {
+	case level < LevelInfo:
+		return logrus.DebugLevel
+	case level < LevelWarn:
+		return logrus.InfoLevel
+	case level < LevelError:
+		return logrus.WarnLevel
+	}
+	return logrus.ErrorLevel
+}
+
+// Enabled 由 logrus 的级别决定
+func (l logrusLogger) Enabled(level Level) bool {
+	return l.l.IsLevelEnabled(logrusLevel(level))
+}
+
+func (l logrusLogger) Log(level Level, subsystem, msg string, fields []Field) {
+	lv := logrusLevel(level)
+	if !l.l.IsLevelEnabled(lv) {
+		return
+	}
+	entry := logrus.NewEntry(l.l)
+	if len(fields) > 0 {
+		fs := make(logrus.Fields, len(fields))
+		for _, f := range fields {
+			fs[f.Key] = f.Value
+		}
+		entry = entry.WithFields(fs)
+	}
+	entry.Log(lv, "["+subsystem+"] "+msg)
+}
+
+var defaultLogger = NewLogrusLogger(nil)
+
+var sensitiveKeys = map[string]bool{
+	"token": true, "access_token": true, "authorization": true, "secret": true,
+	"cookie": true, "cookies": true, "csrf_token": true, "bkn": true, "password": true,
+}
+
+var (
+	redactBase64Reg = regexp.MustCompile(`base64://[\w/+=]{32,}`)
+	redactPairReg   = regexp.MustCompile(`(?i)("?(?:access_token|token|cookies?|csrf_token|bkn|password|secret)"?\s*[:=]\s*"?)([^"&,\s}]+)`)
+	redactAuthReg   = regexp.MustCompile(`(?i)(authorization"?\s*[:=]\s*"?(?:(?:bearer|token)\s+)?)[\w.\-~+/]+=*`)
+)
+
+// DefaultRedactor 隐去敏感键的值, 并将字符串中的 base64 媒体替换为 md5,
+// 将 access_token=, "cookies":, Authorization: Bearer 等之后的内容替换为 ***
+func DefaultRedactor(key string, value any) any {
+	if sensitiveKeys[strings.ToLower(key)] {
+		return "***"
+	}
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	return RedactString(s)
+}
+
+// RedactString 对字符串执行 DefaultRedactor 的替换
+func RedactString(s string) string {
+	s = redactBase64Reg.ReplaceAllStringFunc(s, func(b string) string {
+		m := md5.Sum([]byte(b[9:]))
+		return "base64://" + hex.EncodeToString(m[:]) + ".md5"
+	})
+	s = redactPairReg.ReplaceAllString(s, "${1}***")
+	return redactAuthReg.ReplaceAllString(s, "${1}***")
+}
+
+// SubLogger 子系统的日志记录器
+type SubLogger struct {
+	subsystem string
+	fields    []Field
+}
+
+// Log 返回子系统 subsystem 的日志记录器, 输出到 Config.Logger
+func Log(subsystem string) SubLogger {
+	return SubLogger{subsystem: subsystem}
+}
+
+// With 返回附加了 fields 的记录器
+func (l SubLogger) With(fields ...Field) SubLogger {
+	l.fields = append(append([]Field{}, l.fields...), fields...)
+	return l
+}
+
+// Enabled 是否输出 level 级别的日志, 由 Config.LogLevels 决定,
+// 未配置时由 Logger 的 Enabled 方法 (如有) 决定
+func (l SubLogger) Enabled(level Level) bool {
+	levels := GetConfig().LogLevels
+	if min, ok := levels[l.subsystem]; ok {
+		return level >= min
+	}
+	if min, ok := levels["*"]; ok {
+		return level >= min
+	}
+	if le, ok := l.logger().(interface{ Enabled(Level) bool }); ok {
+		return le.Enabled(level)
+	}
+	return true
+}
+
+func (l SubLogger) logger() Logger {
+	if l := GetConfig().Logger; l != nil {
+		return l
+	}
+	return defaultLogger
+}
+
+func (l SubLogger) log(level Level, msg string, fields []Field) {
+	countLog(l.subsystem, level)
+	if !l.Enabled(level) {
+		return
+	}
+	all := fields
+	if len(l.fields) > 0 {
+		all = append(append(make([]Field, 0, len(l.fields)+len(fields)), l.fields...), fields...)
+	}
+	redact := GetConfig().Redact
+	if redact == nil {
+		redact = DefaultRedactor
+	}
+	for i, f := range all {
+		if err, ok := f.Value.(error); ok {
+			f.Value = err.Error()
+		}
+		all[i].Value = redact(f.Key, f.Value)
+	}
+	l.logger().Log(level, l.subsystem, msg, all)
+}
+
+// Debug 输出 debug 日志
+func (l SubLogger) Debug(msg string, fields ...Field) { l.log(LevelDebug, msg, fields) }
+
+// Info 输出 info 日志
+func (l SubLogger) Info(msg string, fields ...Field) { l.log(LevelInfo, msg, fields) }
+
+// Warn 输出 warn 日志
+func (l SubLogger) Warn(msg string, fields ...Field) { l.log(LevelWarn, msg, fields) }
+
+// Error 输出 error 日志
+func (l SubLogger) Error(msg string, fields ...Field) { l.log(LevelError, msg, fields) }
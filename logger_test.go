@@ -0,0 +1,64 @@
+package zero
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type logEntry struct {
+	level     Level
+	subsystem string
+	msg       string
+	fields    map[string]any
+}
+
+type recordLogger struct {
+	entries []logEntry
+}
+
+func (r *recordLogger) Log(level Level, subsystem, msg string, fields []Field) {
+	m := make(map[string]any, len(fields))
+	for _, f := range fields {
+		m[f.Key] = f.Value
+	}
+	r.entries = append(r.entries, logEntry{level, subsystem, msg, m})
+}
+
+func TestRedactString(t *testing.T) {
+	b64 := strings.Repeat("QUJD", 16)
+	s := RedactString(`{"file":"base64://` + b64 + `","access_token":"abc"} cookies=uin=1;p_skey=2 Authorization: Bearer xyz`)
+	assert.NotContains(t, s, b64)
+	assert.Contains(t, s, ".md5")
+	assert.NotContains(t, s, "abc")
+	assert.NotContains(t, s, "p_skey=2")
+	assert.NotContains(t, s, "xyz")
+	assert.Contains(t, s, "Authorization: Bearer ***")
+	assert.Equal(t, `{"authorization":"Token ***"}`, RedactString(`{"authorization":"Token abc.def"}`))
+	// 普通聊天内容不受影响
+	assert.Equal(t, "token is valid, bearer of news", RedactString("token is valid, bearer of news"))
+	assert.Equal(t, "***", DefaultRedactor("Token", "t"))
+	assert.Equal(t, int64(1), DefaultRedactor(FieldUserID, int64(1)))
+}
+
+func TestSubLogger(t *testing.T) {
+	rec := &recordLogger{}
+	setConfig(t, func(c *Config) {
+		c.Logger = rec
+		c.LogLevels = map[string]Level{"*": LevelWarn, "api": LevelDebug}
+	})
+
+	Log("bot").Info("ignored")
+	Log("api").Debug("call", F(FieldAction, "send_msg"), F("cookie", "secret"))
+	Log("ws").With(F(FieldSelfID, int64(1))).Error("fail", F(FieldError, errors.New("boom")))
+	assert.Len(t, rec.entries, 2)
+	assert.Equal(t, logEntry{LevelDebug, "api", "call", map[string]any{FieldAction: "send_msg", "cookie": "***"}}, rec.entries[0])
+	assert.Equal(t, logEntry{LevelError, "ws", "fail", map[string]any{FieldSelfID: int64(1), FieldError: "boom"}}, rec.entries[1])
+
+	var l Level
+	assert.NoError(t, l.UnmarshalText([]byte("WARN")))
+	assert.Equal(t, LevelWarn, l)
+	assert.Error(t, l.UnmarshalText([]byte("loud")))
+}
@@ -34,6 +34,9 @@ type Matcher struct {
 	Handler []Handler
 	// Engine 注册 Matcher 的 Engine，Engine可为一系列 Matcher 添加通用 Rule 和 其他钩子
 	Engine *Engine
+
+	// id 在 Engine 内的注册序号, 从 1 开始, 不随其它 Matcher 的删除变化
+	id int
 }
 
 var (
@@ -102,6 +105,10 @@ func StoreMatcher(m *Matcher) *Matcher {
 	// todo(wdvxdr): move to engine.
 	if m.Engine != nil {
 		m.Block = m.Block || m.Engine.block
+		if m.id == 0 {
+			m.Engine.matcherSeq++
+			m.id = m.Engine.matcherSeq
+		}
 	}
 	matcherList = append(matcherList, m)
 	sortMatcher()
@@ -136,6 +143,7 @@ func (m *Matcher) copy() *Matcher {
 		Handler:  m.Handler,
 		Temp:     m.Temp,
 		Engine:   m.Engine,
+		id:       m.id,
 	}
 }
 
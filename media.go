@@ -0,0 +1,213 @@
+package zero
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// UploadOversizeMedia 返回用于 message.Fetcher.Oversize 的钩子,
+// 将过大而无法以 base64 发送的媒体通过 upload_group_file (私聊为 upload_private_file) 上传,
+// 并以一段提示文本代替原消息段
+//
+// 上传时传递的是本机的绝对路径 (未启用缓存时为临时文件), 因此要求 OneBot 实现与 bot
+// 运行在同一主机或共享同一文件系统; 实现端在远程时应改用其它方式 (如先上传到对象存储再发送链接)
+//
+//	f := *message.DefaultFetcher()
+//	f.MaxBase64Size = 8 << 20
+//	f.Oversize = ctx.UploadOversizeMedia("")
+//	seg, err := f.FileReader(r, "report.pdf")
+func (ctx *Ctx) UploadOversizeMedia(folder string) message.OversizeHook {
+	return func(m *message.Media, _, name string) (message.Segment, error) {
+		if ctx.Event == nil {
+			return message.Segment{}, errors.New("zero: upload oversize media without event")
+		}
+		if name == "" {
+			name = m.Hash + m.Ext()
+		}
+		path := m.Path
+		if path == "" { // 未启用缓存, 写入临时文件
+			f, err := os.CreateTemp("", "zerobot-upload-*"+m.Ext())
+			if err != nil {
+				return message.Segment{}, err
+			}
+			path = f.Name()
+			_, err = f.Write(m.Data)
+			_ = f.Close()
+			defer os.Remove(path)
+			if err != nil {
+				return message.Segment{}, err
+			}
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return message.Segment{}, err
+		}
+		action, params := "upload_group_file", Params{"group_id": ctx.Event.GroupID, "file": path, "name": name, "folder": folder}
+		if ctx.Event.GroupID == 0 {
+			// 多数实现成功时不返回 file_id, 以 retcode 判断
+			action, params = "upload_private_file", Params{"user_id": ctx.Event.UserID, "file": path, "name": name}
+		}
+		if rsp := ctx.CallAction(action, params); rsp.RetCode != 0 {
+			return message.Segment{}, errors.New("zero: " + action + " failed: " + rsp.Message)
+		}
+		return message.Text("[文件已上传: ", name, "]"), nil
+	}
+}
+
+// DownloadOption 修改 Download 使用的 message.Fetcher
+type DownloadOption func(f *message.Fetcher)
+
+// DownloadMaxSize 限制下载的最大字节数
+func DownloadMaxSize(n int64) DownloadOption {
+	return func(f *message.Fetcher) { f.MaxSize = n }
+}
+
+// DownloadTimeout 设置下载超时
+func DownloadTimeout(d time.Duration) DownloadOption {
+	return func(f *message.Fetcher) { f.Timeout = d }
+}
+
+// DownloadToDir 将文件流式写入 dir (内容寻址), 而不是保存在内存中
+func DownloadToDir(dir string) DownloadOption {
+	return func(f *message.Fetcher) { f.CacheDir = dir }
+}
+
+// Download 下载收到的 image/record/video/file 消息段
+//
+// 按消息段中的 url, 或 get_image/get_record/get_file/get_group_file_url
+// 等 API 解析出下载地址, 下载 NTQQ 链接遇到 rkey 过期时会通过 nc_get_rkey 刷新后重试.
+// 只读取上述 API 返回的本地路径, 消息段自身的 file:// 与本地路径会返回错误;
+// 下载使用 ctx.Context(), 可由 ctx.WithContext 取消
+func (ctx *Ctx) Download(seg message.Segment, opts ...DownloadOption) (*message.Media, error) {
+	f := *message.DefaultFetcher()
+	for _, opt := range opts {
+		opt(&f)
+	}
+	src, local, err := ctx.resolveMediaSource(seg)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(src, "base64://"):
+		data, err := base64.StdEncoding.DecodeString(src[9:])
+		if err != nil {
+			return nil, err
+		}
+		return f.Read(bytes.NewReader(data))
+	case strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://"):
+		c := ctx.Context()
+		m, err := f.Fetch(c, src)
+		var se *message.StatusError
+		if err != nil && errors.As(err, &se) && se.StatusCode >= 400 && se.StatusCode < 500 {
+			if refreshed, ok := ctx.refreshRKey(src); ok {
+				return f.Fetch(c, refreshed)
+			}
+		}
+		return m, err
+	case local:
+		file, err := os.Open(strings.TrimPrefix(src, "file://"))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return f.Read(file)
+	default:
+		return nil, errors.New("zero: refuse to download non-http source " + strconv.Quote(src))
+	}
+}
+
+// resolveMediaSource 解析消息段的下载地址, 可能为 url, 本地路径或 base64://
+//
+// local 表示 src 是 API 返回的本地路径
+func (ctx *Ctx) resolveMediaSource(seg message.Segment) (src string, local bool, err error) {
+	if u := seg.Data["url"]; u != "" {
+		return u, false, nil
+	}
+	file := seg.Data["file"]
+	if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") || strings.HasPrefix(file, "base64://") {
+		return file, false, nil
+	}
+	var rsp gjson.Result
+	switch seg.Type {
+	case "image":
+		rsp = ctx.GetImage(file)
+	case "record":
+		rsp = ctx.GetRecord(file, "mp3")
+	case "file", "video":
+		fileID := seg.Data["file_id"]
+		if fileID == "" {
+			fileID = file
+		}
+		if seg.Type == "file" && ctx.Event != nil {
+			u := ""
+			if ctx.Event.GroupID != 0 {
+				busid, _ := strconv.ParseInt(seg.Data["busid"], 10, 64)
+				u = ctx.GetGroupFileURL(ctx.Event.GroupID, busid, fileID)
+			} else {
+				u = ctx.GetPrivateFileURL(fileID)
+			}
+			if u != "" {
+				return u, false, nil
+			}
+		}
+		rsp = ctx.GetFile(fileID)
+	default:
+		return "", false, errors.New("zero: cannot download segment type " + seg.Type)
+	}
+	if b := rsp.Get("base64").Str; b != "" {
+		return "base64://" + b, false, nil
+	}
+	if p := rsp.Get("file").Str; p != "" {
+		if _, err := os.Stat(strings.TrimPrefix(p, "file://")); err == nil {
+			return p, true, nil // OneBot 实现与 bot 位于同一主机
+		}
+	}
+	if u := rsp.Get("url").Str; u != "" {
+		return u, false, nil
+	}
+	return "", false, errors.New("zero: cannot resolve source of " + seg.Type + " segment")
+}
+
+// refreshRKey 以 nc_get_rkey 获取的新 rkey 替换链接中的 rkey
+func (ctx *Ctx) refreshRKey(src string) (string, bool) {
+	u, err := url.Parse(src)
+	if err != nil || !u.Query().Has("rkey") {
+		return "", false
+	}
+	old := u.Query().Get("rkey")
+	// type 10 为私聊, 20 为群聊
+	want := int64(10)
+	if ctx.Event != nil && ctx.Event.GroupID != 0 {
+		want = 20
+	}
+	candidates := []string{}
+	for _, k := range ctx.NcGetRKey().Array() {
+		rkey := strings.TrimPrefix(k.Get("rkey").Str, "&rkey=")
+		if rkey == "" || rkey == old {
+			continue
+		}
+		if k.Get("type").Int() == want {
+			candidates = append([]string{rkey}, candidates...)
+		} else {
+			candidates = append(candidates, rkey)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	q := u.Query()
+	q.Set("rkey", candidates[0])
+	u.RawQuery = q.Encode()
+	return u.String(), true
+}
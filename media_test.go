@@ -0,0 +1,107 @@
+package zero
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+type fakeCaller func(request APIRequest) APIResponse
+
+func (f fakeCaller) CallAPI(_ context.Context, request APIRequest) (APIResponse, error) {
+	return f(request), nil
+}
+
+func TestCtx_Download(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("rkey") != "fresh" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("GIF89a......"))
+	}))
+	defer srv.Close()
+
+	var actions []string
+	ctx := &Ctx{
+		Event: &Event{GroupID: 1},
+		caller: fakeCaller(func(request APIRequest) APIResponse {
+			actions = append(actions, request.Action)
+			switch request.Action {
+			case "get_image":
+				return APIResponse{Data: gjson.Parse(`{"file":"/not/exist","url":"` + srv.URL + `/img?rkey=stale"}`)}
+			case "nc_get_rkey":
+				return APIResponse{Data: gjson.Parse(`[{"type":10,"rkey":"&rkey=private"},{"type":20,"rkey":"&rkey=fresh"}]`)}
+			}
+			return APIResponse{}
+		}),
+	}
+	m, err := ctx.Download(message.Segment{Type: "image", Data: map[string]string{"file": "abc.image"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"get_image", "nc_get_rkey"}, actions)
+	assert.Equal(t, "image/gif", m.MIME)
+	assert.Equal(t, int64(12), m.Size)
+
+	_, err = ctx.Download(message.Segment{Type: "image", Data: map[string]string{"url": srv.URL + "/img?rkey=fresh"}}, DownloadMaxSize(4))
+	assert.ErrorIs(t, err, message.ErrMediaTooLarge)
+
+	// 取消 ctx 的 context 时停止下载
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx.WithContext(c)
+	_, err = ctx.Download(message.Segment{Type: "image", Data: map[string]string{"url": srv.URL + "/img?rkey=fresh"}})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCtx_DownloadLocal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.gif")
+	assert.NoError(t, os.WriteFile(path, []byte("GIF89a......"), 0o600))
+	ctx := &Ctx{
+		Event: &Event{GroupID: 1},
+		caller: fakeCaller(func(request APIRequest) APIResponse {
+			return APIResponse{Data: gjson.Parse(`{"file":"` + path + `"}`)}
+		}),
+	}
+	// get_image 返回的本地路径可以读取
+	m, err := ctx.Download(message.Segment{Type: "image", Data: map[string]string{"file": "abc.image"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "image/gif", m.MIME)
+	// 消息段自身的本地路径不读取
+	for _, u := range []string{"file://" + path, path} {
+		_, err = ctx.Download(message.Segment{Type: "image", Data: map[string]string{"url": u}})
+		assert.Error(t, err, u)
+	}
+}
+
+func TestCtx_UploadOversizeMedia(t *testing.T) {
+	var params []Params
+	retcode := int64(0)
+	ctx := &Ctx{
+		Event: &Event{UserID: 2},
+		caller: fakeCaller(func(request APIRequest) APIResponse {
+			params = append(params, request.Params)
+			return APIResponse{Status: "ok", RetCode: retcode}
+		}),
+	}
+	m := &message.Media{Data: []byte("hello"), MIME: "text/plain", Size: 5, Hash: "h"}
+	// 私聊上传成功时多数实现不返回 file_id
+	seg, err := ctx.UploadOversizeMedia("")(m, "file", "a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, message.Text("[文件已上传: a.txt]"), seg)
+	assert.Equal(t, int64(2), params[0]["user_id"])
+	assert.True(t, filepath.IsAbs(params[0]["file"].(string)))
+
+	retcode = 100
+	_, err = ctx.UploadOversizeMedia("")(m, "file", "a.txt")
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package message
+
+// Builder 消息构建器, 以链式调用拼接消息
+//
+//	msg := message.NewBuilder().At(uid).Text("你好").If(ok, message.Face(14)).Build()
+type Builder struct {
+	msg Message
+}
+
+// NewBuilder 新建消息构建器, 可传入初始消息段
+func NewBuilder(seg ...Segment) *Builder {
+	b := &Builder{msg: make(Message, 0, len(seg)+4)}
+	return b.Append(seg...)
+}
+
+// Append 追加消息段, 相邻的纯文本将被合并
+func (b *Builder) Append(seg ...Segment) *Builder {
+	for _, s := range seg {
+		if s.Type == "text" && len(b.msg) > 0 && b.msg[len(b.msg)-1].Type == "text" {
+			last := b.msg[len(b.msg)-1]
+			b.msg[len(b.msg)-1] = Text(last.Data["text"], s.Data["text"]) // 不修改原 map
+			continue
+		}
+		b.msg = append(b.msg, s)
+	}
+	return b
+}
+
+// Message 追加一条完整消息
+func (b *Builder) Message(m Message) *Builder {
+	return b.Append(m...)
+}
+
+// Text 追加纯文本
+func (b *Builder) Text(text ...any) *Builder {
+	return b.Append(Text(text...))
+}
+
+// At 追加 @某人, qq 为 0 时 @全体成员
+func (b *Builder) At(qq int64) *Builder {
+	return b.Append(At(qq))
+}
+
+// AtAll 追加 @全体成员
+func (b *Builder) AtAll() *Builder {
+	return b.Append(AtAll())
+}
+
+// Face 追加 QQ 表情
+func (b *Builder) Face(id int) *Builder {
+	return b.Append(Face(id))
+}
+
+// Image 追加图片
+func (b *Builder) Image(file string, summary ...any) *Builder {
+	return b.Append(Image(file, summary...))
+}
+
+// Reply 追加回复, 回复段总是位于消息首部
+func (b *Builder) Reply(id any) *Builder {
+	b.msg = append(Message{Reply(id)}, b.msg...)
+	return b
+}
+
+// If cond 为真时追加消息段
+func (b *Builder) If(cond bool, seg ...Segment) *Builder {
+	if cond {
+		return b.Append(seg...)
+	}
+	return b
+}
+
+// When cond 为真时执行 f 构建部分消息
+func (b *Builder) When(cond bool, f func(b *Builder)) *Builder {
+	if cond {
+		f(b)
+	}
+	return b
+}
+
+// Join 以 sep 为间隔追加消息段
+func (b *Builder) Join(sep Segment, seg ...Segment) *Builder {
+	for i, s := range seg {
+		if i > 0 {
+			b.Append(sep)
+		}
+		b.Append(s)
+	}
+	return b
+}
+
+// JoinText 以 sep 为间隔追加多段纯文本
+func (b *Builder) JoinText(sep string, texts ...string) *Builder {
+	for i, s := range texts {
+		if i > 0 {
+			b.Text(sep)
+		}
+		b.Text(s)
+	}
+	return b
+}
+
+// Len 当前消息段数
+func (b *Builder) Len() int {
+	return len(b.msg)
+}
+
+// Reset 清空构建器
+func (b *Builder) Reset() *Builder {
+	b.msg = b.msg[:0]
+	return b
+}
+
+// Build 返回构建的消息, 后续修改构建器不影响返回值
+func (b *Builder) Build() Message {
+	m := make(Message, len(b.msg))
+	copy(m, b.msg)
+	return m
+}
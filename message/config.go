@@ -1,8 +1,7 @@
 package message
 
 import (
-	"io"
-	"net/http"
+	"context"
 )
 
 var (
@@ -14,11 +13,11 @@ func SetForceBase64File(x bool) {
 	forceBase64File = x
 }
 
+// dl 使用全局 Fetcher 下载文件, 受其超时与大小限制约束
 func dl(file string) ([]byte, error) {
-	resp, err := http.Get(file)
+	m, err := DefaultFetcher().Fetch(context.Background(), file)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	return io.ReadAll(resp.Body)
+	return m.Bytes()
 }
@@ -68,3 +68,13 @@ func ParseMessageFromString(raw string) (m Message) {
 	}
 	return m
 }
+
+// ReplyID 返回消息中回复段的消息 id
+func (m Message) ReplyID() (string, bool) {
+	for _, val := range m {
+		if val.Type == "reply" && val.Data["id"] != "" {
+			return val.Data["id"], true
+		}
+	}
+	return "", false
+}
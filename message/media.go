@@ -0,0 +1,344 @@
+package message
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/wdvxdr1123/ZeroBot/utils/helper"
+)
+
+// ErrMediaTooLarge 媒体超过 Fetcher.MaxSize 或无法以 base64 发送
+var ErrMediaTooLarge = errors.New("message: media too large")
+
+// StatusError 下载时服务器返回了非 200 状态码
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return "message: fetch " + e.URL + ": unexpected status " + strconv.Itoa(e.StatusCode)
+}
+
+// Media 读取到的媒体文件
+type Media struct {
+	Data []byte // 文件内容, 启用缓存时为 nil, 使用 Bytes 读取
+	Path string // 缓存文件路径, 未启用缓存时为空
+	MIME string // 嗅探得到的 MIME 类型
+	Size int64  // 字节数
+	Hash string // sha256 十六进制值
+}
+
+// Bytes 返回文件内容, 必要时从缓存读取
+func (m *Media) Bytes() ([]byte, error) {
+	if m.Data != nil || m.Path == "" {
+		return m.Data, nil
+	}
+	return os.ReadFile(m.Path)
+}
+
+// Ext 根据 MIME 类型推断的扩展名
+func (m *Media) Ext() string {
+	mt, _, _ := strings.Cut(m.MIME, ";")
+	exts, _ := mime.ExtensionsByType(mt)
+	if len(exts) > 0 {
+		return exts[len(exts)-1]
+	}
+	return ""
+}
+
+// OversizeHook 媒体大于 Fetcher.MaxBase64Size 时调用,
+// 返回用于替代的消息段, typ 为 image/record/video/file
+type OversizeHook func(m *Media, typ, name string) (Segment, error)
+
+// Fetcher 媒体获取器, 负责下载与读取媒体并转为消息段
+//
+// 零值可用, 此时无超时, 无大小限制且不缓存
+type Fetcher struct {
+	Client        *http.Client  // 为 nil 时使用 http.DefaultClient
+	Timeout       time.Duration // 单次下载超时, 0 为不限
+	MaxSize       int64         // 允许读取的最大字节数, 0 为不限
+	MaxBase64Size int64         // 超过此大小不再以 base64 发送, 0 为不限
+	CacheDir      string        // 内容寻址缓存目录, 为空时不缓存
+	Oversize      OversizeHook  // 超过 MaxBase64Size 时的处理, 为 nil 时使用缓存文件路径
+}
+
+// NewFetcher 默认配置的 Fetcher: 下载超时 1 分钟, 最大 64 MiB
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		Timeout: time.Minute,
+		MaxSize: 64 << 20,
+	}
+}
+
+var defaultFetcher atomic.Pointer[Fetcher]
+
+func init() {
+	defaultFetcher.Store(NewFetcher())
+}
+
+// DefaultFetcher 返回全局 Fetcher, 修改前请先拷贝
+func DefaultFetcher() *Fetcher {
+	return defaultFetcher.Load()
+}
+
+// SetDefaultFetcher 设置全局 Fetcher, 影响 SetForceBase64File 的下载行为
+func SetDefaultFetcher(f *Fetcher) {
+	if f == nil {
+		f = NewFetcher()
+	}
+	defaultFetcher.Store(f)
+}
+
+// Fetch 下载 url 指向的媒体, 启用缓存时命中则不再下载
+func (f *Fetcher) Fetch(ctx context.Context, url string) (*Media, error) {
+	if m := f.lookupURL(url); m != nil {
+		return m, nil
+	}
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	if f.MaxSize > 0 && resp.ContentLength > f.MaxSize {
+		return nil, ErrMediaTooLarge
+	}
+	m, err := f.Read(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.storeURL(url, m)
+	return m, nil
+}
+
+// Read 从 r 读取媒体, 超过 MaxSize 时返回 ErrMediaTooLarge
+func (f *Fetcher) Read(r io.Reader) (*Media, error) {
+	if f.MaxSize > 0 {
+		r = io.LimitReader(r, f.MaxSize+1)
+	}
+	h := sha256.New()
+	if f.CacheDir == "" {
+		data, err := io.ReadAll(io.TeeReader(r, h))
+		if err != nil {
+			return nil, err
+		}
+		if f.MaxSize > 0 && int64(len(data)) > f.MaxSize {
+			return nil, ErrMediaTooLarge
+		}
+		return &Media{
+			Data: data,
+			MIME: http.DetectContentType(data),
+			Size: int64(len(data)),
+			Hash: hex.EncodeToString(h.Sum(nil)),
+		}, nil
+	}
+	return f.readToCache(r, h)
+}
+
+// readToCache 边读边写入缓存目录, 完成后以哈希重命名
+func (f *Fetcher) readToCache(r io.Reader, h hash.Hash) (*Media, error) {
+	err := os.MkdirAll(f.CacheDir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(f.CacheDir, ".dl-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		_ = tmp.Close()
+		return nil, err
+	}
+	head = head[:n]
+	w := io.MultiWriter(tmp, h)
+	_, _ = w.Write(head)
+	size, err := io.Copy(w, r)
+	_ = tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+	size += int64(n)
+	if f.MaxSize > 0 && size > f.MaxSize {
+		return nil, ErrMediaTooLarge
+	}
+	m := &Media{
+		MIME: http.DetectContentType(head),
+		Size: size,
+		Hash: hex.EncodeToString(h.Sum(nil)),
+	}
+	m.Path = filepath.Join(f.CacheDir, m.Hash[:2], m.Hash+m.Ext())
+	if err = os.MkdirAll(filepath.Dir(m.Path), 0o755); err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(m.Path); err != nil {
+		if err = os.Rename(tmp.Name(), m.Path); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// urlIndexPath url 到内容哈希的索引文件
+func (f *Fetcher) urlIndexPath(url string) string {
+	s := sha256.Sum256(helper.StringToBytes(url))
+	return filepath.Join(f.CacheDir, "url", hex.EncodeToString(s[:]))
+}
+
+func (f *Fetcher) lookupURL(url string) *Media {
+	if f.CacheDir == "" {
+		return nil
+	}
+	idx, err := os.ReadFile(f.urlIndexPath(url))
+	if err != nil {
+		return nil
+	}
+	hs, mt, _ := strings.Cut(helper.BytesToString(idx), "\n")
+	m := &Media{MIME: mt, Hash: hs}
+	if len(hs) < 2 {
+		return nil
+	}
+	m.Path = filepath.Join(f.CacheDir, hs[:2], hs+m.Ext())
+	st, err := os.Stat(m.Path)
+	if err != nil {
+		return nil
+	}
+	m.Size = st.Size()
+	if f.MaxSize > 0 && m.Size > f.MaxSize {
+		return nil
+	}
+	return m
+}
+
+func (f *Fetcher) storeURL(url string, m *Media) {
+	if f.CacheDir == "" || m.Path == "" {
+		return
+	}
+	p := f.urlIndexPath(url)
+	if os.MkdirAll(filepath.Dir(p), 0o755) != nil {
+		return
+	}
+	_ = os.WriteFile(p, []byte(m.Hash+"\n"+m.MIME), 0o644)
+}
+
+// Segment 将媒体转为 typ 类型的消息段 (image/record/video/file)
+func (f *Fetcher) Segment(typ string, m *Media, name string) (Segment, error) {
+	file := ""
+	if f.MaxBase64Size > 0 && m.Size > f.MaxBase64Size {
+		switch {
+		case f.Oversize != nil:
+			return f.Oversize(m, typ, name)
+		case m.Path != "":
+			abs, err := filepath.Abs(m.Path)
+			if err != nil {
+				return Segment{}, err
+			}
+			file = "file:///" + strings.TrimPrefix(filepath.ToSlash(abs), "/")
+		default:
+			return Segment{}, ErrMediaTooLarge
+		}
+	} else {
+		data, err := m.Bytes()
+		if err != nil {
+			return Segment{}, err
+		}
+		file = "base64://" + base64.StdEncoding.EncodeToString(data)
+	}
+	seg := Segment{
+		Type: typ,
+		Data: map[string]string{
+			"file": file,
+		},
+	}
+	if typ == "file" {
+		if name == "" {
+			name = m.Hash + m.Ext()
+		}
+		seg.Data["name"] = name
+	}
+	return seg, nil
+}
+
+func (f *Fetcher) readerSegment(typ string, r io.Reader, name string) (Segment, error) {
+	m, err := f.Read(r)
+	if err != nil {
+		return Segment{}, err
+	}
+	return f.Segment(typ, m, name)
+}
+
+// ImageReader 从 r 读取图片
+func (f *Fetcher) ImageReader(r io.Reader, summary ...any) (Segment, error) {
+	seg, err := f.readerSegment("image", r, "")
+	if err == nil && len(summary) > 0 {
+		seg.Data["summary"] = fmt.Sprint(summary...)
+	}
+	return seg, err
+}
+
+// RecordReader 从 r 读取语音
+func (f *Fetcher) RecordReader(r io.Reader) (Segment, error) {
+	return f.readerSegment("record", r, "")
+}
+
+// VideoReader 从 r 读取短视频
+func (f *Fetcher) VideoReader(r io.Reader) (Segment, error) {
+	return f.readerSegment("video", r, "")
+}
+
+// FileReader 从 r 读取文件
+func (f *Fetcher) FileReader(r io.Reader, name string) (Segment, error) {
+	return f.readerSegment("file", r, name)
+}
+
+// ImageReader 使用全局 Fetcher 从 r 读取图片
+func ImageReader(r io.Reader, summary ...any) (Segment, error) {
+	return DefaultFetcher().ImageReader(r, summary...)
+}
+
+// RecordReader 使用全局 Fetcher 从 r 读取语音
+func RecordReader(r io.Reader) (Segment, error) {
+	return DefaultFetcher().RecordReader(r)
+}
+
+// VideoReader 使用全局 Fetcher 从 r 读取短视频
+func VideoReader(r io.Reader) (Segment, error) {
+	return DefaultFetcher().VideoReader(r)
+}
+
+// FileReader 使用全局 Fetcher 从 r 读取文件
+func FileReader(r io.Reader, name string) (Segment, error) {
+	return DefaultFetcher().FileReader(r, name)
+}
@@ -0,0 +1,71 @@
+package message
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n0000")
+
+func TestFetcherRead(t *testing.T) {
+	f := &Fetcher{MaxSize: 16}
+	seg, err := f.ImageReader(bytes.NewReader(pngHeader), "pic")
+	assert.NoError(t, err)
+	assert.Equal(t, Segment{Type: "image", Data: map[string]string{
+		"file":    "base64://" + base64.StdEncoding.EncodeToString(pngHeader),
+		"summary": "pic",
+	}}, seg)
+
+	_, err = f.FileReader(strings.NewReader(strings.Repeat("a", 17)), "a.txt")
+	assert.ErrorIs(t, err, ErrMediaTooLarge)
+
+	f = &Fetcher{MaxBase64Size: 4}
+	_, err = f.RecordReader(bytes.NewReader(pngHeader))
+	assert.ErrorIs(t, err, ErrMediaTooLarge)
+	f.Oversize = func(m *Media, typ, name string) (Segment, error) {
+		return Text(typ, m.Size, m.MIME, name), nil
+	}
+	seg, err = f.FileReader(bytes.NewReader(pngHeader), "a.png")
+	assert.NoError(t, err)
+	assert.Equal(t, Text("file12image/pnga.png"), seg)
+}
+
+func TestFetcherCache(t *testing.T) {
+	var hit int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hit, 1)
+		if r.URL.Path == "/slow" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		_, _ = w.Write(pngHeader)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	f := &Fetcher{CacheDir: dir, Timeout: 100 * time.Millisecond}
+	for i := 0; i < 2; i++ {
+		m, err := f.Fetch(context.Background(), srv.URL+"/a.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "image/png", m.MIME)
+		assert.Equal(t, int64(len(pngHeader)), m.Size)
+		data, err := os.ReadFile(m.Path)
+		assert.NoError(t, err)
+		assert.Equal(t, pngHeader, data)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hit))
+
+	_, err := f.Fetch(context.Background(), srv.URL+"/slow")
+	assert.Error(t, err)
+}
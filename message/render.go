@@ -0,0 +1,362 @@
+package message
+
+import (
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NameLookup 渲染 at 时将 qq 解析为显示名称, 返回空串时使用 qq 号
+type NameLookup func(qq int64) string
+
+// markdownSpecial 纯文本中需要转义的 Markdown 字符
+const markdownSpecial = "\\`*_[]()!<>~|"
+
+// Markdown 将消息渲染为 Markdown, 可由 ParseMarkdown 还原
+//
+//	at: <@123> <@all>
+//	face: <:face:14>
+//	image: ![summary](file)
+//	其它: <CQ:type,k=v>
+//
+// 含有其它字段的 at, face 与 image (如收到的图片带有 url 与 file_size) 同样使用 <CQ:type,k=v>, 以免丢失字段
+func (m Message) Markdown() string {
+	sb := strings.Builder{}
+	for _, seg := range m {
+		typ := seg.Type
+		if !markdownLossless(seg) {
+			typ = ""
+		}
+		switch typ {
+		case "text":
+			writeMarkdownEscaped(&sb, seg.Data["text"])
+		case "at":
+			sb.WriteString("<@")
+			sb.WriteString(seg.Data["qq"])
+			sb.WriteByte('>')
+		case "face":
+			sb.WriteString("<:face:")
+			sb.WriteString(seg.Data["id"])
+			sb.WriteByte('>')
+		case "image":
+			sb.WriteString("![")
+			writeMarkdownEscaped(&sb, seg.Data["summary"])
+			sb.WriteString("](")
+			sb.WriteString(escapeMarkdownURL(seg.Data["file"]))
+			sb.WriteByte(')')
+		default:
+			writeMarkupSegment(&sb, seg)
+		}
+	}
+	return sb.String()
+}
+
+// markdownLossless 判断消息段能否以简写的 Markdown 形式表示而不丢失字段
+func markdownLossless(seg Segment) bool {
+	var keys []string
+	switch seg.Type {
+	case "text":
+		return true
+	case "at":
+		if _, err := strconv.ParseInt(seg.Data["qq"], 10, 64); err != nil && seg.Data["qq"] != "all" {
+			return false
+		}
+		keys = []string{"qq"}
+	case "face":
+		if _, err := strconv.Atoi(seg.Data["id"]); err != nil {
+			return false
+		}
+		keys = []string{"id"}
+	case "image":
+		if seg.Data["file"] == "" || seg.Data["summary"] == "" && len(seg.Data) > 1 {
+			return false
+		}
+		keys = []string{"file", "summary"}
+	default:
+		return false
+	}
+	n := 0
+	for _, k := range keys {
+		if _, ok := seg.Data[k]; ok {
+			n++
+		}
+	}
+	return n == len(seg.Data)
+}
+
+// ParseMarkdown 将 Markdown 方法生成的标记解析为消息
+func ParseMarkdown(s string) Message {
+	m := Message{}
+	text := strings.Builder{}
+	flush := func() {
+		if text.Len() > 0 {
+			m = append(m, Text(text.String()))
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(markdownSpecial, s[i+1]) >= 0:
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '<':
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				break
+			}
+			if seg, ok := parseMarkupTag(s[i+1 : i+end]); ok {
+				flush()
+				m = append(m, seg)
+				i += end + 1
+				continue
+			}
+		case c == '!' && strings.HasPrefix(s[i:], "!["):
+			alt, n := readMarkdownLabel(s[i+2:])
+			if n < 0 || !strings.HasPrefix(s[i+2+n:], "(") {
+				break
+			}
+			rest := s[i+2+n+1:]
+			end := strings.IndexByte(rest, ')')
+			if end < 0 {
+				break
+			}
+			flush()
+			img := Segment{Type: "image", Data: map[string]string{"file": unescapeMarkdownURL(rest[:end])}}
+			if alt != "" {
+				img.Data["summary"] = alt
+			}
+			m = append(m, img)
+			i += 2 + n + 1 + end + 1
+			continue
+		}
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+	return m
+}
+
+// HTML 将消息渲染为 HTML 片段, lookup 可为 nil
+func (m Message) HTML(lookup NameLookup) string {
+	sb := strings.Builder{}
+	for _, seg := range m {
+		switch seg.Type {
+		case "text":
+			sb.WriteString(strings.ReplaceAll(html.EscapeString(seg.Data["text"]), "\n", "<br>"))
+		case "at":
+			sb.WriteString(`<span class="zb-at" data-qq="`)
+			sb.WriteString(html.EscapeString(seg.Data["qq"]))
+			sb.WriteString(`">@`)
+			sb.WriteString(html.EscapeString(atName(seg.Data["qq"], lookup)))
+			sb.WriteString(`</span>`)
+		case "face":
+			sb.WriteString(`<span class="zb-face" data-id="`)
+			sb.WriteString(html.EscapeString(seg.Data["id"]))
+			sb.WriteString(`">`)
+			sb.WriteString(html.EscapeString(faceText(seg.Data["id"])))
+			sb.WriteString(`</span>`)
+		case "image":
+			sb.WriteString(`<img class="zb-image" src="`)
+			sb.WriteString(html.EscapeString(safeURL(mediaURL(seg))))
+			sb.WriteString(`" alt="`)
+			sb.WriteString(html.EscapeString(seg.Data["summary"]))
+			sb.WriteString(`">`)
+		case "record":
+			sb.WriteString(`<audio class="zb-record" controls src="`)
+			sb.WriteString(html.EscapeString(safeURL(mediaURL(seg))))
+			sb.WriteString(`"></audio>`)
+		case "video":
+			sb.WriteString(`<video class="zb-video" controls src="`)
+			sb.WriteString(html.EscapeString(safeURL(mediaURL(seg))))
+			sb.WriteString(`"></video>`)
+		case "file":
+			sb.WriteString(`<a class="zb-file" href="`)
+			sb.WriteString(html.EscapeString(safeURL(mediaURL(seg))))
+			sb.WriteString(`">`)
+			sb.WriteString(html.EscapeString(seg.Data["name"]))
+			sb.WriteString(`</a>`)
+		default:
+			sb.WriteString(`<span class="zb-segment" data-type="`)
+			sb.WriteString(html.EscapeString(seg.Type))
+			sb.WriteString(`">`)
+			sb.WriteString(html.EscapeString(consoleSegment(seg, lookup)))
+			sb.WriteString(`</span>`)
+		}
+	}
+	return sb.String()
+}
+
+// Console 将消息渲染为适合终端与日志阅读的纯文本, lookup 可为 nil
+func (m Message) Console(lookup NameLookup) string {
+	sb := strings.Builder{}
+	for _, seg := range m {
+		if seg.Type == "text" {
+			sb.WriteString(seg.Data["text"])
+			continue
+		}
+		sb.WriteString(consoleSegment(seg, lookup))
+	}
+	return sb.String()
+}
+
+func consoleSegment(seg Segment, lookup NameLookup) string {
+	switch seg.Type {
+	case "text":
+		return seg.Data["text"]
+	case "at":
+		return "@" + atName(seg.Data["qq"], lookup)
+	case "face":
+		return faceText(seg.Data["id"])
+	case "image":
+		if s := seg.Data["summary"]; s != "" {
+			return "[图片:" + s + "]"
+		}
+		return "[图片]"
+	case "record":
+		return "[语音]"
+	case "video":
+		return "[视频]"
+	case "file":
+		return "[文件:" + seg.Data["name"] + "]"
+	case "reply":
+		return "[回复:" + seg.Data["id"] + "]"
+	case "forward", "node":
+		return "[合并转发]"
+	case "json", "xml":
+		return "[卡片]"
+	case "poke":
+		return "[戳一戳]"
+	case "music":
+		return "[音乐]"
+	default:
+		return "[" + seg.Type + "]"
+	}
+}
+
+func atName(qq string, lookup NameLookup) string {
+	if qq == "all" {
+		return "全体成员"
+	}
+	if lookup != nil {
+		if id, err := strconv.ParseInt(qq, 10, 64); err == nil {
+			if name := lookup(id); name != "" {
+				return name
+			}
+		}
+	}
+	return qq
+}
+
+func faceText(id string) string {
+	if i, err := strconv.Atoi(id); err == nil {
+		if r, ok := Emoji[i]; ok {
+			return string(r)
+		}
+	}
+	return "[表情" + id + "]"
+}
+
+func mediaURL(seg Segment) string {
+	if u := seg.Data["url"]; u != "" {
+		return u
+	}
+	return seg.Data["file"]
+}
+
+func writeMarkdownEscaped(sb *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(markdownSpecial, s[i]) >= 0 {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+}
+
+var (
+	markdownURLEscaper   = strings.NewReplacer("%", "%25", "(", "%28", ")", "%29", " ", "%20")
+	markdownURLUnescaper = strings.NewReplacer("%25", "%", "%28", "(", "%29", ")", "%20", " ")
+	markupEscaper        = strings.NewReplacer(">", "&#62;", "<", "&#60;")
+	markupUnescaper      = strings.NewReplacer("&#62;", ">", "&#60;", "<")
+)
+
+func escapeMarkdownURL(s string) string   { return markdownURLEscaper.Replace(s) }
+func unescapeMarkdownURL(s string) string { return markdownURLUnescaper.Replace(s) }
+
+// safeURL 仅放行相对地址与 http/https/data 协议, 其余 (如 javascript:) 返回空串
+func safeURL(s string) string {
+	i := strings.IndexByte(s, ':')
+	if i < 0 || strings.ContainsAny(s[:i], "/?#") {
+		return s
+	}
+	switch strings.ToLower(strings.TrimSpace(s[:i])) {
+	case "http", "https", "data":
+		return s
+	}
+	return ""
+}
+
+// writeMarkupSegment 以 <CQ:type,k=v> 形式写入消息段, 参数按键排序
+func writeMarkupSegment(sb *strings.Builder, seg Segment) {
+	keys := make([]string, 0, len(seg.Data))
+	for k := range seg.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sb.WriteString("<CQ:")
+	sb.WriteString(markupEscaper.Replace(EscapeCQCodeText(seg.Type)))
+	for _, k := range keys {
+		sb.WriteByte(',')
+		sb.WriteString(markupEscaper.Replace(EscapeCQCodeText(k)))
+		sb.WriteByte('=')
+		sb.WriteString(markupEscaper.Replace(EscapeCQCodeText(seg.Data[k])))
+	}
+	sb.WriteByte('>')
+}
+
+// parseMarkupTag 解析 < > 内的标记
+func parseMarkupTag(tag string) (Segment, bool) {
+	switch {
+	case strings.HasPrefix(tag, "@"):
+		qq := tag[1:]
+		if qq == "all" {
+			return AtAll(), true
+		}
+		if _, err := strconv.ParseInt(qq, 10, 64); err != nil {
+			return Segment{}, false
+		}
+		return Segment{Type: "at", Data: map[string]string{"qq": qq}}, true
+	case strings.HasPrefix(tag, ":face:"):
+		id, err := strconv.Atoi(tag[6:])
+		if err != nil {
+			return Segment{}, false
+		}
+		return Face(id), true
+	case strings.HasPrefix(tag, "CQ:"):
+		m := ParseMessageFromString("[" + markupUnescaper.Replace(tag) + "]")
+		if len(m) != 1 || m[0].Type == "text" {
+			return Segment{}, false
+		}
+		return m[0], true
+	}
+	return Segment{}, false
+}
+
+// readMarkdownLabel 读取 ] 之前的转义文本, 返回文本与消耗的字节数(含 ])
+func readMarkdownLabel(s string) (string, int) {
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && strings.IndexByte(markdownSpecial, s[i+1]) >= 0:
+			sb.WriteByte(s[i+1])
+			i++
+		case s[i] == ']':
+			return sb.String(), i + 1
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", -1
+}
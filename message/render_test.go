@@ -0,0 +1,51 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	msgs := []Message{
+		{Text("hello *world* [x](y) <@1> \\")},
+		{At(123), Text(" hi"), Face(14), AtAll()},
+		{Reply(int64(42)), Image("https://example.com/a (1).png", "pic]")},
+		{Image("https://x/a%20b.png"), Image("https://x/%2528.png")},
+		{Record("file:///tmp/a>b.amr"), Text("!["), Segment{Type: "json", Data: map[string]string{"data": `{"a":[1,2]}`}}},
+	}
+	for _, m := range msgs {
+		md := m.Markdown()
+		assert.Equal(t, m, ParseMarkdown(md), md)
+	}
+	assert.Equal(t, `<@123> hi<:face:14>![](a.png)`, Message{At(123), Text(" hi"), Face(14), Image("a.png")}.Markdown())
+
+	// 收到的图片带有 url 等字段, 以 CQ 标记保留全部字段
+	img := Segment{Type: "image", Data: map[string]string{
+		"file": "abc.image", "url": "https://x/a.png?rkey=1&b=2", "file_size": "1024", "summary": "[图片]", "sub_type": "0",
+	}}
+	m := Message{Text("see "), img, At(1), Segment{Type: "at", Data: map[string]string{"qq": "1", "name": "a"}}}
+	md := m.Markdown()
+	assert.Equal(t, m, ParseMarkdown(md), md)
+	assert.Equal(t, `<CQ:image,file=abc.image,file_size=1024,sub_type=0,summary=&#91;图片&#93;,url=https://x/a.png?rkey=1&amp;b=2>`,
+		Message{img}.Markdown())
+}
+
+func TestConsoleAndHTML(t *testing.T) {
+	m := Message{At(123), Text(" <hi>\n"), Face(14), Image("a.png", "cat"), Segment{Type: "mface", Data: map[string]string{}}}
+	lookup := func(qq int64) string {
+		if qq == 123 {
+			return "Alice"
+		}
+		return ""
+	}
+	assert.Equal(t, "@Alice <hi>\n🙂[图片:cat][mface]", m.Console(lookup))
+	assert.Equal(t, "@123 <hi>\n🙂[图片:cat][mface]", m.Console(nil))
+	assert.Equal(t,
+		`<span class="zb-at" data-qq="123">@Alice</span> &lt;hi&gt;<br><span class="zb-face" data-id="14">🙂</span>`+
+			`<img class="zb-image" src="a.png" alt="cat"><span class="zb-segment" data-type="mface">[mface]</span>`,
+		m.HTML(lookup))
+	assert.Equal(t,
+		`<img class="zb-image" src="" alt=""><a class="zb-file" href="https://x/a.txt">a</a>`,
+		Message{Image("javascript:alert(1)"), Segment{Type: "file", Data: map[string]string{"url": "https://x/a.txt", "name": "a"}}}.HTML(nil))
+}
@@ -0,0 +1,212 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// escapeFuncName 模板渲染时自动插入到每个输出动作末尾的转义函数
+const escapeFuncName = "_zerobot_cq_escape"
+
+// Template 消息模板, 语法同 text/template
+//
+// 模板中的文本与 {{.Field}} 等输出均按纯文本转义,
+// 只有以下函数会生成消息段:
+//
+//	{{at .UserID}} {{atall}} {{face 14}} {{image .URL}} {{image .URL "summary"}}
+//	{{record .File}} {{video .File}} {{reply .MessageID}} {{poke .UserID}}
+//
+// 实现了 encoding.TextUnmarshaler, 可直接作为配置文件字段
+type Template struct {
+	name string
+	text string
+	t    *template.Template
+}
+
+// TemplateFuncs 模板内置的消息段函数
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"at": func(qq any) (Segment, error) {
+			if s, ok := qq.(string); ok && s == "all" {
+				return AtAll(), nil
+			}
+			id, err := anyToInt64(qq)
+			if err != nil {
+				return Segment{}, err
+			}
+			return At(id), nil
+		},
+		"atall": AtAll,
+		"face": func(id any) (Segment, error) {
+			i, err := anyToInt64(id)
+			if err != nil {
+				return Segment{}, err
+			}
+			return Face(int(i)), nil
+		},
+		"image": func(file string, summary ...any) Segment {
+			return Image(file, summary...)
+		},
+		"record": Record,
+		"video":  Video,
+		"reply":  Reply,
+		"poke": func(qq any) (Segment, error) {
+			id, err := anyToInt64(qq)
+			if err != nil {
+				return Segment{}, err
+			}
+			return Poke(id), nil
+		},
+	}
+}
+
+// NewTemplate 解析消息模板, funcs 可追加或覆盖内置函数
+func NewTemplate(name, text string, funcs ...template.FuncMap) (*Template, error) {
+	t := template.New(name).Funcs(TemplateFuncs()).Funcs(template.FuncMap{
+		escapeFuncName: escapeTemplateValue,
+	})
+	for _, f := range funcs {
+		t = t.Funcs(f)
+	}
+	t, err := t.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, tpl := range t.Templates() {
+		if tpl.Tree != nil {
+			escapeTemplateNode(tpl.Tree, tpl.Tree.Root)
+		}
+	}
+	return &Template{name: name, text: text, t: t}, nil
+}
+
+// MustTemplate 同 NewTemplate, 出错时 panic
+func MustTemplate(name, text string, funcs ...template.FuncMap) *Template {
+	t, err := NewTemplate(name, text, funcs...)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// RenderTemplate 解析并渲染一次性模板
+func RenderTemplate(text string, data any) (Message, error) {
+	t, err := NewTemplate("", text)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(data)
+}
+
+// Render 以 data 渲染模板为消息
+func (t *Template) Render(data any) (Message, error) {
+	if t == nil || t.t == nil {
+		return nil, errors.New("message: render nil template")
+	}
+	sb := strings.Builder{}
+	err := t.t.Execute(&sb, data)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMessageFromString(sb.String()), nil
+}
+
+// String 返回模板原文
+func (t *Template) String() string {
+	if t == nil {
+		return ""
+	}
+	return t.text
+}
+
+// MarshalText impls encoding.TextMarshaler
+func (t *Template) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText impls encoding.TextUnmarshaler
+func (t *Template) UnmarshalText(text []byte) error {
+	nt, err := NewTemplate(t.name, string(text))
+	if err != nil {
+		return err
+	}
+	*t = *nt
+	return nil
+}
+
+// escapeTemplateValue 将模板输出转为 CQ 字符串片段, 消息段原样输出, 其余按纯文本转义
+func escapeTemplateValue(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case Segment:
+		if x.Type == "text" {
+			return EscapeCQText(x.Data["text"])
+		}
+		return x.String()
+	case Message:
+		return x.String()
+	case []Segment:
+		return Message(x).String()
+	default:
+		return EscapeCQText(fmt.Sprint(v))
+	}
+}
+
+// escapeTemplateNode 转义文本节点, 并在输出动作末尾追加转义函数
+func escapeTemplateNode(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			escapeTemplateNode(tree, c)
+		}
+	case *parse.TextNode:
+		n.Text = []byte(EscapeCQText(string(n.Text)))
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 { // 变量声明不输出
+			return
+		}
+		cmd := &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos}
+		cmd.Args = []parse.Node{parse.NewIdentifier(escapeFuncName).SetTree(tree).SetPos(n.Pos)}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, cmd)
+	case *parse.IfNode:
+		escapeTemplateNode(tree, n.List)
+		escapeTemplateNode(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeTemplateNode(tree, n.List)
+		escapeTemplateNode(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeTemplateNode(tree, n.List)
+		escapeTemplateNode(tree, n.ElseList)
+	}
+}
+
+func anyToInt64(v any) (int64, error) {
+	switch x := v.(type) {
+	case int64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case uint32:
+		return int64(x), nil
+	case uint64:
+		return int64(x), nil
+	case float64:
+		return int64(x), nil
+	case string:
+		return strconv.ParseInt(x, 10, 64)
+	case fmt.Stringer:
+		return strconv.ParseInt(x.String(), 10, 64)
+	default:
+		return 0, fmt.Errorf("message: cannot convert %T to int64", v)
+	}
+}
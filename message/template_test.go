@@ -0,0 +1,52 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	m := NewBuilder().
+		At(123).
+		Text("hello").
+		Text(" world").
+		If(false, Face(1)).
+		If(true, Face(14)).
+		Join(Text(","), Text("a"), Text("b")).
+		Reply(int64(42)).
+		Build()
+	assert.Equal(t, Message{Reply(int64(42)), At(123), Text("hello world"), Face(14), Text("a,b")}, m)
+}
+
+func TestTemplate(t *testing.T) {
+	tpl, err := NewTemplate("test", `{{at .UserID}} 你好 {{.Name}}[]{{face 14}}{{if .URL}}{{image .URL}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := tpl.Render(map[string]any{
+		"UserID": int64(123456),
+		"Name":   "[CQ:at,qq=all]&",
+		"URL":    "https://example.com/a.png",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Message{
+		At(123456),
+		Text(" 你好 [CQ:at,qq=all]&[]"),
+		Face(14),
+		Image("https://example.com/a.png"),
+	}, m)
+
+	var cfg struct {
+		Reply Template
+	}
+	assert.NoError(t, cfg.Reply.UnmarshalText([]byte(`{{range .}}{{.}}{{end}}{{atall}}`)))
+	m, err = cfg.Reply.Render([]int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, Message{Text("12"), AtAll()}, m)
+
+	_, err = RenderTemplate(`{{at "abc"}}`, nil)
+	assert.Error(t, err)
+}
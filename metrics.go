@@ -0,0 +1,90 @@
+package zero
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/wdvxdr1123/ZeroBot/utils/metrics"
+)
+
+var (
+	metricEvents = metrics.NewCounterVec("zerobot_events_total",
+		"接收的事件数", "post_type", "detail_type", "self_id")
+	metricEventsInFlight = metrics.NewGaugeVec("zerobot_events_in_flight",
+		"正在匹配与处理的事件数")
+	metricMatcherHits = metrics.NewCounterVec("zerobot_matcher_hits_total",
+		"通过所有 Rule 并被处理的次数", "engine", "matcher")
+	metricHandlerDuration = metrics.NewHistogramVec("zerobot_handler_duration_seconds",
+		"Matcher Handler 的处理时间", nil, "engine", "matcher")
+	metricAPIDuration = metrics.NewHistogramVec("zerobot_api_duration_seconds",
+		"API 调用时间", nil, "action")
+	metricAPIErrors = metrics.NewCounterVec("zerobot_api_errors_total",
+		"API 调用失败的次数, reason 为 error 或 retcode", "action", "reason")
+	_ = metrics.NewGaugeFunc("zerobot_event_queue_depth",
+		"事件环中等待处理的事件数", func() float64 { return float64(evring.depth()) })
+	_ = metrics.NewGaugeFunc("zerobot_api_callers",
+		"已连接的 APICaller 数", func() float64 {
+			n := 0
+			APICallers.Range(func(int64, APICaller) bool {
+				n++
+				return true
+			})
+			return float64(n)
+		})
+)
+
+// depth 返回事件环中尚未处理的事件数
+func (evr *eventRing) depth() uintptr {
+	evr.Lock()
+	defer evr.Unlock()
+	return evr.c - atomic.LoadUintptr(&evr.done)
+}
+
+// SetName 设置 Engine 在指标与日志中的名称
+func (e *Engine) SetName(name string) *Engine {
+	e.name = name
+	return e
+}
+
+// Name 返回 Engine 名称, 默认为调用 New 的包名
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// callerPackage 返回调用栈第 skip 层函数所在的包名
+func callerPackage(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+	fn := runtime.FuncForPC(pc).Name() // github.com/a/b/pkg.init.0
+	if i := strings.LastIndexByte(fn, '/'); i >= 0 {
+		fn = fn[i+1:]
+	}
+	if i := strings.IndexByte(fn, '.'); i >= 0 {
+		fn = fn[:i]
+	}
+	return fn
+}
+
+// matcherName 返回 engine#id 形式的 Matcher 名称
+func matcherName(m *Matcher) string {
+	_, label := matcherLabel(m)
+	return label
+}
+
+// matcherLabel 返回 Engine 名称与 engine#id 形式的 Matcher 名称
+//
+// 临时 Matcher 统一为 engine#temp, 以免标签无限增长
+func matcherLabel(m *Matcher) (string, string) {
+	name := ""
+	if m.Engine != nil {
+		name = m.Engine.Name()
+	}
+	if m.Temp || m.id == 0 {
+		return name, name + "#temp"
+	}
+	return name, name + "#" + strconv.Itoa(m.id)
+}
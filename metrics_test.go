@@ -0,0 +1,30 @@
+package zero
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineName(t *testing.T) {
+	e := New()
+	defer e.Delete()
+	assert.Equal(t, "ZeroBot", e.Name())
+	assert.Equal(t, "default", defaultEngine.Name())
+	first := e.OnMessage()
+	m := e.OnMessage()
+	engine, label := matcherLabel(m)
+	assert.Equal(t, "ZeroBot", engine)
+	assert.Equal(t, "ZeroBot#2", label)
+	// 删除其它 Matcher 后不变
+	first.Delete()
+	_, label = matcherLabel(m)
+	assert.Equal(t, "ZeroBot#2", label)
+	_, label = matcherLabel(e.OnMessage().copy())
+	assert.Equal(t, "ZeroBot#3", label)
+	tm := StoreTempMatcher(&Matcher{Engine: e})
+	defer tm.Delete()
+	_, label = matcherLabel(tm)
+	assert.Equal(t, "ZeroBot#temp", label)
+	assert.Equal(t, "plugin", e.SetName("plugin").Name())
+}
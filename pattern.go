@@ -29,8 +29,8 @@ func (p *Pattern) AsRule() Rule {
 		for i := 0; i < len(ctx.Event.Message); i++ {
 			if i > 0 && ctx.Event.Message[i-1].Type == "reply" && ctx.Event.Message[i].Type == "at" {
 				// [reply][at]
-				reply := ctx.GetMessage(ctx.Event.Message[i-1].Data["id"], true)
-				if reply.MessageID.ID() != 0 && reply.Sender != nil && reply.Sender.ID != 0 && strconv.FormatInt(reply.Sender.ID, 10) == ctx.Event.Message[i].Data["qq"] {
+				reply, err := ctx.GetMessageCached(ctx.Event.Message[i-1].Data["id"])
+				if err == nil && reply.Sender.ID != 0 && strconv.FormatInt(reply.Sender.ID, 10) == ctx.Event.Message[i].Data["qq"] {
 					continue
 				}
 			}
@@ -0,0 +1,120 @@
+package zero
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/FloatTech/ttl"
+)
+
+// KeyRepliedMessage RepliedHas 匹配成功后在 State 中存放被回复消息的键
+const KeyRepliedMessage = "replied_message"
+
+// ErrNoReply 当前消息没有回复任何消息
+var ErrNoReply = errors.New("zero: message does not reply to any message")
+
+// repliedMessages 被回复消息的缓存, 以 self_id 与消息 id 为键
+var repliedMessages = ttl.NewCache[string, *Message](time.Minute * 5)
+
+// ReplyID 返回当前消息回复的消息 id
+func (ctx *Ctx) ReplyID() (string, bool) {
+	if ctx.Event == nil {
+		return "", false
+	}
+	return ctx.Event.Message.ReplyID()
+}
+
+// RepliedMessage 获取当前消息回复 (引用) 的消息, 结果会被缓存
+func (ctx *Ctx) RepliedMessage() (*Message, error) {
+	id, ok := ctx.ReplyID()
+	if !ok {
+		return nil, ErrNoReply
+	}
+	return ctx.GetMessageCached(id)
+}
+
+// ReplyChain 沿回复链向上获取至多 depth 层被回复的消息,
+// 返回值按由近到远排列, 遇到无法获取的消息时停止
+func (ctx *Ctx) ReplyChain(depth int) ([]*Message, error) {
+	id, ok := ctx.ReplyID()
+	if !ok {
+		return nil, ErrNoReply
+	}
+	chain := make([]*Message, 0, depth)
+	seen := make(map[string]struct{}, depth)
+	for len(chain) < depth {
+		if _, ok := seen[id]; ok { // 防止成环
+			break
+		}
+		seen[id] = struct{}{}
+		m, err := ctx.GetMessageCached(id)
+		if err != nil {
+			if len(chain) == 0 {
+				return nil, err
+			}
+			break
+		}
+		chain = append(chain, m)
+		id, ok = m.Elements.ReplyID()
+		if !ok {
+			break
+		}
+	}
+	return chain, nil
+}
+
+// GetMessageCached 同 GetMessage, 但结果会被缓存 5 分钟
+func (ctx *Ctx) GetMessageCached(id string) (*Message, error) {
+	var selfID int64
+	if ctx.Event != nil {
+		selfID = ctx.Event.SelfID
+	}
+	key := strconv.FormatInt(selfID, 10) + ":" + id
+	if m := repliedMessages.Get(key); m != nil {
+		return m, nil
+	}
+	var m Message
+	if i, err := strconv.ParseInt(id, 10, 64); err == nil {
+		m = ctx.GetMessage(i, true)
+	} else {
+		m = ctx.GetMessage(id, true)
+	}
+	if m.MessageID.ID() == 0 || m.Sender == nil {
+		return nil, errors.New("zero: get message " + id + " failed")
+	}
+	repliedMessages.Set(key, &m)
+	return &m, nil
+}
+
+// HasReply 消息回复了其它消息
+func HasReply(ctx *Ctx) bool {
+	_, ok := ctx.ReplyID()
+	return ok
+}
+
+// RepliedHas 被回复的消息含有 types 中任一类型的消息段,
+// types 为空时只要求被回复的消息存在
+//
+// 匹配成功后被回复的消息存放于 ctx.State[KeyRepliedMessage]
+func RepliedHas(types ...string) Rule {
+	return func(ctx *Ctx) bool {
+		m, err := ctx.RepliedMessage()
+		if err != nil {
+			return false
+		}
+		if len(types) == 0 {
+			ctx.State[KeyRepliedMessage] = m
+			return true
+		}
+		for _, seg := range m.Elements {
+			for _, typ := range types {
+				if seg.Type == typ {
+					ctx.State[KeyRepliedMessage] = m
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
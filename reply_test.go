@@ -0,0 +1,44 @@
+package zero
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+func TestCtx_ReplyChain(t *testing.T) {
+	calls := 0
+	ctx := &Ctx{
+		Event: &Event{SelfID: 10001, Message: message.Message{message.Reply(3), message.Text("/ocr")}},
+		State: State{},
+		caller: fakeCaller(func(request APIRequest) APIResponse {
+			calls++
+			id := request.Params["message_id"].(int64)
+			msg := `[{"type":"text","data":{"text":"root"}}]`
+			if id > 1 {
+				msg = `[{"type":"reply","data":{"id":"` + strconv.FormatInt(id-1, 10) + `"}},{"type":"image","data":{"file":"a.image"}}]`
+			}
+			return APIResponse{Data: gjson.Parse(`{"message_id":` + strconv.FormatInt(id, 10) +
+				`,"message_type":"group","sender":{"user_id":` + strconv.FormatInt(id*100, 10) + `},"message":` + msg + `}`)}
+		}),
+	}
+	assert.True(t, HasReply(ctx))
+	assert.True(t, RepliedHas("image")(ctx))
+	m := ctx.State[KeyRepliedMessage].(*Message)
+	assert.Equal(t, int64(300), m.Sender.ID)
+
+	chain, err := ctx.ReplyChain(5)
+	assert.NoError(t, err)
+	assert.Len(t, chain, 3)
+	assert.Equal(t, "root", chain[2].Elements.ExtractPlainText())
+	assert.Equal(t, 3, calls) // 3 已被缓存
+
+	ctx.Event.Message = message.Message{message.Text("hi")}
+	_, err = ctx.RepliedMessage()
+	assert.ErrorIs(t, err, ErrNoReply)
+	assert.False(t, RepliedHas()(ctx))
+}
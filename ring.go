@@ -14,6 +14,8 @@ type eventRing struct {
 	r []*eventRingItem
 	i uintptr
 	p []eventRingItem
+	// done 已处理的事件数
+	done uintptr
 }
 
 type eventRingItem struct {
@@ -49,7 +51,7 @@ func (evr *eventRing) processEvent(response []byte, caller APICaller) {
 //
 //	latency 延迟 latency 再处理事件
 func (evr *eventRing) loop(latency, maxwait time.Duration, process func([]byte, APICaller, time.Duration)) {
-	go func(r []*eventRingItem) {
+	go func(r []*eventRingItem, done *uintptr) {
 		c := uintptr(0)
 		if latency < time.Millisecond {
 			latency = time.Millisecond
@@ -66,11 +68,12 @@ func (evr *eventRing) loop(latency, maxwait time.Duration, process func([]byte,
 			it.response = nil
 			it.caller = nil
 			c++
+			atomic.StoreUintptr(done, c)
 			totl += latency
 			if totl > time.Second {
 				totl = 0
 				runtime.GC()
 			}
 		}
-	}(evr.r)
+	}(evr.r, &evr.done)
 }
@@ -90,10 +90,11 @@ func CommandRule(commands ...string) Rule {
 		}
 		first := ctx.Event.Message[0]
 		firstMessage := first.Data["text"]
-		if !strings.HasPrefix(firstMessage, BotConfig.CommandPrefix) {
+		prefix := GetConfig().CommandPrefix
+		if !strings.HasPrefix(firstMessage, prefix) {
 			return false
 		}
-		cmdMessage := firstMessage[len(BotConfig.CommandPrefix):]
+		cmdMessage := firstMessage[len(prefix):]
 		for _, command := range commands {
 			if strings.HasPrefix(cmdMessage, command) {
 				ctx.State["command"] = command
@@ -218,7 +219,7 @@ func OnlyGuild(ctx *Ctx) bool {
 }
 
 func issu(id int64) bool {
-	for _, su := range BotConfig.SuperUsers {
+	for _, su := range GetConfig().SuperUsers {
 		if su == id {
 			return true
 		}
@@ -263,7 +264,7 @@ func GroupHigherPermission(gettarget func(ctx *Ctx) int64) Rule {
 		}
 		if SuperUserPermission(ctx) {
 			sender := ctx.Event.UserID
-			return BotConfig.GetFirstSuperUser(sender, target) == sender
+			return GetConfig().GetFirstSuperUser(sender, target) == sender
 		}
 		if ctx.Event.Sender.Role == "owner" {
 			return !issu(target) && ctx.GetThisGroupMemberInfo(target, false).Get("role").Str != "owner"
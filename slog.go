@@ -0,0 +1,34 @@
+//go:build go1.21
+
+package zero
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger 以 log/slog 输出日志, 子系统作为 subsystem 属性
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l: l}
+}
+
+// Enabled 由 slog.Handler 的级别决定
+func (l slogLogger) Enabled(level Level) bool {
+	return l.l.Enabled(context.Background(), slog.Level(level))
+}
+
+func (l slogLogger) Log(level Level, subsystem, msg string, fields []Field) {
+	attrs := make([]slog.Attr, 0, len(fields)+1)
+	attrs = append(attrs, slog.String("subsystem", subsystem))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.Key, f.Value))
+	}
+	l.l.LogAttrs(context.Background(), slog.Level(level), msg, attrs...)
+}
@@ -0,0 +1,255 @@
+package zero
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 运行时状态, 供管理接口等查询
+
+var (
+	engines   []*Engine
+	engineSeq int
+	enginesMu sync.RWMutex
+	startTime = time.Now()
+)
+
+func registerEngine(e *Engine) {
+	enginesMu.Lock()
+	e.id = engineSeq
+	engineSeq++
+	engines = append(engines, e)
+	enginesMu.Unlock()
+}
+
+func unregisterEngine(e *Engine) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	for i, en := range engines {
+		if en == e {
+			engines = append(engines[:i], engines[i+1:]...)
+			return
+		}
+	}
+}
+
+// ID 返回 Engine 的编号, 按创建顺序从 0 开始, 不随其它 Engine 的删除变化
+func (e *Engine) ID() int {
+	return e.id
+}
+
+// Engines 返回所有通过 New 创建且未被 Delete 的 Engine, 按创建顺序排列
+func Engines() []*Engine {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+	return append([]*Engine{}, engines...)
+}
+
+// Uptime 返回自程序启动以来的时间
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
+
+// Matchers 返回该 Engine 注册的所有 Matcher
+func (e *Engine) Matchers() []*Matcher {
+	matcherLock.RLock()
+	defer matcherLock.RUnlock()
+	return append([]*Matcher{}, e.matchers...)
+}
+
+// Registered 该 Matcher 是否仍在匹配列表中
+func (m *Matcher) Registered() bool {
+	matcherLock.RLock()
+	defer matcherLock.RUnlock()
+	for _, matcher := range matcherList {
+		if matcher == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Enable 在 gid 中启用 Engine, gid 为 0 时全局启用,
+// 私聊以 -user_id 作为 gid
+func (e *Engine) Enable(gid int64) *Engine {
+	e.setEnabled(gid, true)
+	return e
+}
+
+// Disable 在 gid 中禁用 Engine, gid 为 0 时全局禁用,
+// 私聊以 -user_id 作为 gid
+func (e *Engine) Disable(gid int64) *Engine {
+	e.setEnabled(gid, false)
+	return e
+}
+
+// Reset 清除 gid 的单独设置, 使其跟随全局状态
+func (e *Engine) Reset(gid int64) *Engine {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	delete(e.groups, gid)
+	e.hasState.Store(e.disabled || len(e.groups) > 0)
+	return e
+}
+
+func (e *Engine) setEnabled(gid int64, enable bool) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	if gid == 0 {
+		e.disabled = !enable
+	} else {
+		if e.groups == nil {
+			e.groups = map[int64]bool{}
+		}
+		e.groups[gid] = enable
+	}
+	e.hasState.Store(e.disabled || len(e.groups) > 0)
+}
+
+// IsEnabledIn Engine 在 gid 中是否启用, 未单独设置时跟随全局状态
+func (e *Engine) IsEnabledIn(gid int64) bool {
+	if !e.hasState.Load() {
+		return true
+	}
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+	if enable, ok := e.groups[gid]; ok && gid != 0 {
+		return enable
+	}
+	return !e.disabled
+}
+
+// GroupStates 返回单独设置了启用状态的 gid
+func (e *Engine) GroupStates() map[int64]bool {
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+	m := make(map[int64]bool, len(e.groups))
+	for gid, enable := range e.groups {
+		m[gid] = enable
+	}
+	return m
+}
+
+// eventGID 事件所在的 gid, 私聊为 -user_id
+func eventGID(e *Event) int64 {
+	if e.GroupID != 0 {
+		return e.GroupID
+	}
+	return -e.UserID
+}
+
+// engineDisabled Matcher 所属 Engine 是否在事件所在处被禁用
+func engineDisabled(e *Engine, ctx *Ctx) bool {
+	return e != nil && ctx.Event != nil && !e.IsEnabledIn(eventGID(ctx.Event))
+}
+
+// EventRecord 最近事件的摘要
+type EventRecord struct {
+	Index      uintptr   `json:"index"`
+	Time       time.Time `json:"time"`
+	SelfID     int64     `json:"self_id"`
+	PostType   string    `json:"post_type"`
+	DetailType string    `json:"detail_type"`
+	SubType    string    `json:"sub_type,omitempty"`
+	GroupID    int64     `json:"group_id,omitempty"`
+	UserID     int64     `json:"user_id,omitempty"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// RecentEventsLen 保留的最近事件数
+const RecentEventsLen = 64
+
+var recentEvents struct {
+	sync.Mutex
+	buf  [RecentEventsLen]EventRecord
+	next uint
+}
+
+func recordEvent(e *Event, idx uintptr) {
+	r := EventRecord{
+		Index:      idx,
+		Time:       time.Now(),
+		SelfID:     e.SelfID,
+		PostType:   e.PostType,
+		DetailType: e.DetailType,
+		SubType:    e.SubType,
+		GroupID:    e.GroupID,
+		UserID:     e.UserID,
+		Message:    e.RawMessage,
+	}
+	recentEvents.Lock()
+	recentEvents.buf[recentEvents.next%RecentEventsLen] = r
+	recentEvents.next++
+	recentEvents.Unlock()
+}
+
+// RecentEvents 返回最近收到的事件, 新事件在前
+func RecentEvents() []EventRecord {
+	recentEvents.Lock()
+	defer recentEvents.Unlock()
+	n := recentEvents.next
+	if n > RecentEventsLen {
+		n = RecentEventsLen
+	}
+	records := make([]EventRecord, 0, n)
+	for i := uint(1); i <= n; i++ {
+		records = append(records, recentEvents.buf[(recentEvents.next-i)%RecentEventsLen])
+	}
+	return records
+}
+
+// LogCount 子系统输出的警告与错误日志数
+type LogCount struct {
+	Subsystem string `json:"subsystem"`
+	Warn      uint64 `json:"warn"`
+	Error     uint64 `json:"error"`
+}
+
+var logCounts sync.Map // subsystem -> *[2]uint64
+
+func countLog(subsystem string, level Level) {
+	if level < LevelWarn {
+		return
+	}
+	v, ok := logCounts.Load(subsystem)
+	if !ok {
+		v, _ = logCounts.LoadOrStore(subsystem, new([2]uint64))
+	}
+	c := v.(*[2]uint64)
+	if level < LevelError {
+		atomic.AddUint64(&c[0], 1)
+	} else {
+		atomic.AddUint64(&c[1], 1)
+	}
+}
+
+// LogCounts 返回各子系统自启动以来的警告与错误日志数, 不受日志级别影响
+func LogCounts() []LogCount {
+	var counts []LogCount
+	logCounts.Range(func(k, v any) bool {
+		c := v.(*[2]uint64)
+		counts = append(counts, LogCount{
+			Subsystem: k.(string),
+			Warn:      atomic.LoadUint64(&c[0]),
+			Error:     atomic.LoadUint64(&c[1]),
+		})
+		return true
+	})
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Subsystem < counts[j].Subsystem })
+	return counts
+}
+
+// DriverState 驱动的连接状态
+type DriverState struct {
+	Type      string  `json:"type"`
+	URL       string  `json:"url"`
+	Connected bool    `json:"connected"`
+	SelfIDs   []int64 `json:"self_ids"`
+}
+
+// DriverStatus 可由 Driver 实现, 用于管理接口展示连接状态
+type DriverStatus interface {
+	Status() DriverState
+}
@@ -0,0 +1,60 @@
+package zero
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineEnable(t *testing.T) {
+	e := New()
+	defer e.Delete()
+	assert.Contains(t, Engines(), e)
+	deleted := New()
+	deleted.Delete()
+	assert.NotContains(t, Engines(), deleted)
+	assert.Equal(t, e.ID()+1, deleted.ID())
+	assert.True(t, e.IsEnabledIn(100))
+	e.Disable(100)
+	assert.False(t, e.IsEnabledIn(100))
+	assert.True(t, e.IsEnabledIn(200))
+	e.Disable(0).Enable(200)
+	assert.False(t, e.IsEnabledIn(100))
+	assert.True(t, e.IsEnabledIn(200))
+	assert.False(t, e.IsEnabledIn(-1))
+	assert.Equal(t, map[int64]bool{100: false, 200: true}, e.GroupStates())
+	e.Enable(0).Reset(100)
+	assert.True(t, e.IsEnabledIn(100))
+
+	e.Disable(-1)
+	assert.True(t, engineDisabled(e, &Ctx{Event: &Event{UserID: 1}}))
+	assert.False(t, engineDisabled(e, &Ctx{Event: &Event{UserID: 1, GroupID: 100}}))
+}
+
+func TestRecentEvents(t *testing.T) {
+	e := New()
+	defer e.Delete()
+	e.Disable(-3)
+	called := make(chan struct{}, 1)
+	e.OnMessage(func(ctx *Ctx) bool { return ctx.Event.RawMessage == "recent" }).Handle(func(*Ctx) {
+		called <- struct{}{}
+	})
+	caller := fakeCaller(func(APIRequest) APIResponse { return APIResponse{} })
+	processEventAsync([]byte(`{"post_type":"message","message_type":"private","raw_message":"recent","message":"recent","user_id":3,"self_id":2,"message_id":1,"sender":{"user_id":3}}`), caller, time.Second)
+	r := RecentEvents()[0]
+	assert.Equal(t, "message", r.PostType)
+	assert.Equal(t, "private", r.DetailType)
+	assert.Equal(t, int64(3), r.UserID)
+	assert.Equal(t, "recent", r.Message)
+	select {
+	case <-called:
+		t.Fatal("disabled engine handled event")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	countLog("status-test", LevelWarn)
+	countLog("status-test", LevelError)
+	countLog("status-test", LevelInfo)
+	assert.Contains(t, LogCounts(), LogCount{Subsystem: "status-test", Warn: 1, Error: 1})
+}
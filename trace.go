@@ -0,0 +1,83 @@
+package zero
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Attr 追踪属性
+type Attr struct {
+	Key   string
+	Value any // string, bool, int64, float64 或其它可格式化的值
+}
+
+// Span 一段被追踪的操作
+type Span interface {
+	SetAttributes(attrs ...Attr)
+	// SetError 标记操作失败
+	SetError(err error)
+	End()
+}
+
+// Tracer 追踪器, 可适配 OpenTelemetry 等实现
+//
+// Start 以 c 中的 Span 为父 Span 创建新的 Span, 并返回携带新 Span 的 context
+type Tracer interface {
+	Start(c context.Context, name string, attrs ...Attr) (context.Context, Span)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attr) {}
+func (noopSpan) SetError(error)        {}
+func (noopSpan) End()                  {}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(c context.Context, _ string, _ ...Attr) (context.Context, Span) {
+	return c, noopSpan{}
+}
+
+type tracerHolder struct{ Tracer }
+
+var tracer atomic.Value
+
+func init() {
+	tracer.Store(tracerHolder{noopTracer{}})
+}
+
+// SetTracer 设置全局追踪器, 为 nil 时关闭追踪
+//
+// 启用后每个事件产生一个 event Span, 其下为每个匹配类型的 Matcher 的 matcher Span,
+// Handler 在 handler Span 中运行, 经 Ctx 调用的 API 产生 api Span
+func SetTracer(t Tracer) {
+	if t == nil {
+		t = noopTracer{}
+	}
+	tracer.Store(tracerHolder{t})
+}
+
+// GetTracer 返回全局追踪器
+func GetTracer() Tracer {
+	return tracer.Load().(tracerHolder).Tracer
+}
+
+func tracing() bool {
+	_, ok := GetTracer().(noopTracer)
+	return !ok
+}
+
+type ctxHolder struct{ context.Context }
+
+// Context 返回当前事件处理所在的 context, 携带追踪信息
+func (ctx *Ctx) Context() context.Context {
+	if h, ok := ctx.c.Load().(ctxHolder); ok && h.Context != nil {
+		return h.Context
+	}
+	return context.Background()
+}
+
+// WithContext 设置 Ctx 的 context, 之后经 Ctx 调用的 API 使用此 context
+func (ctx *Ctx) WithContext(c context.Context) {
+	ctx.c.Store(ctxHolder{c})
+}
@@ -0,0 +1,92 @@
+package zero
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordSpan struct {
+	name   string
+	parent *recordSpan
+	attrs  map[string]any
+	t      *recordTracer
+}
+
+func (s *recordSpan) SetAttributes(attrs ...Attr) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+func (s *recordSpan) SetError(error) {}
+func (s *recordSpan) End() {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.t.ended = append(s.t.ended, s)
+}
+
+type spanKey struct{}
+
+type recordTracer struct {
+	mu    sync.Mutex
+	ended []*recordSpan
+}
+
+func (t *recordTracer) Start(c context.Context, name string, attrs ...Attr) (context.Context, Span) {
+	s := &recordSpan{name: name, attrs: map[string]any{}, t: t}
+	if p, ok := c.Value(spanKey{}).(*recordSpan); ok {
+		s.parent = p
+	}
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+	return context.WithValue(c, spanKey{}, s), s
+}
+
+func TestTracing(t *testing.T) {
+	tr := &recordTracer{}
+	SetTracer(tr)
+	defer SetTracer(nil)
+
+	e := New().SetName("trace")
+	defer e.Delete()
+	done := make(chan struct{})
+	e.OnMessage(func(ctx *Ctx) bool { return ctx.Event.RawMessage == "trace" }).Handle(func(ctx *Ctx) {
+		ctx.CallAction("get_status", nil)
+		close(done)
+	})
+	caller := fakeCaller(func(APIRequest) APIResponse { return APIResponse{RetCode: 0} })
+	processEventAsync([]byte(`{"post_type":"message","message_type":"private","raw_message":"trace","message":"trace","user_id":1,"self_id":2,"message_id":1,"sender":{"user_id":1}}`), caller, time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+	assert.Eventually(t, func() bool {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		return len(tr.ended) > 0 && tr.ended[len(tr.ended)-1].name == "event message/private"
+	}, time.Second, 10*time.Millisecond)
+
+	var api *recordSpan
+	tr.mu.Lock()
+	for _, s := range tr.ended {
+		if s.name == "api get_status" {
+			api = s
+		}
+	}
+	tr.mu.Unlock()
+	if api == nil {
+		t.Fatal("api span not ended")
+	}
+	assert.Equal(t, int64(0), api.attrs["zerobot.api.retcode"])
+	assert.Equal(t, "handler", api.parent.name)
+	assert.Equal(t, "matcher trace#1", api.parent.parent.name)
+	assert.Equal(t, true, api.parent.parent.attrs["zerobot.matcher.hit"])
+	assert.Equal(t, "event message/private", api.parent.parent.parent.name)
+}
@@ -0,0 +1,236 @@
+// Package metrics 提供以 Prometheus 文本格式导出的简易指标
+//
+// 默认关闭, 调用 Enable 或 Handler 后开始记录, 未启用时记录操作几乎没有开销
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var enabled atomic.Bool
+
+// Enable 开始记录指标
+func Enable() { enabled.Store(true) }
+
+// Enabled 是否正在记录指标
+func Enabled() bool { return enabled.Load() }
+
+// collector 一个指标族
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry 指标注册表
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+// NewRegistry 新建空的注册表
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]collector{}}
+}
+
+// Default 默认注册表, 包级构造函数在此注册
+var Default = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[c.name()]; ok {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	r.collectors[c.name()] = c
+}
+
+// WriteTo 以 Prometheus 文本格式写出所有指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cs := make([]collector, len(names))
+	for i, name := range names {
+		cs[i] = r.collectors[name]
+	}
+	r.mu.RUnlock()
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range cs {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Handler 返回输出 r 中指标的 http.Handler, 并启用记录
+func (r *Registry) Handler() http.Handler {
+	Enable()
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// Handler 返回输出默认注册表的 http.Handler, 并启用记录
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// ListenAndServe 在 addr 上提供 /metrics
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return http.ListenAndServe(addr, mux)
+}
+
+// family 带标签的指标族
+type family[T any] struct {
+	fname  string
+	help   string
+	typ    string
+	labels []string
+	newFn  func() *T
+	mu     sync.RWMutex
+	series map[string]*series[T]
+}
+
+type series[T any] struct {
+	values []string
+	metric *T
+}
+
+func newFamily[T any](name, help, typ string, labels []string, newFn func() *T) *family[T] {
+	return &family[T]{
+		fname: name, help: help, typ: typ, labels: labels,
+		newFn: newFn, series: map[string]*series[T]{},
+	}
+}
+
+func (f *family[T]) name() string { return f.fname }
+
+func (f *family[T]) with(values []string) *T {
+	if len(values) != len(f.labels) {
+		panic("metrics: " + f.fname + " expects " + strconv.Itoa(len(f.labels)) + " label values")
+	}
+	key := strings.Join(values, "\xff")
+	f.mu.RLock()
+	s, ok := f.series[key]
+	f.mu.RUnlock()
+	if ok {
+		return s.metric
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok = f.series[key]; !ok {
+		s = &series[T]{values: append([]string{}, values...), metric: f.newFn()}
+		f.series[key] = s
+	}
+	return s.metric
+}
+
+// each 按标签值排序遍历
+func (f *family[T]) each(fn func(labels string, m *T)) {
+	f.mu.RLock()
+	list := make([]*series[T], 0, len(f.series))
+	for _, s := range f.series {
+		list = append(list, s)
+	}
+	f.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return strings.Join(list[i].values, "\xff") < strings.Join(list[j].values, "\xff")
+	})
+	for _, s := range list {
+		fn(formatLabels(f.labels, s.values, "", ""), s.metric)
+	}
+}
+
+func (f *family[T]) writeHeader(w *bufio.Writer) {
+	w.WriteString("# HELP " + f.fname + " " + escapeHelp(f.help) + "\n")
+	w.WriteString("# TYPE " + f.fname + " " + f.typ + "\n")
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+// formatLabels 生成 {k="v",...}, extra 为额外的一个标签 (如 le)
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	sb := strings.Builder{}
+	sb.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(n + `="` + labelEscaper.Replace(values[i]) + `"`)
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extraName + `="` + extraValue + `"`)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// atomicFloat 以原子操作更新的 float64
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		n := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&f.bits, old, n) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(v float64) { atomic.StoreUint64(&f.bits, math.Float64bits(v)) }
+
+func (f *atomicFloat) load() float64 { return math.Float64frombits(atomic.LoadUint64(&f.bits)) }
+
+func formatUint(v uint64) string { return strconv.FormatUint(v, 10) }
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "计数", "a")
+	g := r.NewGaugeVec("test_gauge", "gauge")
+	h := r.NewHistogramVec("test_seconds", "耗时", []float64{1, 0.1}, "op")
+	r.NewGaugeFunc("test_func", "func", func() float64 { return 3 })
+
+	c.With("x").Inc() // 未启用, 不记录
+	g.With().Inc()    // Gauge 始终记录, 启用后的 Dec 才能与之抵消
+	Enable()
+	g.With().Dec()
+	defer enabled.Store(false)
+	c.With("x").Inc()
+	c.With(`y"`).Add(2)
+	g.With().Set(1.5)
+	h.With("get").Observe(0.05)
+	h.With("get").Observe(0.1)
+	h.With("get").Observe(5)
+	assert.Panics(t, func() { r.NewGaugeVec("test_gauge", "dup") })
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, `# HELP test_func func
+# TYPE test_func gauge
+test_func 3
+# HELP test_gauge gauge
+# TYPE test_gauge gauge
+test_gauge 1.5
+# HELP test_seconds 耗时
+# TYPE test_seconds histogram
+test_seconds_bucket{op="get",le="0.1"} 2
+test_seconds_bucket{op="get",le="1"} 2
+test_seconds_bucket{op="get",le="+Inf"} 3
+test_seconds_sum{op="get"} 5.15
+test_seconds_count{op="get"} 3
+# HELP test_total 计数
+# TYPE test_total counter
+test_total{a="x"} 1
+test_total{a="y\""} 2
+`, w.Body.String())
+}
@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"bufio"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Counter 只增计数器
+type Counter struct {
+	v atomicFloat
+}
+
+// Inc 加一
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add 增加 v, v 应为非负数
+func (c *Counter) Add(v float64) {
+	if Enabled() {
+		c.v.add(v)
+	}
+}
+
+// Value 当前值
+func (c *Counter) Value() float64 { return c.v.load() }
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	*family[Counter]
+}
+
+// NewCounterVec 在 r 中注册计数器
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{newFamily(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(v)
+	return v
+}
+
+// NewCounterVec 在默认注册表中注册计数器
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// With 返回对应标签值的计数器
+func (v *CounterVec) With(values ...string) *Counter { return v.with(values) }
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	v.each(func(labels string, c *Counter) {
+		w.WriteString(v.fname + labels + " " + formatFloat(c.Value()) + "\n")
+	})
+}
+
+// Gauge 可增减的值
+type Gauge struct {
+	v atomicFloat
+}
+
+// Set 设置为 v
+//
+// 与 Counter 不同, Gauge 不受 Enabled 限制, 以免开启前后的 Inc 与 Dec 不成对
+func (g *Gauge) Set(v float64) { g.v.set(v) }
+
+// Add 增加 v
+func (g *Gauge) Add(v float64) { g.v.add(v) }
+
+// Inc 加一
+func (g *Gauge) Inc() { g.Add(1) }
+
+// Dec 减一
+func (g *Gauge) Dec() { g.Add(-1) }
+
+// Value 当前值
+func (g *Gauge) Value() float64 { return g.v.load() }
+
+// GaugeVec 带标签的 Gauge
+type GaugeVec struct {
+	*family[Gauge]
+}
+
+// NewGaugeVec 在 r 中注册 Gauge
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{newFamily(name, help, "gauge", labels, func() *Gauge { return &Gauge{} })}
+	r.register(v)
+	return v
+}
+
+// NewGaugeVec 在默认注册表中注册 Gauge
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return Default.NewGaugeVec(name, help, labels...)
+}
+
+// With 返回对应标签值的 Gauge
+func (v *GaugeVec) With(values ...string) *Gauge { return v.with(values) }
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	v.each(func(labels string, g *Gauge) {
+		w.WriteString(v.fname + labels + " " + formatFloat(g.Value()) + "\n")
+	})
+}
+
+// GaugeFunc 导出时调用函数取值的 Gauge
+type GaugeFunc struct {
+	fname, help string
+	fn          func() float64
+}
+
+// NewGaugeFunc 在 r 中注册 GaugeFunc
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{fname: name, help: help, fn: fn}
+	r.register(g)
+	return g
+}
+
+// NewGaugeFunc 在默认注册表中注册 GaugeFunc
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, fn)
+}
+
+func (g *GaugeFunc) name() string { return g.fname }
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	w.WriteString("# HELP " + g.fname + " " + escapeHelp(g.help) + "\n")
+	w.WriteString("# TYPE " + g.fname + " gauge\n")
+	w.WriteString(g.fname + " " + formatFloat(g.fn()) + "\n")
+}
+
+// DefBuckets 默认的直方图桶 (秒)
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// Histogram 直方图
+type Histogram struct {
+	upper  []float64
+	counts []uint64 // 非累积计数, 最后一个为 +Inf
+	sum    atomicFloat
+	count  uint64
+}
+
+// Observe 记录一个值
+func (h *Histogram) Observe(v float64) {
+	if !Enabled() {
+		return
+	}
+	i := sort.SearchFloat64s(h.upper, v)
+	atomic.AddUint64(&h.counts[i], 1)
+	h.sum.add(v)
+	atomic.AddUint64(&h.count, 1)
+}
+
+// ObserveSince 记录自 t 以来经过的秒数
+func (h *Histogram) ObserveSince(t time.Time) {
+	h.Observe(time.Since(t).Seconds())
+}
+
+// Count 记录的次数
+func (h *Histogram) Count() uint64 { return atomic.LoadUint64(&h.count) }
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	*family[Histogram]
+	buckets []float64
+}
+
+// NewHistogramVec 在 r 中注册直方图, buckets 为 nil 时使用 DefBuckets
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	v := &HistogramVec{buckets: buckets}
+	v.family = newFamily(name, help, "histogram", labels, func() *Histogram {
+		return &Histogram{upper: buckets, counts: make([]uint64, len(buckets)+1)}
+	})
+	r.register(v)
+	return v
+}
+
+// NewHistogramVec 在默认注册表中注册直方图
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// With 返回对应标签值的直方图
+func (v *HistogramVec) With(values ...string) *Histogram { return v.with(values) }
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	v.family.mu.RLock()
+	list := make([]*series[Histogram], 0, len(v.series))
+	for _, s := range v.series {
+		list = append(list, s)
+	}
+	v.family.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return formatLabels(v.labels, list[i].values, "", "") < formatLabels(v.labels, list[j].values, "", "")
+	})
+	for _, s := range list {
+		h := s.metric
+		cum := uint64(0)
+		for i, upper := range h.upper {
+			cum += atomic.LoadUint64(&h.counts[i])
+			w.WriteString(v.fname + "_bucket" + formatLabels(v.labels, s.values, "le", formatFloat(upper)) +
+				" " + formatUint(cum) + "\n")
+		}
+		cum += atomic.LoadUint64(&h.counts[len(h.upper)])
+		w.WriteString(v.fname + "_bucket" + formatLabels(v.labels, s.values, "le", "+Inf") + " " + formatUint(cum) + "\n")
+		labels := formatLabels(v.labels, s.values, "", "")
+		w.WriteString(v.fname + "_sum" + labels + " " + formatFloat(h.sum.load()) + "\n")
+		w.WriteString(v.fname + "_count" + labels + " " + formatUint(cum) + "\n")
+	}
+}